@@ -0,0 +1,344 @@
+package buffer
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// aeadChunkSize is a size of plaintext chunks sealed with a custom cipher.AEAD
+	aeadChunkSize = 64 << 10 // 64 KB
+	// aeadMinNonceSize is the min nonce size of a custom cipher.AEAD. The random nonce prefix
+	// of a stream is 16 bytes long, so prefixes of different streams don't collide
+	aeadMinNonceSize = 24
+)
+
+// EnableEncryptionWithAEAD enables encryption with passed cipher.AEAD instead of github.com/minio/sio.
+// It allows to use vetted crypto implementations or hardware-backed keys. The key of aead is used
+// for all temp files, so the nonce size of aead must be at least 24 bytes: for example, XChaCha20-Poly1305
+// (see golang.org/x/crypto/chacha20poly1305.NewX). Random 12-byte nonces of AES-GCM would collide
+// after a large number of temp files, and a collision breaks both confidentiality and authenticity.
+//
+// Data is split into chunks of 64 KB. Every chunk is sealed with a unique nonce: a random prefix
+// generated for every temp file and a sequence number of the chunk. The last chunk is marked
+// in additional data, so truncation of a temp file is detected
+func (b *Buffer) EnableEncryptionWithAEAD(aead cipher.AEAD) error {
+	if aead == nil {
+		return errors.New("aead can't be nil")
+	}
+	if aead.NonceSize() < aeadMinNonceSize {
+		return errors.Errorf("nonce size must be at least %d bytes, got %d", aeadMinNonceSize, aead.NonceSize())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.encrypt = true
+	b.aead = aead
+
+	return nil
+}
+
+// aeadNonce returns a nonce for the chunk with index seq
+func aeadNonce(dst, prefix []byte, seq uint64) []byte {
+	dst = append(dst[:0], prefix...)
+	return binary.BigEndian.AppendUint64(dst, seq)
+}
+
+// aeadAdditionalData returns additional data for the chunk with index seq
+func aeadAdditionalData(dst []byte, seq uint64, final bool) []byte {
+	dst = binary.BigEndian.AppendUint64(dst[:0], seq)
+	if final {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// aeadWriter encrypts data with cipher.AEAD. The output has the following format:
+//
+//	header | nonce prefix | sealed chunk 0 | sealed chunk 1 | ... | sealed final chunk
+//
+// All chunks except the final one contain aeadChunkSize bytes of plaintext. The header is empty
+// by default
+type aeadWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+
+	header []byte
+	prefix []byte
+	seq    uint64
+
+	// chunk contains plaintext that isn't sealed yet
+	chunk []byte
+	// sealed is used to avoid allocations
+	sealed []byte
+	nonce  []byte
+	ad     []byte
+
+	headerWritten bool
+	closed        bool
+}
+
+func newAEADWriter(w io.Writer, aead cipher.AEAD) (*aeadWriter, error) {
+	prefix := make([]byte, aead.NonceSize()-8)
+	_, err := rand.Read(prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read random data")
+	}
+
+	return &aeadWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		chunk:  make([]byte, 0, aeadChunkSize),
+	}, nil
+}
+
+func (aw *aeadWriter) Write(p []byte) (n int, err error) {
+	if aw.closed {
+		return 0, errors.New("write to closed encryption stream")
+	}
+
+	for len(p) > 0 {
+		// Seal the chunk only when there's more data: the last chunk must be marked as final
+		if len(aw.chunk) == aeadChunkSize {
+			err = aw.seal(false)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		bound := aeadChunkSize - len(aw.chunk)
+		if bound > len(p) {
+			bound = len(p)
+		}
+		aw.chunk = append(aw.chunk, p[:bound]...)
+		p = p[bound:]
+		n += bound
+	}
+
+	return n, nil
+}
+
+func (aw *aeadWriter) seal(final bool) error {
+	if !aw.headerWritten {
+		_, err := aw.w.Write(append(aw.header, aw.prefix...))
+		if err != nil {
+			return err
+		}
+		aw.headerWritten = true
+	}
+
+	aw.nonce = aeadNonce(aw.nonce, aw.prefix, aw.seq)
+	aw.ad = aeadAdditionalData(aw.ad, aw.seq, final)
+	aw.sealed = aw.aead.Seal(aw.sealed[:0], aw.nonce, aw.chunk, aw.ad)
+
+	_, err := aw.w.Write(aw.sealed)
+	if err != nil {
+		return err
+	}
+
+	aw.seq++
+	aw.chunk = aw.chunk[:0]
+
+	return nil
+}
+
+// Close seals the final chunk and closes the underlying writer if it implements io.Closer
+func (aw *aeadWriter) Close() error {
+	if aw.closed {
+		return nil
+	}
+	aw.closed = true
+
+	err := aw.seal(true)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := aw.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// aeadReader decrypts data encrypted by aeadWriter
+type aeadReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+
+	prefix []byte
+	seq    uint64
+
+	sealed []byte
+	// plaintext is the decrypted part of the current chunk that wasn't read yet
+	plaintext []byte
+	nonce     []byte
+	ad        []byte
+
+	err error
+}
+
+func newAEADReader(r io.Reader, aead cipher.AEAD) *aeadReader {
+	return &aeadReader{
+		r:      bufio.NewReaderSize(r, aeadChunkSize+aead.Overhead()+1),
+		aead:   aead,
+		sealed: make([]byte, aeadChunkSize+aead.Overhead()),
+	}
+}
+
+func (ar *aeadReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(ar.plaintext) == 0 {
+			if ar.err != nil {
+				break
+			}
+			ar.err = ar.next()
+			continue
+		}
+
+		copied := copy(p[n:], ar.plaintext)
+		ar.plaintext = ar.plaintext[copied:]
+		n += copied
+	}
+
+	if n == 0 {
+		return 0, ar.err
+	}
+	return n, nil
+}
+
+// next decrypts the next chunk. It returns io.EOF after the final chunk
+func (ar *aeadReader) next() error {
+	if ar.prefix == nil {
+		prefix := make([]byte, ar.aead.NonceSize()-8)
+		_, err := io.ReadFull(ar.r, prefix)
+		if err != nil {
+			return errors.Wrap(ErrTampered, "can't read nonce prefix")
+		}
+		ar.prefix = prefix
+	}
+
+	n, err := io.ReadFull(ar.r, ar.sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return errors.Wrap(ErrTampered, "final chunk is missing")
+		}
+		return err
+	}
+
+	// The chunk is final if there's no more data
+	_, peekErr := ar.r.Peek(1)
+	final := peekErr == io.EOF
+
+	ar.nonce = aeadNonce(ar.nonce, ar.prefix, ar.seq)
+	ar.ad = aeadAdditionalData(ar.ad, ar.seq, final)
+	ar.plaintext, err = ar.aead.Open(ar.sealed[:0], ar.nonce, ar.sealed[:n], ar.ad)
+	if err != nil {
+		return errors.Wrapf(ErrTampered, "can't authenticate chunk %d", ar.seq)
+	}
+	ar.seq++
+
+	if final {
+		return io.EOF
+	}
+	return nil
+}
+
+// aeadReaderAt decrypts data encrypted by aeadWriter. It is safe for concurrent use
+type aeadReaderAt struct {
+	r    io.ReaderAt
+	aead cipher.AEAD
+
+	prefix []byte
+	// size is the size of the encrypted data
+	size    int64
+	pkgSize int64
+	chunks  int64
+}
+
+func newAEADReaderAt(r io.ReaderAt, size int64, aead cipher.AEAD) (*aeadReaderAt, error) {
+	prefix := make([]byte, aead.NonceSize()-8)
+	n, err := r.ReadAt(prefix, 0)
+	if n < len(prefix) {
+		if err == nil || err == io.EOF {
+			err = errors.Wrap(ErrTampered, "can't read nonce prefix")
+		}
+		return nil, err
+	}
+
+	var (
+		pkgSize  = int64(aeadChunkSize + aead.Overhead())
+		dataSize = size - int64(len(prefix))
+		chunks   = (dataSize + pkgSize - 1) / pkgSize
+	)
+	if chunks <= 0 {
+		return nil, errors.Wrap(ErrTampered, "final chunk is missing")
+	}
+
+	return &aeadReaderAt{
+		r:       r,
+		aead:    aead,
+		prefix:  prefix,
+		size:    size,
+		pkgSize: pkgSize,
+		chunks:  chunks,
+	}, nil
+}
+
+func (ar *aeadReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset: %d", off)
+	}
+
+	var (
+		sealed    = make([]byte, ar.pkgSize)
+		plaintext []byte
+		nonce     []byte
+		ad        []byte
+	)
+	for n < len(p) {
+		seq := off / aeadChunkSize
+		if seq >= ar.chunks {
+			return n, io.EOF
+		}
+
+		pkgOff := int64(len(ar.prefix)) + seq*ar.pkgSize
+		pkgLen := ar.size - pkgOff
+		if pkgLen > ar.pkgSize {
+			pkgLen = ar.pkgSize
+		}
+
+		read, err := ar.r.ReadAt(sealed[:pkgLen], pkgOff)
+		if int64(read) < pkgLen {
+			if err == nil || err == io.EOF {
+				err = errors.Wrap(ErrTampered, "chunk is truncated")
+			}
+			return n, err
+		}
+
+		final := seq == ar.chunks-1
+		nonce = aeadNonce(nonce, ar.prefix, uint64(seq))
+		ad = aeadAdditionalData(ad, uint64(seq), final)
+		plaintext, err = ar.aead.Open(plaintext[:0], nonce, sealed[:pkgLen], ad)
+		if err != nil {
+			return n, errors.Wrapf(ErrTampered, "can't authenticate chunk %d", seq)
+		}
+
+		chunkOff := off - seq*aeadChunkSize
+		if chunkOff >= int64(len(plaintext)) {
+			return n, io.EOF
+		}
+
+		copied := copy(p[n:], plaintext[chunkOff:])
+		n += copied
+		off += int64(copied)
+	}
+
+	return n, nil
+}
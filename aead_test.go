@@ -0,0 +1,225 @@
+package buffer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	mathrand "math/rand"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func newTestAEAD(require *require.Assertions, nonceSize int) cipher.AEAD {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.Nil(err)
+
+	block, err := aes.NewCipher(key)
+	require.Nil(err)
+
+	aead, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	require.Nil(err)
+
+	return aead
+}
+
+func TestBuffer_EnableEncryptionWithAEAD(t *testing.T) {
+	sizes := []int{
+		1,
+		100,
+		aeadChunkSize - 1,
+		aeadChunkSize,
+		aeadChunkSize + 1,
+		3 * aeadChunkSize,
+		mathrand.Intn(1<<20) + 1,
+	}
+
+	for _, size := range sizes {
+		size := size
+
+		for _, nonceSize := range []int{24, 32} {
+			nonceSize := nonceSize
+
+			t.Run("", func(t *testing.T) {
+				t.Parallel()
+
+				require := require.New(t)
+
+				data := []byte(generateRandomString(size))
+
+				newBuffer := func() *Buffer {
+					b := NewBufferWithMaxMemorySize(0)
+					require.Nil(b.EnableEncryptionWithAEAD(newTestAEAD(require, nonceSize)))
+
+					_, err := b.Write(data)
+					require.Nil(err)
+
+					return b
+				}
+
+				// Read
+				b := newBuffer()
+				defer b.Reset()
+
+				require.Equal(string(data), string(readByChunks(require, b, 1000)))
+
+				// ReadAt
+				b = newBuffer()
+				defer b.Reset()
+
+				off := mathrand.Intn(size)
+				res := make([]byte, size-off)
+				n, err := b.ReadAt(res, int64(off))
+				require.Nil(err)
+				require.Equal(len(res), n)
+				require.Equal(data[off:], res)
+			})
+		}
+	}
+
+	t.Run("Invalid AEAD", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBuffer(nil)
+		require.NotNil(b.EnableEncryptionWithAEAD(nil))
+
+		block, err := aes.NewCipher(make([]byte, 32))
+		require.Nil(err)
+		// Random nonce prefixes of 12-byte nonces can collide
+		for _, nonceSize := range []int{8, 12, 16} {
+			aead, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+			require.Nil(err)
+			require.NotNil(b.EnableEncryptionWithAEAD(aead))
+		}
+	})
+
+	t.Run("XChaCha20-Poly1305", func(t *testing.T) {
+		require := require.New(t)
+
+		aead, err := chacha20poly1305.NewX(make([]byte, chacha20poly1305.KeySize))
+		require.Nil(err)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+		require.Nil(b.EnableEncryptionWithAEAD(aead))
+
+		data := []byte(generateRandomString(200 << 10))
+		writeByChunks(require, b, data, 4096)
+		require.Equal(data, readByChunks(require, b, 4096))
+	})
+}
+
+// nonceRecorder records nonces passed to Seal
+type nonceRecorder struct {
+	cipher.AEAD
+
+	nonces map[string]bool
+	reused bool
+}
+
+func (nr *nonceRecorder) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if nr.nonces[string(nonce)] {
+		nr.reused = true
+	}
+	nr.nonces[string(nonce)] = true
+	return nr.AEAD.Seal(dst, nonce, plaintext, additionalData)
+}
+
+func TestBuffer_EnableEncryptionWithAEAD_UniqueNonces(t *testing.T) {
+	require := require.New(t)
+
+	// The key is shared by all streams: temp files of different Buffers and their rewrites
+	aead := &nonceRecorder{AEAD: newTestAEAD(require, aeadMinNonceSize), nonces: map[string]bool{}}
+	for i := 0; i < 100; i++ {
+		b := NewBufferWithMaxMemorySize(0)
+		require.Nil(b.EnableEncryptionWithAEAD(aead))
+
+		_, err := b.Write([]byte(generateRandomString(2*aeadChunkSize + 1)))
+		require.Nil(err)
+
+		_, err = b.Read(make([]byte, aeadChunkSize+1))
+		require.Nil(err)
+		require.Nil(b.Compact())
+		b.Reset()
+	}
+	require.True(len(aead.nonces) >= 100*3)
+	require.False(aead.reused, "nonces must be unique for all streams")
+}
+
+func TestBuffer_EnableEncryptionWithAEAD_Tampered(t *testing.T) {
+	const size = 3 * aeadChunkSize
+
+	tests := []struct {
+		desc   string
+		damage func(require *require.Assertions, filename string, overhead int)
+	}{
+		{
+			desc: "Modified file",
+			damage: func(require *require.Assertions, filename string, _ int) {
+				f, err := os.OpenFile(filename, os.O_RDWR, 0)
+				require.Nil(err)
+				defer f.Close()
+
+				_, err = f.WriteAt([]byte("!!!"), 100)
+				require.Nil(err)
+			},
+		},
+		{
+			desc: "Final chunk is removed",
+			damage: func(require *require.Assertions, filename string, overhead int) {
+				require.Nil(os.Truncate(filename, int64(aeadMinNonceSize-8+2*(aeadChunkSize+overhead))))
+			},
+		},
+		{
+			desc: "Truncated chunk",
+			damage: func(require *require.Assertions, filename string, _ int) {
+				require.Nil(os.Truncate(filename, 100))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			newBuffer := func() *Buffer {
+				aead := newTestAEAD(require, aeadMinNonceSize)
+
+				b := NewBufferWithMaxMemorySize(0)
+				require.Nil(b.EnableEncryptionWithAEAD(aead))
+
+				_, err := b.Write([]byte(generateRandomString(size)))
+				require.Nil(err)
+				require.Nil(b.finishWriting())
+				tt.damage(require, b.filename, aead.Overhead())
+
+				return b
+			}
+
+			// Read
+			b := newBuffer()
+			defer b.Reset()
+
+			data := make([]byte, 1024)
+			var err error
+			for err == nil {
+				_, err = b.Read(data)
+			}
+			require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+
+			// ReadAt
+			b = newBuffer()
+			defer b.Reset()
+
+			data = make([]byte, size)
+			_, err = b.ReadAt(data, 0)
+			require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+		})
+	}
+}
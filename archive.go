@@ -0,0 +1,45 @@
+package buffer
+
+import (
+	"archive/tar"
+	"archive/zip"
+
+	"github.com/pkg/errors"
+)
+
+// NewTarWriter returns a tar.Writer that writes an archive into b. Large archives are stored
+// on a disk. Use OpenTar to read the archive back
+func NewTarWriter(b *Buffer) *tar.Writer {
+	return tar.NewWriter(b)
+}
+
+// OpenTar returns a tar.Reader that reads the archive from b. The archive is consumed
+func (b *Buffer) OpenTar() *tar.Reader {
+	return tar.NewReader(b)
+}
+
+// NewZipWriter returns a zip.Writer that writes an archive into b. Large archives are stored
+// on a disk. Use OpenZip to read the archive back
+func NewZipWriter(b *Buffer) *zip.Writer {
+	return zip.NewWriter(b)
+}
+
+// OpenZip finishes writing and returns a zip.Reader that reads the archive from b. zip.Reader
+// requires io.ReaderAt and the size of the archive: ReadAt is used, so files can be read in parallel.
+// The Buffer must not be read with Read till the zip.Reader is used: the temp file is removed
+// when the Buffer is drained
+func (b *Buffer) OpenZip() (*zip.Reader, error) {
+	b.mu.Lock()
+	err := b.finishWriting()
+	size := b.size
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := zip.NewReader(b, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open zip archive")
+	}
+	return r, nil
+}
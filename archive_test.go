@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Archives(t *testing.T) {
+	files := map[string][]byte{
+		"a.txt": []byte(generateRandomString(1000)),
+		"b.txt": []byte(generateRandomString(10)),
+		"c.txt": nil,
+	}
+	names := []string{"a.txt", "b.txt", "c.txt"}
+
+	t.Run("Tar", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		w := NewTarWriter(b)
+		for _, name := range names {
+			require.Nil(w.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name]))}))
+			_, err := w.Write(files[name])
+			require.Nil(err)
+		}
+		require.Nil(w.Close())
+		require.True(b.useFile)
+
+		r := b.OpenTar()
+		for _, name := range names {
+			header, err := r.Next()
+			require.Nil(err)
+			require.Equal(name, header.Name)
+
+			data, err := ioutil.ReadAll(r)
+			require.Nil(err)
+			require.Equal(len(files[name]), len(data))
+			require.Equal(string(files[name]), string(data))
+		}
+		_, err := r.Next()
+		require.Equal(io.EOF, err)
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		w := NewZipWriter(b)
+		for _, name := range names {
+			f, err := w.Create(name)
+			require.Nil(err)
+			_, err = f.Write(files[name])
+			require.Nil(err)
+		}
+		require.Nil(w.Close())
+		require.True(b.useFile)
+
+		r, err := b.OpenZip()
+		require.Nil(err)
+		require.Len(r.File, len(names))
+
+		// Read files in reverse order: zip.Reader uses ReadAt
+		for i := len(r.File) - 1; i >= 0; i-- {
+			f, err := r.File[i].Open()
+			require.Nil(err)
+			data, err := ioutil.ReadAll(f)
+			require.Nil(err)
+			f.Close()
+
+			require.Equal(string(files[r.File[i].Name]), string(data))
+		}
+	})
+
+	t.Run("Not zip", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		_, err := b.WriteString("hello")
+		require.Nil(err)
+		_, err = b.OpenZip()
+		require.NotNil(err)
+	})
+}
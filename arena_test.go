@@ -0,0 +1,104 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SetArena(t *testing.T) {
+	_, err := NewArena(0, 1)
+	require.NotNil(t, err)
+
+	arena, err := NewArena(64, 2)
+	require.Nil(t, err)
+	require.Equal(t, 64, arena.BlockSize())
+	require.Equal(t, 2, arena.Free())
+
+	data := generateRandomString(50)
+
+	t.Run("reuse", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(64)
+		defer b.Reset()
+		b.SetArena(arena)
+
+		for i := 0; i < 3; i++ {
+			writeByChunks(require, b, []byte(data), 7)
+			require.Equal(1, arena.Free())
+			require.NotNil(b.arenaBlock)
+			require.Equal(&b.arenaBlock[0], &b.buff.Bytes()[0])
+
+			require.Equal([]byte(data), readByChunks(require, b, 9))
+
+			// Reset returns the block
+			b.Reset()
+			require.Nil(b.arenaBlock)
+			require.Equal(2, arena.Free())
+		}
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(64)
+		defer b.Reset()
+		b.SetArena(arena)
+
+		data := generateRandomString(200)
+		writeByChunks(require, b, []byte(data), 10)
+		require.True(b.useFile)
+		require.NotNil(b.arenaBlock)
+		require.Equal(1, arena.Free())
+
+		require.Equal([]byte(data), readByChunks(require, b, 30))
+		b.Reset()
+		require.Equal(2, arena.Free())
+
+		// FlushToDisk moves the data into the temp file and returns the block
+		_, err := b.WriteString(data[:50])
+		require.Nil(err)
+		require.Equal(1, arena.Free())
+		flushed, err := b.FlushToDisk()
+		require.Nil(err)
+		require.True(flushed)
+		require.Nil(b.arenaBlock)
+		require.Equal(2, arena.Free())
+
+		require.Equal([]byte(data[:50]), readByChunks(require, b, 30))
+	})
+
+	t.Run("large buffer", func(t *testing.T) {
+		require := require.New(t)
+
+		// maxInMemorySize exceeds the block size: the arena isn't used
+		b := NewBufferWithMaxMemorySize(128)
+		defer b.Reset()
+		b.SetArena(arena)
+
+		writeByChunks(require, b, []byte(data), 7)
+		require.Nil(b.arenaBlock)
+		require.Equal(2, arena.Free())
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		require := require.New(t)
+
+		buffers := make([]*Buffer, 3)
+		for i := range buffers {
+			buffers[i] = NewBufferWithMaxMemorySize(64)
+			buffers[i].SetArena(arena)
+			_, err := buffers[i].WriteString(data)
+			require.Nil(err)
+		}
+		require.Equal(0, arena.Free())
+
+		for _, b := range buffers {
+			require.Equal([]byte(data), readByChunks(require, b, 9))
+			b.Reset()
+		}
+		// Only the preallocated number of blocks is kept
+		require.Equal(2, arena.Free())
+	})
+}
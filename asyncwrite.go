@@ -0,0 +1,178 @@
+package buffer
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultAsyncWriteChunkSize is used when Buffer.EnableAsyncWrites is called with non-positive chunk size
+const DefaultAsyncWriteChunkSize = 64 << 10 // 64 KB
+
+// EnableAsyncWrites makes the Buffer write data into the temp file in a background goroutine.
+// Write copies data into chunks of chunkSize bytes, up to chunks chunks can wait for writing.
+// Write blocks only when all chunks are in use.
+//
+// An error of the background writing is returned by the next Write, Flush or Read.
+// It must be called before the first Write
+func (b *Buffer) EnableAsyncWrites(chunkSize, chunks int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultAsyncWriteChunkSize
+	}
+	if chunks < 0 {
+		chunks = 0
+	}
+
+	b.asyncWriteChunkSize = chunkSize
+	b.asyncWriteChunks = chunks
+}
+
+// Flush writes staged data (see EnableWriteStaging) and waits until all data is written into the temp file.
+// It returns an error of the background writing if any
+func (b *Buffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.flushStaging()
+	if err != nil {
+		return err
+	}
+
+	if w, ok := b.writeFile.(*asyncWriter); ok {
+		return w.Flush()
+	}
+	return nil
+}
+
+type asyncChunk struct {
+	data []byte
+	// flushed is closed when all previous chunks are written. It is used by Flush
+	flushed chan struct{}
+}
+
+// asyncWriter writes data into the underlying writer in a background goroutine
+type asyncWriter struct {
+	w         io.WriteCloser
+	chunkSize int
+
+	// staging is a chunk that is being filled
+	staging []byte
+
+	chunks chan asyncChunk
+	// free contains written chunks that can be reused
+	free   chan []byte
+	exited chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func newAsyncWriter(w io.WriteCloser, chunkSize, chunks int) *asyncWriter {
+	aw := &asyncWriter{
+		w:         w,
+		chunkSize: chunkSize,
+		chunks:    make(chan asyncChunk, chunks),
+		free:      make(chan []byte, chunks+1),
+		exited:    make(chan struct{}),
+	}
+	go aw.loop()
+
+	return aw
+}
+
+func (aw *asyncWriter) loop() {
+	defer close(aw.exited)
+
+	for chunk := range aw.chunks {
+		if chunk.flushed != nil {
+			close(chunk.flushed)
+			continue
+		}
+
+		// Skip data after an error. Writes will return the error
+		if aw.getErr() == nil {
+			_, err := aw.w.Write(chunk.data)
+			if err != nil {
+				aw.mu.Lock()
+				aw.err = err
+				aw.mu.Unlock()
+			}
+		}
+
+		select {
+		case aw.free <- chunk.data[:0]:
+		default:
+		}
+	}
+}
+
+func (aw *asyncWriter) getErr() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return aw.err
+}
+
+// Write copies p into the staging chunk. Full chunks are passed to the background goroutine
+func (aw *asyncWriter) Write(p []byte) (n int, err error) {
+	if err := aw.getErr(); err != nil {
+		return 0, err
+	}
+
+	for len(p) > 0 {
+		if aw.staging == nil {
+			select {
+			case aw.staging = <-aw.free:
+			default:
+				aw.staging = make([]byte, 0, aw.chunkSize)
+			}
+		}
+
+		bound := aw.chunkSize - len(aw.staging)
+		if bound > len(p) {
+			bound = len(p)
+		}
+		aw.staging = append(aw.staging, p[:bound]...)
+		p = p[bound:]
+		n += bound
+
+		if len(aw.staging) == aw.chunkSize {
+			aw.sendStaging()
+		}
+	}
+
+	return n, nil
+}
+
+func (aw *asyncWriter) sendStaging() {
+	if len(aw.staging) != 0 {
+		aw.chunks <- asyncChunk{data: aw.staging}
+	}
+	aw.staging = nil
+}
+
+// Flush waits until all written data is passed to the underlying writer
+func (aw *asyncWriter) Flush() error {
+	aw.sendStaging()
+
+	flushed := make(chan struct{})
+	aw.chunks <- asyncChunk{flushed: flushed}
+	<-flushed
+
+	return aw.getErr()
+}
+
+// Close writes remaining data, stops the background goroutine and closes the underlying writer
+func (aw *asyncWriter) Close() error {
+	aw.sendStaging()
+	close(aw.chunks)
+	<-aw.exited
+
+	closeErr := aw.w.Close()
+	if err := aw.getErr(); err != nil {
+		return err
+	}
+	return closeErr
+}
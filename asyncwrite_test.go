@@ -0,0 +1,150 @@
+package buffer
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_AsyncWrites(t *testing.T) {
+	tests := []struct {
+		desc    string
+		encrypt bool
+	}{
+		{desc: "Without encryption", encrypt: false},
+		{desc: "With encryption", encrypt: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				t.Run("", func(t *testing.T) {
+					t.Parallel()
+
+					require := require.New(t)
+
+					var (
+						sliceSize      = rand.Intn(1<<16) + 1
+						bufferSize     = rand.Intn(sliceSize)
+						chunkSize      = rand.Intn(1<<12) + 1
+						chunks         = rand.Intn(4) + 1
+						writeChunkSize = rand.Intn(1<<12) + 1
+					)
+
+					defer func() {
+						if t.Failed() {
+							t.Logf("sliceSize: %d; bufferSize: %d; chunkSize: %d; chunks: %d; writeChunkSize: %d\n",
+								sliceSize, bufferSize, chunkSize, chunks, writeChunkSize)
+						}
+					}()
+
+					slice := []byte(generateRandomString(sliceSize))
+
+					b := NewBufferWithMaxMemorySize(bufferSize)
+					defer b.Reset()
+
+					if tt.encrypt {
+						require.Nil(b.EnableEncryption())
+					}
+					b.EnableAsyncWrites(chunkSize, chunks)
+
+					writeByChunks(require, b, slice, writeChunkSize)
+					require.Nil(b.Flush())
+
+					res := readByChunks(require, b, 512)
+					require.Equal(slice, res, "wrong content was read")
+				})
+			}
+		})
+	}
+}
+
+type failingWriteCloser struct {
+	bytes.Buffer
+	failAfter int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriteCloser) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.failAfter {
+		return 0, errWriteFailed
+	}
+	return w.Buffer.Write(p)
+}
+
+func (w *failingWriteCloser) Close() error {
+	return nil
+}
+
+func TestAsyncWriter_Errors(t *testing.T) {
+	t.Run("Flush and Write", func(t *testing.T) {
+		require := require.New(t)
+
+		w := newAsyncWriter(&failingWriteCloser{failAfter: 10}, 8, 2)
+
+		n, err := w.Write([]byte("0123456789abcdef"))
+		require.Nil(err)
+		require.Equal(16, n)
+
+		err = w.Flush()
+		require.Equal(errWriteFailed, err)
+
+		_, err = w.Write([]byte("test"))
+		require.Equal(errWriteFailed, err)
+
+		err = w.Close()
+		require.Equal(errWriteFailed, err)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		require := require.New(t)
+
+		dst := &failingWriteCloser{failAfter: 10}
+		w := newAsyncWriter(dst, 8, 2)
+
+		_, err := w.Write([]byte("01234"))
+		require.Nil(err)
+		require.Nil(w.Close())
+		require.Equal("01234", dst.String())
+
+		w = newAsyncWriter(&failingWriteCloser{failAfter: 10}, 8, 2)
+		_, err = w.Write([]byte("0123456789abc"))
+		require.Nil(err)
+		require.Equal(errWriteFailed, w.Close())
+	})
+
+	t.Run("Buffer", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		b.EnableAsyncWrites(8, 2)
+		defer b.Reset()
+
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+
+		// Replace the underlying writer to emulate a disk failure
+		aw := b.writeFile.(*asyncWriter)
+		require.Nil(aw.Flush())
+		file := aw.w
+		defer file.Close()
+		aw.w = &failingWriteCloser{failAfter: 0}
+
+		_, err = b.Write([]byte("world"))
+		require.Nil(err)
+
+		data := make([]byte, 16)
+		_, err = b.Read(data)
+		require.True(errors.Is(err, errWriteFailed))
+
+		// The error must be returned till Reset()
+		_, err = b.Read(data)
+		require.True(errors.Is(err, errWriteFailed))
+	})
+}
@@ -0,0 +1,152 @@
+package buffer
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+// decryptedBlockSize is a size of blocks of decrypted data cached by the decrypted block cache.
+// It is equal to the payload size of DARE packages and chunks sealed with a custom cipher.AEAD
+const decryptedBlockSize = 64 << 10 // 64 KB
+
+// DefaultReadCacheBlockSize is the default size of blocks cached by the read cache
+const DefaultReadCacheBlockSize = 4 << 10 // 4 KB
+
+// EnableReadCache makes the Buffer cache up to blocks recently read blocks of the temp file for ReadAt.
+// It benefits workloads that re-read hot regions (for example, a header of a large object). Blocks
+// are aligned to blockSize. If blockSize <= 0, DefaultReadCacheBlockSize is used. Least recently used
+// blocks are evicted first. The cache is disabled if blocks is 0.
+//
+// The cache contains plain data, so it is useful for encrypted Buffers too. It isn't used when the temp
+// file is mapped into memory (see EnableMmap)
+func (b *Buffer) EnableReadCache(blockSize, blocks int) {
+	if blockSize <= 0 {
+		blockSize = DefaultReadCacheBlockSize
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.readCacheBlockSize = blockSize
+	b.readCacheBlocks = blocks
+}
+
+// EnableDecryptedBlockCache makes the Buffer cache up to blocks decrypted blocks of 64 KB for ReadAt.
+// Random ReadAt calls over an encrypted temp file decrypt overlapping blocks repeatedly. The cache
+// allows range-heavy workloads (HTTP Range requests, reading of zip central directory) to avoid it.
+// Least recently used blocks are evicted first. The cache is disabled if blocks is 0
+func (b *Buffer) EnableDecryptedBlockCache(blocks int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.decryptedBlockCacheSize = blocks
+}
+
+// cachedReaderAt caches blocks of data read from the underlying io.ReaderAt. Blocks are aligned
+// to blockSize. It is safe for concurrent use
+type cachedReaderAt struct {
+	r         io.ReaderAt
+	blockSize int64
+
+	mu    sync.Mutex
+	cache *lruCache
+}
+
+func newCachedReaderAt(r io.ReaderAt, blockSize int, blocks int) *cachedReaderAt {
+	return &cachedReaderAt{
+		r:         r,
+		blockSize: int64(blockSize),
+		cache:     newLRUCache(blocks),
+	}
+}
+
+func (cr *cachedReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		idx := off / cr.blockSize
+		block, err := cr.block(idx)
+		if err != nil {
+			return n, err
+		}
+
+		blockOff := off - idx*cr.blockSize
+		if blockOff >= int64(len(block)) {
+			return n, io.EOF
+		}
+
+		copied := copy(p[n:], block[blockOff:])
+		n += copied
+		off += int64(copied)
+	}
+
+	return n, nil
+}
+
+// block returns the block with index idx. The last block can be shorter than blockSize
+func (cr *cachedReaderAt) block(idx int64) ([]byte, error) {
+	cr.mu.Lock()
+	block, ok := cr.cache.get(idx)
+	cr.mu.Unlock()
+	if ok {
+		return block, nil
+	}
+
+	// Read the block without the lock, so other blocks can be read in parallel
+	block = make([]byte, cr.blockSize)
+	n, err := cr.r.ReadAt(block, idx*cr.blockSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	block = block[:n]
+
+	cr.mu.Lock()
+	cr.cache.add(idx, block)
+	cr.mu.Unlock()
+
+	return block, nil
+}
+
+// lruCache is a cache of blocks with LRU eviction. It isn't thread-safe
+type lruCache struct {
+	capacity int
+	// blocks contains *lruEntry. The most recently used blocks are at the front
+	blocks *list.List
+	index  map[int64]*list.Element
+}
+
+type lruEntry struct {
+	idx   int64
+	block []byte
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		blocks:   list.New(),
+		index:    make(map[int64]*list.Element, capacity),
+	}
+}
+
+func (c *lruCache) get(idx int64) ([]byte, bool) {
+	elem, ok := c.index[idx]
+	if !ok {
+		return nil, false
+	}
+	c.blocks.MoveToFront(elem)
+	return elem.Value.(*lruEntry).block, true
+}
+
+func (c *lruCache) add(idx int64, block []byte) {
+	if elem, ok := c.index[idx]; ok {
+		// The block was read by another goroutine
+		c.blocks.MoveToFront(elem)
+		return
+	}
+
+	c.index[idx] = c.blocks.PushFront(&lruEntry{idx: idx, block: block})
+	for c.blocks.Len() > c.capacity {
+		last := c.blocks.Back()
+		c.blocks.Remove(last)
+		delete(c.index, last.Value.(*lruEntry).idx)
+	}
+}
@@ -0,0 +1,141 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingReaderAt counts calls of ReadAt
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt64(&c.calls, 1)
+	return c.r.ReadAt(p, off)
+}
+
+func TestCachedReaderAt(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(1000))
+	r := &countingReaderAt{r: bytes.NewReader(slice)}
+	cr := newCachedReaderAt(r, 100, 2)
+
+	data := make([]byte, 150)
+	for i := 0; i < 3; i++ {
+		n, err := cr.ReadAt(data, 50)
+		require.Nil(err)
+		require.Equal(150, n)
+		require.Equal(slice[50:200], data)
+	}
+	// Blocks 0 and 1 are cached
+	require.Equal(int64(2), r.calls)
+
+	// Block 0 is evicted
+	_, err := cr.ReadAt(data[:10], 250)
+	require.Nil(err)
+	_, err = cr.ReadAt(data[:10], 150)
+	require.Nil(err)
+	require.Equal(int64(3), r.calls)
+	_, err = cr.ReadAt(data[:10], 0)
+	require.Nil(err)
+	require.Equal(int64(4), r.calls)
+
+	// End of data
+	n, err := cr.ReadAt(data, 900)
+	require.Equal(io.EOF, err)
+	require.Equal(100, n)
+	require.Equal(slice[900:], data[:n])
+
+	_, err = cr.ReadAt(data, 1000)
+	require.Equal(io.EOF, err)
+}
+
+func TestBuffer_DecryptedBlockCache(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(1 << 19))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+
+	require.Nil(b.EnableEncryption())
+	b.EnableDecryptedBlockCache(2)
+
+	writeByChunks(require, b, slice, 4096)
+
+	for i := 0; i < 200; i++ {
+		off := rand.Intn(len(slice))
+		data := make([]byte, rand.Intn(1<<17)+1)
+
+		n, err := b.ReadAt(data, int64(off))
+		if off+len(data) > len(slice) {
+			require.Equal(io.EOF, err)
+		} else {
+			require.Nil(err)
+		}
+		require.Equal(slice[off:off+n], data[:n])
+	}
+
+	res := readByChunks(require, b, 4096)
+	require.Equal(slice, res, "wrong content was read")
+}
+
+func TestBuffer_ReadCache(t *testing.T) {
+	tests := []struct {
+		desc      string
+		encrypt   bool
+		checksums bool
+	}{
+		{desc: "Plain"},
+		{desc: "With encryption", encrypt: true},
+		{desc: "With checksums", checksums: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			slice := []byte(generateRandomString(1 << 18))
+
+			b := NewBufferWithMaxMemorySize(1000)
+			defer b.Reset()
+
+			if tt.encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			if tt.checksums {
+				b.EnableChecksums()
+			}
+			b.EnableReadCache(0, 4)
+
+			writeByChunks(require, b, slice, 4096)
+
+			for i := 0; i < 200; i++ {
+				// Re-read the hot region from time to time
+				off := rand.Intn(len(slice))
+				if i%2 == 0 {
+					off = rand.Intn(DefaultReadCacheBlockSize * 2)
+				}
+				data := make([]byte, rand.Intn(1<<14)+1)
+
+				n, err := b.ReadAt(data, int64(off))
+				if off+len(data) > len(slice) {
+					require.Equal(io.EOF, err)
+				} else {
+					require.Nil(err)
+				}
+				require.Equal(slice[off:off+n], data[:n])
+			}
+		})
+	}
+}
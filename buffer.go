@@ -1,23 +1,34 @@
 package buffer
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/rand"
+	"crypto/cipher"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode/utf8"
 
-	"github.com/minio/sio"
 	"github.com/pkg/errors"
 )
 
 const (
 	// DefaultMaxMemorySize is used when Buffer is created with NewBuffer() or NewBufferString()
 	DefaultMaxMemorySize = 2 << 20 // 2 MB
+
+	// jumboWriteFactor defines jumbo writes: writes of at least jumboWriteFactor * maxInMemorySize
+	// bytes skip memory and go straight to the temp file
+	jumboWriteFactor = 16
+
+	// inlineMemorySize is the size of the array inside Buffer used for small data
+	inlineMemorySize = 64
 )
 
 // ErrBufferFinished is used when Buffer.Write() method is called after Buffer.Read()
@@ -25,30 +36,227 @@ var ErrBufferFinished = errors.New("buffer is finished")
 
 // Buffer is a buffer which can store data on a disk. It isn't thread-safe!
 type Buffer struct {
+	// mu guards the state of the Buffer. It allows to access the Buffer from helpers
+	// that work in background (Manager, for example)
+	mu sync.Mutex
+
 	maxInMemorySize int
+	// initialCapacity is the capacity of the internal buffer allocated on the first write.
+	// maxInMemorySize is used if it is 0
+	initialCapacity int
 
 	writingFinished bool
 	readingFinished bool
 
-	size   int
-	offset int
+	// writeErr is an error that occurred during finishing writing
+	writeErr error
+
+	size   int64
+	offset int64
 
-	// tempFileDir is a directory for temp files. It is empty by default (so, "ioutil.TempFile" uses os.TempDir)
+	// tempFileDir is a directory for temp files. It is empty by default (so, os.TempDir is used)
 	tempFileDir string
 
+	// isolateTempDir makes the Buffer create its own subdirectory in tempFileDir for temp files
+	isolateTempDir bool
+	// isolatedDir is a path of the subdirectory created for the Buffer. It is empty if the subdirectory
+	// wasn't created yet
+	isolatedDir string
+
+	// quota limits the total size of temp files of all Buffers that share it
+	quota *Quota
+	// quotaUsed is the amount of space acquired from the quota
+	quotaUsed int64
+	// memoryBudget limits the total size of memory of all Buffers that share it
+	memoryBudget *MemoryBudget
+	// memoryBudgetUsed is the amount of memory acquired from the budget
+	memoryBudgetUsed int64
+
+	// manager is the Manager that created the Buffer
+	manager *Manager
+	// tracked is true when the Buffer is registered in the manager
+	tracked bool
+	// createdAt is the time the Buffer was registered in the manager
+	createdAt time.Time
+
+	// readAheadChunkSize and readAheadChunks configure prefetching of the temp file.
+	// Prefetching is disabled if readAheadChunks is 0
+	readAheadChunkSize int
+	readAheadChunks    int
+	// pageCacheHints enables posix_fadvise hints for sequential reading of the temp file
+	pageCacheHints bool
+
+	// asyncWriteChunkSize and asyncWriteChunks configure background writing into the temp file.
+	// Background writing is disabled if asyncWriteChunks is 0
+	asyncWriteChunkSize int
+	asyncWriteChunks    int
+
+	// hash is a hash of all written data. It is nil if hashing is disabled
+	hash hash.Hash
+	// readHash is a hash of read data. expectedDigest is compared with it at the end of reading.
+	// Verification is disabled if readHash is nil
+	readHash       hash.Hash
+	expectedDigest []byte
+	// countRecords makes the Buffer count occurrences of recordDelim in written data. records is the count
+	countRecords bool
+	recordDelim  byte
+	records      int64
+	// tee receives all data written into the Buffer. It is nil if the tee is disabled
+	tee io.Writer
+
+	// readCacheBlockSize and readCacheBlocks configure the cache of recently read blocks of the temp file.
+	// The cache is disabled if readCacheBlocks is 0
+	readCacheBlockSize int
+	readCacheBlocks    int
+
+	// tmpfsPolicy defines how to handle a temp dir stored in memory
+	tmpfsPolicy TmpfsPolicy
+
+	// freeSpaceCheck makes the Buffer check free space on a disk before writing. freeSpaceReserve
+	// is the amount of space that must stay free, freeSpaceLargeWrite is the minimal size of writes
+	// that are checked after creation of the temp file
+	freeSpaceCheck      bool
+	freeSpaceReserve    int64
+	freeSpaceLargeWrite int
+
+	// retryPolicy defines how to retry creation of the temp file and writes into it
+	retryPolicy RetryPolicy
+
+	// memoryFallbackSize is the max size of data stored in memory after a failure of the temp file.
+	// The fallback is disabled if it is 0
+	memoryFallbackSize int
+	// diskFailed is true when the Buffer fell back to memory
+	diskFailed bool
+
+	// diskFullPolicy defines what happens when the disk is full. diskFullDirs are alternative
+	// directories for temp files
+	diskFullPolicy       DiskFullPolicy
+	diskFullDirs         []string
+	diskFullWaitInterval time.Duration
+
+	// tempFileStrategy creates, opens and removes temp files. DefaultTempFileStrategy is used if it is nil
+	tempFileStrategy TempFileStrategy
+
+	// sparseFiles makes the Buffer skip blocks of zero bytes instead of writing them
+	sparseFiles bool
+
+	// mmapEnabled makes the Buffer map the temp file into memory for reading
+	mmapEnabled bool
+	// mmapWriteRegionSize is a size of temp file regions mapped into memory for writing.
+	// Writing through mappings is disabled if it is 0
+	mmapWriteRegionSize int
+
+	// lifetimeTimer fires when the max lifetime of the Buffer is exceeded
+	lifetimeTimer *time.Timer
+	// lifetimeGen is used to ignore timers that were stopped after they had fired
+	lifetimeGen uint64
+
 	encrypt       bool
 	encryptionKey [32]byte
+	// encryptionConfig defines the format of data encrypted with sio
+	encryptionConfig EncryptionConfig
+	// decryptedBlockCacheSize is the number of decrypted blocks cached for ReadAt.
+	// The cache is disabled if it is 0
+	decryptedBlockCacheSize int
+	// lockKey makes the Buffer keep the encryption key in lockedKey instead of encryptionKey.
+	// lockedKey is a memory region locked with mlock. It is nil after the key was wiped by Reset
+	lockKey   bool
+	lockedKey []byte
+	// wrappedKey is the encryption key wrapped by a KeyProvider. It is empty if the key isn't wrapped
+	wrappedKey []byte
+	// aead is used for encryption instead of sio if it isn't nil
+	aead cipher.AEAD
+	// sealedHeader reports whether the encrypted temp file ends with a sealed spillHeader.
+	// Files opened with OpenEncrypted or OpenExported don't have it
+	sealedHeader bool
 
 	// buff is used to store data in memory
 	buff bytes.Buffer
+	// writtenMemory is all data stored in memory. It is set when writing is finished and used by ReadAt
+	writtenMemory []byte
+	// sensitive makes the Buffer store data in sensitiveMemory and hide it from dumps
+	sensitive       bool
+	sensitiveMemory *sensitiveMemory
+	// arena provides memory blocks. arenaBlock is the block used by buff
+	arena      *Arena
+	arenaBlock []byte
+	// inline is used to store small data without allocations. inlineUsed reports whether buff uses it
+	inline     [inlineMemorySize]byte
+	inlineUsed bool
+	// runeBuf is used by WriteRune to encode runes without allocations
+	runeBuf [utf8.UTFMax]byte
+	// staging contains small writes that aren't written yet. Staging is disabled if its capacity is 0
+	staging []byte
+
+	// directWrite makes writes skip memory and go straight to the temp file. It is set by ReadFrom
+	// when the size of the source is known to be large
+	directWrite bool
 
 	// writeFile is used to write the data on a disk
 	writeFile io.WriteCloser
+	// writeTempFile is the temp file at the bottom of writeFile. It is nil if the file
+	// can't be replaced during writing
+	writeTempFile *swappableFile
 	// readFile is used to read the data from a disk
 	readFile io.ReadCloser
+	// fdManager limits the number of opened temp files. It is nil if the number isn't limited
+	fdManager *FDManager
+	// readBuf buffers readFile. It is created by ReadBytes and ReadString to search for a delimiter
+	// in blocks. All reads from the file go through readBuf after that
+	readBuf *bufio.Reader
+	// maxTokenSize is the max size of data returned by ReadBytes and ReadString. It isn't limited if it is 0
+	maxTokenSize int
+	// readAtFile is used by ReadAt. It is separate from readFile, so ReadAt doesn't depend
+	// on the read position. readAtMu is held for reading during ReadAt calls and for writing
+	// when readAtFile is replaced or closed
+	readAtFile readerAtCloser
+	readAtMu   sync.RWMutex
 
 	useFile  bool
 	filename string
+	// fileSize is the amount of data written into the temp file
+	fileSize int64
+	// fileDropped is the amount of read data removed from the beginning of the temp file by Compact
+	fileDropped int64
+	// filePunched is the size of the deallocated prefix of the temp file (see PunchHoles)
+	filePunched int64
+	// autoCompactionSize is the min size of read data that triggers compaction. Auto compaction is disabled if it is 0
+	autoCompactionSize int64
+	// keepFile is true when the file doesn't belong to the Buffer and must not be removed
+	keepFile bool
+	// sharedFile is the temp file shared with clones (see Clone). It is nil if the file isn't shared
+	sharedFile *sharedFile
+
+	// spillGroup is a group that stores data of the Buffer in a shared file. groupFile is the data
+	// of the Buffer in the shared file
+	spillGroup *SpillGroup
+	groupFile  *groupFile
+
+	// persistentIndex is the index of records of a persistent Buffer (see OpenPersistent)
+	persistentIndex *persistentIndex
+
+	// unlinkTempFiles is true when temp files must be removed right after creation.
+	// unlinkedFile is the descriptor of the current unlinked file
+	unlinkTempFiles bool
+	unlinkedFile    *os.File
+
+	// lockFiles is true when the temp file must be locked. fileLock holds the lock
+	lockFiles bool
+	fileLock  *os.File
+
+	// spillLostHandler is called when the temp file is lost. spillLostReported is set when it was called
+	spillLostHandler  func(filename string)
+	spillLostReported atomic.Bool
+
+	// mirrorDir is a directory for mirrors of temp files. Mirroring is disabled if it is empty
+	mirrorDir string
+	// mirrorFilename is the name of the mirror of the current temp file
+	mirrorFilename string
+
+	// checksumsEnabled makes the Buffer compute checksums of the temp file
+	checksumsEnabled bool
+	// checksums contains checksums of the current temp file
+	checksums *checksums
 }
 
 // NewBufferWithMaxMemorySize creates a new Buffer with passed maxInMemorySize
@@ -57,9 +265,18 @@ func NewBufferWithMaxMemorySize(maxInMemorySize int) *Buffer {
 		maxInMemorySize: maxInMemorySize,
 	}
 
-	// Grow the internal buffer
-	// TODO: should we use just maxInMemorySize?
-	b.buff.Grow(maxInMemorySize / 2)
+	// The internal buffer is grown on the first write, see growMemory
+	trackLeaks(b)
+
+	return b
+}
+
+// NewBufferWithInitialCapacity creates a new Buffer with passed maxInMemorySize. The internal buffer
+// is allocated with initialCapacity instead of maxInMemorySize. It is useful when the size of data
+// is known in advance: memory isn't wasted, and the spill threshold isn't changed
+func NewBufferWithInitialCapacity(maxInMemorySize, initialCapacity int) *Buffer {
+	b := NewBufferWithMaxMemorySize(maxInMemorySize)
+	b.initialCapacity = initialCapacity
 
 	return b
 }
@@ -88,102 +305,389 @@ func NewBufferString(s string) *Buffer {
 
 // ChangeTempDir changes directory for temp files
 func (b *Buffer) ChangeTempDir(dir string) error {
+	path, err := checkTempDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// Change
+	b.mu.Lock()
+	b.tempFileDir = path
+	b.mu.Unlock()
+
+	return nil
+}
+
+// checkTempDir checks whether dir is a directory and returns its absolute path
+func checkTempDir(dir string) (string, error) {
 	f, err := os.Open(dir)
 	if err != nil {
-		return errors.Wrapf(err, "can't open directory '%s'", dir)
+		return "", errors.Wrapf(err, "can't open directory '%s'", dir)
 	}
 	defer f.Close()
 
 	stats, err := f.Stat()
 	if err != nil {
-		return errors.Wrapf(err, "can't get stats of the directory '%s'", dir)
+		return "", errors.Wrapf(err, "can't get stats of the directory '%s'", dir)
 	}
 	if !stats.IsDir() {
-		return errors.Errorf("'%s' is not a directory", dir)
+		return "", errors.Errorf("'%s' is not a directory", dir)
 	}
 
 	path, err := filepath.Abs(dir)
 	if err != nil {
-		return errors.New("can't get an absolute path")
+		return "", errors.New("can't get an absolute path")
 	}
+	return path, nil
+}
 
-	// Change
-	b.tempFileDir = path
+// SetInitialCapacity sets the capacity of the internal buffer that is allocated on the first write.
+// By default, the whole maxInMemorySize is allocated. A smaller capacity saves memory when most data
+// is expected to be small, but the buffer is copied when it grows. The capacity can't exceed
+// maxInMemorySize. SetInitialCapacity must be called before the first Write
+func (b *Buffer) SetInitialCapacity(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	return nil
+	b.initialCapacity = n
 }
 
-// EnableEncryption enables encryption and generates an encryption key
-func (b *Buffer) EnableEncryption() error {
-	b.encrypt = true
+// EnableIsolatedTempDir makes the Buffer create its own subdirectory in the temp dir
+// for temp files. The subdirectory is removed with all its content on Reset()
+func (b *Buffer) EnableIsolatedTempDir() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	key := make([]byte, len(b.encryptionKey))
-	_, err := rand.Read(key)
-	if err != nil {
-		return errors.Wrap(err, "can't read random data")
-	}
+	b.isolateTempDir = true
+}
 
-	for i := range key {
-		b.encryptionKey[i] = key[i]
-	}
+// SetQuota makes the Buffer share the disk quota q with other Buffers. It must be called
+// before the first Write, otherwise the data that is already stored on a disk isn't counted
+func (b *Buffer) SetQuota(q *Quota) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	return nil
+	b.quota = q
+}
+
+// EnableEncryption enables encryption and generates an encryption key with LocalKeyProvider
+func (b *Buffer) EnableEncryption() error {
+	return b.EnableEncryptionWithKeyProvider(LocalKeyProvider)
 }
 
 // Write writes data into bytes.Buffer while size of the Buffer is less than maxInMemorySize, when size of Buffer is equal to maxInMemorySize, Write creates a temporary file and writes remaining data into this one.
 // Write returns ErrBufferFinished after the call of Buffer.Read(), Buffer.ReadByte() or Buffer.Next()
 func (b *Buffer) Write(data []byte) (n int, err error) {
+	for {
+		b.mu.Lock()
+		written, err := b.write(data[n:])
+		b.mu.Unlock()
+
+		n += written
+		wait, ok := err.(*quotaWaitError)
+		if !ok {
+			return n, err
+		}
+		// Wait for free space with unlocked b.mu, so the Buffer can be read or reset meanwhile
+		wait.quota.wait(wait.n)
+	}
+}
+
+// write is a non-locking version of Write
+func (b *Buffer) write(data []byte) (n int, err error) {
 	if b.writingFinished {
 		return 0, ErrBufferFinished
 	}
+	if cap(b.staging) > 0 {
+		return b.writeStaged(data)
+	}
+	return b.writeData(data)
+}
 
+// writeData writes data into memory or into the temp file
+func (b *Buffer) writeData(data []byte) (n int, err error) {
+	if b.manager != nil && !b.tracked {
+		b.manager.add(b)
+	}
+
+	original := data
 	defer func() {
-		b.size += n
+		b.size += int64(n)
+		b.countRecordsIn(original[:n])
+		if b.hash != nil {
+			b.hash.Write(original[:n])
+		}
+		if b.tee != nil && n > 0 {
+			_, teeErr := b.tee.Write(original[:n])
+			if teeErr != nil && err == nil {
+				err = errors.Wrap(teeErr, "can't write data into tee writer")
+			}
+		}
 	}()
 
-	if !b.useFile {
-		if b.buff.Len()+len(data) <= b.maxInMemorySize {
-			// Just write data into the buffer
-			n, err = b.buff.Write(data)
+	if !b.useFile && !b.diskFailed && !b.directWrite && !b.isJumboWrite(int64(len(data))) {
+		// Fill the buffer at first. Data that straddles the boundary is split: every part
+		// is copied only once, either into the buffer or into the file
+		memoryPart := data
+		if free := b.maxInMemorySize - b.buff.Len(); len(memoryPart) > free {
+			memoryPart = memoryPart[:free]
+		}
+		memoryPart = b.acquireMemory(memoryPart)
+
+		b.growMemory(len(memoryPart))
+		n, err = b.buff.Write(memoryPart)
+		if err != nil || n == len(data) {
 			return
 		}
 
-		// We have to use a file. But fill the buffer at first
+		// We have to use a file. Trim written bytes
+		data = data[n:]
 
-		bound := b.maxInMemorySize - b.buff.Len()
-		n, err = b.buff.Write(data[:bound])
-		if err != nil {
+		// fallthrough
+	}
+
+	if b.diskFailed {
+		n1, err := b.writeToMemoryFallback(data)
+		n += n1
+		return n, err
+	}
+
+	// Write data into the file
+	n1, err := b.writeToFile(data)
+	if _, wait := err.(*quotaWaitError); err != nil && !wait && b.memoryFallbackSize > 0 {
+		n1, err = b.fallbackToMemory(data, n1, err)
+	}
+	n += n1
+	return
+}
+
+// growMemory allocates the internal buffer before a write of size bytes into memory. Small data
+// is stored in the inline array at first. The whole maxInMemorySize is allocated by default, so
+// the buffer isn't copied during growth
+func (b *Buffer) growMemory(size int) {
+	if b.inlineUsed {
+		if b.buff.Len()+size <= len(b.inline) {
 			return
 		}
+		// Move the data out of the inline array
+		data := b.buff.Bytes()
+		b.buff = bytes.Buffer{}
+		b.inlineUsed = false
+		b.growMemory(len(data) + size)
+		b.buff.Write(data)
+		return
+	}
+	if b.buff.Cap() > 0 {
+		return
+	}
+	if b.sensitive {
+		b.growSensitiveMemory()
+		return
+	}
+	if b.growArenaMemory() {
+		return
+	}
+	if size <= len(b.inline) {
+		b.buff = *bytes.NewBuffer(b.inline[:0])
+		b.inlineUsed = true
+		return
+	}
+	b.allocateMemory()
+}
 
-		// Trim written bytes
-		data = data[bound:]
+// allocateMemory allocates the internal buffer on the heap
+func (b *Buffer) allocateMemory() {
+	if b.memoryBudget != nil && b.initialCapacity <= 0 {
+		// The buffer grows with data: the budget counts only stored bytes
+		return
+	}
 
-		b.useFile = true
+	capacity := b.initialCapacity
+	if capacity <= 0 || capacity > b.maxInMemorySize {
+		capacity = b.maxInMemorySize
+	}
+	b.buff.Grow(capacity)
+}
+
+// isJumboWrite reports whether a write of size bytes is much larger than maxInMemorySize.
+// Such writes go straight to the temp file: data that is already stored in memory is still
+// read first, so the order isn't broken
+func (b *Buffer) isJumboWrite(size int64) bool {
+	return b.maxInMemorySize > 0 && size >= jumboWriteFactor*int64(b.maxInMemorySize)
+}
 
-		// Create a temporary file
-		file, err := ioutil.TempFile(b.tempFileDir, "go-disk-buffer-*.tmp")
+// writeToFile writes data into the temp file. The file is created on the first call
+func (b *Buffer) writeToFile(data []byte) (n int, err error) {
+	if b.quota != nil {
+		err = b.quota.acquire(b, int64(len(data)))
 		if err != nil {
-			return n, errors.Wrap(err, "can't create a temp file")
+			return 0, err
 		}
+		defer func() {
+			// Return the unused space
+			b.quota.release(b, int64(len(data)-n))
+			b.quotaUsed += int64(n)
+		}()
+	}
 
-		var writeFile io.WriteCloser = file
-		if b.encrypt {
-			writeFile, err = sio.EncryptWriter(file, sio.Config{Key: b.encryptionKey[:]})
+	if !b.useFile {
+		// The file can be already created by EnableEagerSpill
+		if b.writeFile == nil {
+			err = b.createWriteFile(int64(len(data)))
 			if err != nil {
-				return n, errors.Wrap(err, "can't create an encryption stream")
+				return 0, err
 			}
 		}
-		b.writeFile = writeFile
-		b.filename = file.Name()
+		b.useFile = true
+	} else if b.freeSpaceLargeWrite > 0 && len(data) >= b.freeSpaceLargeWrite {
+		err = b.checkFreeSpace(filepath.Dir(b.filename), int64(len(data)))
+		if err != nil {
+			return 0, err
+		}
+	}
 
-		// fallthrough
+	n, err = b.writeFile.Write(data)
+	b.fileSize += int64(n)
+	if err != nil && IsDiskFull(err) {
+		err = diskFullError(filepath.Dir(b.filename), err)
 	}
+	return n, err
+}
 
-	// Write data into the file
-	n1, err := b.writeFile.Write(data)
-	n += n1
-	return
+// createWriteFile creates a temp file and prepares it for writing. required is the number
+// of bytes that will be written into the file
+func (b *Buffer) createWriteFile(required int64) error {
+	if b.spillGroup != nil {
+		return b.createGroupFile()
+	}
+
+	file, err := b.createTempFile(required)
+	if err != nil {
+		return err
+	}
+
+	var lock *os.File
+	if b.lockFiles {
+		lock, err = lockFile(file.Name())
+		if err != nil {
+			file.Close()
+			b.tempFiles().Remove(file.Name())
+			return err
+		}
+	}
+
+	var unlinked *os.File
+	if b.canUnlinkTempFile() {
+		unlinked, err = unlinkFile(b.tempFiles(), file.Name())
+		if err != nil {
+			file.Close()
+			b.tempFiles().Remove(file.Name())
+			if lock != nil {
+				lock.Close()
+			}
+			return err
+		}
+	}
+
+	mirror, err := b.createMirrorFile()
+	if err != nil {
+		file.Close()
+		b.tempFiles().Remove(file.Name())
+		if lock != nil {
+			lock.Close()
+		}
+		if unlinked != nil {
+			unlinked.Close()
+		}
+		return err
+	}
+
+	err = b.setWriteFile(file, mirror)
+	if err != nil {
+		if mirror != nil {
+			mirror.Close()
+			b.tempFiles().Remove(mirror.Name())
+		}
+		if lock != nil {
+			lock.Close()
+		}
+		if unlinked != nil {
+			unlinked.Close()
+		}
+		return err
+	}
+	b.filename = file.Name()
+	if b.lockFiles {
+		b.setFileLock(lock)
+	}
+	if unlinked != nil {
+		// The previous file is closed by the caller
+		b.unlinkedFile = unlinked
+	}
+	if mirror != nil {
+		b.mirrorFilename = mirror.Name()
+	}
+
+	return nil
+}
+
+// setWriteFile prepares file for writing: it builds a chain of writers over file and sets writeFile.
+// Data written into file is mirrored into mirror if it isn't nil
+func (b *Buffer) setWriteFile(file, mirror *os.File) (err error) {
+	var writeFile io.WriteCloser = file
+	b.writeTempFile = nil
+	switch {
+	case b.mmapWriteRegionSize > 0:
+		writeFile = newMmapWriter(file, b.mmapWriteRegionSize)
+	case b.sparseFiles && !b.encrypt:
+		b.writeTempFile = &swappableFile{File: file}
+		writeFile = newSparseWriter(b.writeTempFile)
+	case b.diskFullPolicy != DiskFullFail:
+		var dirs []string
+		if b.asyncWriteChunks == 0 {
+			dirs = b.diskFullDirs
+		}
+		writeFile = newDiskFullWriter(file, b.tempFiles(), b.diskFullPolicy, b.diskFullWaitInterval, dirs, func(filename string) {
+			b.filename = filename
+			if b.checksums != nil {
+				b.checksums.filename = filename
+			}
+			if b.lockFiles {
+				// The write error is more important than an error of locking
+				b.lockTempFile()
+			}
+			// The file was replaced
+			b.writeTempFile = nil
+		})
+	default:
+		b.writeTempFile = &swappableFile{File: file}
+		writeFile = b.writeTempFile
+	}
+	if mirror != nil {
+		writeFile = newMirrorWriter(writeFile, mirror)
+	}
+	if b.retryPolicy.MaxRetries > 0 {
+		writeFile = newRetryWriter(writeFile, b.retryPolicy)
+	}
+	if b.checksumsEnabled {
+		b.checksums = &checksums{filename: file.Name()}
+		writeFile = newChecksumWriter(writeFile, b.checksums)
+	}
+	b.sealedHeader = b.encrypt
+	if b.encrypt {
+		writeFile, err = b.newSealedWriter(writeFile)
+		if err != nil {
+			file.Close()
+			b.tempFiles().Remove(file.Name())
+			return errors.Wrap(err, "can't create an encryption stream")
+		}
+	}
+	if b.asyncWriteChunks > 0 {
+		writeFile = newAsyncWriter(writeFile, b.asyncWriteChunkSize, b.asyncWriteChunks)
+	}
+	b.writeFile = writeFile
+
+	return nil
 }
 
 // WriteByte writes a single byte.
@@ -197,15 +701,25 @@ func (b *Buffer) WriteByte(c byte) error {
 
 // WriteRune writes a rune.
 //
-// It uses bytes.Buffer and Buffer.Write underhood.
+// It uses Buffer.Write underhood
 func (b *Buffer) WriteRune(r rune) (n int, err error) {
-	tmp := bytes.Buffer{}
-	n, err = tmp.WriteRune(r)
-	if err != nil {
-		return n, err
-	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		// Use the array of the Buffer: a local one escapes to the heap
+		size := utf8.EncodeRune(b.runeBuf[:], r)
+		written, err := b.write(b.runeBuf[n:size])
+		n += written
 
-	return b.Write(tmp.Bytes())
+		wait, ok := err.(*quotaWaitError)
+		if !ok {
+			return n, err
+		}
+		b.mu.Unlock()
+		wait.quota.wait(wait.n)
+		b.mu.Lock()
+	}
 }
 
 // WriteString writes a string
@@ -213,19 +727,49 @@ func (b *Buffer) WriteString(s string) (n int, err error) {
 	return b.Write([]byte(s))
 }
 
+// readFromChunkSize is a size of chunks used by ReadFrom to write data into the temp file
+const readFromChunkSize = 32 << 10 // 32 KB
+
 // ReadFrom reads data from r until EOF and writes it into the Buffer.
+//
+// While data is stored in memory, ReadFrom reads chunks that fit into the remaining memory,
+// so chunks don't straddle the boundary between memory and the temp file. If r has
+// a Len() method (like *bytes.Reader) and reports a jumbo size, data goes straight to the temp file.
+//
+// If r is a *Buffer, its data is moved without streaming: memory is copied at once, and the temp file
+// is moved if possible
 func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	if src, ok := r.(*Buffer); ok {
+		return transferBuffer(b, src)
+	}
+
 	var n int64
 
-	data := make([]byte, 512)
+	if lr, ok := r.(interface{ Len() int }); ok {
+		b.mu.Lock()
+		b.directWrite = b.isJumboWrite(int64(lr.Len()))
+		b.mu.Unlock()
+
+		defer func() {
+			b.mu.Lock()
+			b.directWrite = false
+			b.mu.Unlock()
+		}()
+	}
+
+	data := make([]byte, readFromChunkSize)
 	for {
-		rN, rErr := r.Read(data)
+		chunk := data
+		if free := b.freeMemory(); free > 0 && free < len(chunk) {
+			chunk = chunk[:free]
+		}
+
+		rN, rErr := r.Read(chunk)
 		if rErr != nil && rErr != io.EOF {
 			return n, errors.Wrap(rErr, "can't read data from passed io.Reader")
 		}
 
-		data = data[:rN]
-		wN, wErr := b.Write(data)
+		wN, wErr := b.Write(chunk[:rN])
 		if wErr != nil {
 			return n + int64(wN), errors.Wrap(wErr, "can't write data")
 		}
@@ -234,42 +778,56 @@ func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
 		if rErr == io.EOF {
 			return n, nil
 		}
+	}
+}
 
-		data = data[:cap(data)]
+// freeMemory returns the number of bytes that can be written into memory before the Buffer
+// starts to use the temp file
+func (b *Buffer) freeMemory() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.useFile || b.diskFailed || b.directWrite {
+		return 0
 	}
+	return b.maxInMemorySize - b.buff.Len()
 }
 
 // Read reads data from bytes.Buffer or from a file. A temp file is deleted when Read() encounter n == 0
 func (b *Buffer) Read(data []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.read(data)
+}
+
+// read is a non-locking version of Read
+func (b *Buffer) read(data []byte) (n int, err error) {
 	if b.readingFinished {
 		return 0, io.EOF
 	}
 
-	if !b.writingFinished {
-		// Finish writing and close Write&Read file if needed
-		if b.writeFile != nil {
-			b.writeFile.Close()
-			b.writeFile = nil
-		}
-		b.writingFinished = true
+	// Finish writing and close Write&Read file if needed
+	err = b.finishWriting()
+	if err != nil {
+		return 0, err
 	}
 
 	// Check if reading is finished
 	defer func() {
-		b.offset += n
-
-		// If n is less than size of data slice, reading is finished
-		if n < len(data) {
-			b.readingFinished = true
-		}
-
-		if b.readingFinished && b.readFile != nil {
-			// Can close the file
-			b.readFile.Close()
-			os.Remove(b.filename)
-
-			b.readFile = nil
-			b.filename = ""
+		b.offset += int64(n)
+		b.hashRead(data[:n])
+
+		// Reading is finished when all data was read or the file ended. Short reads don't mean
+		// the end of the data: the file layer can return less than requested. The temp file is
+		// removed right after the last data is read
+		if b.readingFinished || b.offset >= b.size || err == io.EOF {
+			finishErr := b.finishReading()
+			if finishErr != nil && (err == nil || err == io.EOF) {
+				err = finishErr
+			}
+		} else if b.autoCompactionSize > 0 && n > 0 {
+			b.autoCompact()
 		}
 	}()
 
@@ -281,14 +839,11 @@ func (b *Buffer) Read(data []byte) (n int, err error) {
 			return
 		}
 
-		// Can use the file to fill the slice
+		// Can use the file to fill the slice. Read the data directly into the remaining part
+		// of the slice to avoid allocations and extra copies
 
 		var n1 int
-
-		temp := make([]byte, len(data)-n)
-		n1, err = b.readFromFile(temp)
-		temp = temp[:n1]
-		copy(data[n:], temp)
+		n1, err = b.readFromFile(data[n:])
 
 		n += n1
 		return
@@ -307,6 +862,13 @@ func (b *Buffer) Read(data []byte) (n int, err error) {
 	return
 }
 
+// ReadAt reads len(data) bytes starting at offset off from the beginning of the written data.
+// It doesn't depend on the read position and doesn't change it. ReadAt is safe for concurrent use: multiple goroutines can call ReadAt in parallel, reads
+// from the temp file don't share any state.
+//
+// ReadAt doesn't finish writing if data is stored as is (without encryption, checksums, async writing, etc.):
+// it serves the data written so far and returns io.EOF beyond it. So, data can be served while it is still
+// being written
 func (b *Buffer) ReadAt(data []byte, off int64) (n int, err error) {
 	// Input validation
 	if off < 0 {
@@ -315,109 +877,370 @@ func (b *Buffer) ReadAt(data []byte, off int64) (n int, err error) {
 	if len(data) == 0 {
 		return 0, nil
 	}
-	if off >= int64(b.size) {
+
+	b.mu.Lock()
+	if off >= b.size {
+		b.mu.Unlock()
 		return 0, io.EOF
 	}
 
-	// Ensure writing is finished before reading
-	if !b.writingFinished {
-		if b.writeFile != nil {
-			b.writeFile.Close()
-			b.writeFile = nil
-		}
-		b.writingFinished = true
+	memory, file, err := b.prepareReadAt()
+	if err != nil {
+		b.mu.Unlock()
+		return 0, err
 	}
+	var (
+		start            = off
+		size             = b.size
+		fileDropped      = b.fileDropped
+		filePunched      = b.filePunched
+		spillLostHandler = b.spillLostHandler
+		filename         = b.filename
+	)
+
+	// Lock readAtMu before unlocking mu, so the temp file can't be closed during reading
+	b.readAtMu.RLock()
+	defer b.readAtMu.RUnlock()
+	b.mu.Unlock()
 
-	bufferSize := b.buff.Len()
 	totalBytesToRead := len(data)
 	bytesRead := 0
+	// Don't read beyond the written data: the temp file can be preallocated or still being written
+	if left := size - off; int64(len(data)) > left {
+		data = data[:left]
+	}
 
-	// Case 1: Read starts and ends within buffer
-	if off < int64(bufferSize) {
-		// Calculate how much we can read from buffer
-		bufferBytesToRead := bufferSize - int(off)
-		if bufferBytesToRead > totalBytesToRead {
-			bufferBytesToRead = totalBytesToRead
-		}
-
-		// Read from buffer
-		bufferReader := bytes.NewReader(b.buff.Bytes())
-		n, err := bufferReader.ReadAt(data[:bufferBytesToRead], off)
-		if err != nil && err != io.EOF {
-			return n, err
-		}
+	// Case 1: Read starts within buffer
+	if off < int64(len(memory)) {
+		n := copy(data, memory[off:])
 		bytesRead += n
 		data = data[n:]
 		off += int64(n)
 	}
 
 	// Case 2: Read from file if there's more data needed and we use a file
-	if len(data) > 0 && b.useFile {
-		// Open file if not already open
-		if b.readFile == nil {
-			file, err := os.Open(b.filename)
-			if err != nil {
-				return bytesRead, errors.Wrapf(err, "can't open a temp file '%s'", b.filename)
-			}
-
-			var readFile io.ReadCloser = file
-			if b.encrypt {
-				reader, err := sio.DecryptReaderAt(file, sio.Config{Key: b.encryptionKey[:]})
-				if err != nil {
-					return bytesRead, errors.Wrap(err, "can't create a decryption stream")
-				}
-				readFile = newSioDecryptReaderAtWrapper(reader, file)
-			}
-			b.readFile = readFile
+	if len(data) > 0 && file != nil {
+		fileOffset := off - int64(len(memory)) - fileDropped
+		if fileOffset < filePunched {
+			return bytesRead, ErrCompacted
 		}
-
-		// Read from file
-		if readerAt, ok := b.readFile.(io.ReaderAt); ok {
-			fileOffset := off - int64(bufferSize)
-			n, err := readerAt.ReadAt(data, fileOffset)
-			bytesRead += n
-			if err != nil && err != io.EOF {
-				return bytesRead, err
-			}
-		} else {
-			return bytesRead, fmt.Errorf("readFile does not implement io.ReaderAt")
+		n, err := file.ReadAt(data, fileOffset)
+		bytesRead += n
+		if err != nil && err != io.EOF {
+			return bytesRead, err
 		}
 	}
 
 	// Return EOF if we've read less than requested (end of buffer/file)
 	if bytesRead < totalBytesToRead {
+		if start+int64(bytesRead) < size {
+			return bytesRead, b.spillLost(spillLostHandler, filename, "was truncated")
+		}
 		return bytesRead, io.EOF
 	}
 
 	return bytesRead, nil
 }
 
-func (b *Buffer) readFromBuffer(data []byte) (n int, err error) {
-	return b.buff.Read(data)
+// prepareReadAt finishes writing if needed and opens the temp file for ReadAt. It returns data
+// stored in memory and the temp file. The file is nil if the Buffer doesn't use a file
+func (b *Buffer) prepareReadAt() (memory []byte, file io.ReaderAt, err error) {
+	if b.canReadAtWhileWriting() {
+		err = b.flushStaging()
+		if err != nil {
+			return nil, nil, err
+		}
+		// Serve the written data. The data in memory isn't modified during writing: it is only appended
+		memory = b.buff.Bytes()
+	} else {
+		// Ensure writing is finished before reading
+		err = b.finishWriting()
+		if err != nil {
+			return nil, nil, err
+		}
+		memory = b.writtenMemory
+	}
+
+	if !b.useFile {
+		return memory, nil, nil
+	}
+
+	if b.readAtFile == nil {
+		readAtFile, err := b.openReadAtFile()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		b.readAtMu.Lock()
+		b.readAtFile = readAtFile
+		b.readAtMu.Unlock()
+	}
+
+	return memory, b.readAtFile, nil
 }
 
-func (b *Buffer) readFromFile(data []byte) (n int, err error) {
-	if b.readFile == nil {
-		file, err := os.Open(b.filename)
+// canReadAtWhileWriting reports whether ReadAt can serve the written data without finishing writing.
+// Written data must reach the temp file as is and the file must not be replaced
+func (b *Buffer) canReadAtWhileWriting() bool {
+	switch {
+	case b.writingFinished, b.writeErr != nil, b.diskFailed:
+		return false
+	case b.encrypt, b.checksumsEnabled, b.asyncWriteChunks > 0, b.sparseFiles, b.diskFullPolicy != DiskFullFail:
+		return false
+	case b.mmapEnabled, b.mmapWriteRegionSize > 0, b.readCacheBlocks > 0, b.spillGroup != nil, b.persistentIndex != nil:
+		return false
+	}
+	return true
+}
+
+// openReadAtFile opens the temp file for ReadAt. The returned reader is safe for concurrent use
+func (b *Buffer) openReadAtFile() (readerAtCloser, error) {
+	if b.useMmap() {
+		return newMmapReader(b.tempFiles(), b.filename)
+	}
+
+	file, err := b.openTempFile()
+	if err != nil {
+		return nil, err
+	}
+	if b.persistentIndex != nil {
+		return newPersistentReader(file, b.persistentIndex), nil
+	}
+
+	var src io.ReaderAt = file
+	if b.checksums != nil {
+		src = newChecksumReaderAt(file, b.checksums)
+	}
+	if b.encrypt {
+		size, err := b.encryptedDataSize(file)
 		if err != nil {
-			return 0, errors.Wrapf(err, "can't open a temp file '%s'", b.filename)
+			file.Close()
+			return nil, err
+		}
+		src, err = b.newDecryptReaderAt(io.NewSectionReader(src, 0, size), size)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "can't create a decryption stream")
+		}
+		if b.decryptedBlockCacheSize > 0 {
+			src = newCachedReaderAt(src, decryptedBlockSize, b.decryptedBlockCacheSize)
+		}
+	}
+	if b.readCacheBlocks > 0 {
+		src = newCachedReaderAt(src, b.readCacheBlockSize, b.readCacheBlocks)
+	}
+
+	return newFileReaderAt(src, file), nil
+}
+
+// closeReadAtFile closes the temp file opened for ReadAt. It waits for running ReadAt calls
+func (b *Buffer) closeReadAtFile() {
+	b.readAtMu.Lock()
+	defer b.readAtMu.Unlock()
+
+	if b.readAtFile != nil {
+		b.readAtFile.Close()
+		b.readAtFile = nil
+	}
+}
+
+// finishWriting closes the temp file opened for writing. It must be called before reading.
+// An error that occurred during closing is returned on every call till Reset()
+func (b *Buffer) finishWriting() error {
+	if !b.writingFinished {
+		if err := b.flushStaging(); err != nil {
+			b.writeErr = err
 		}
+		b.writingFinished = true
+		// The data in memory isn't modified after writing. Sequential reads only move the offset of buff
+		b.writtenMemory = b.buff.Bytes()
 
-		var readFile io.ReadCloser = file
-		if b.encrypt {
-			reader, err := sio.DecryptReader(file, sio.Config{Key: b.encryptionKey[:]})
+		if b.writeFile != nil {
+			err := b.writeFile.Close()
+			b.writeFile = nil
+			b.writeTempFile = nil
 			if err != nil {
-				return 0, errors.Wrap(err, "can't create a decryption stream")
+				b.writeErr = errors.Wrap(err, "can't finish writing into a temp file")
 			}
-			readFile = newSioDecryptReaderWrapper(reader, file)
 		}
+		if !b.useFile && b.filename != "" {
+			// The file was created by EnableEagerSpill, but wasn't used
+			b.removeTempFile()
+		}
+	}
+
+	return b.writeErr
+}
 
-		b.readFile = readFile
+func (b *Buffer) readFromBuffer(data []byte) (n int, err error) {
+	return b.buff.Read(data)
+}
+
+func (b *Buffer) readFromFile(data []byte) (n int, err error) {
+	err = b.prepareReadFile()
+	if err != nil {
+		return 0, err
 	}
 
+	if b.readBuf != nil {
+		// Fill data fully: bufio.Reader returns only buffered data. The end of the data is
+		// detected by the read position
+		n, err = io.ReadFull(b.readBuf, data)
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		return n, err
+	}
 	return b.readFile.Read(data)
 }
 
+// prepareReadFile opens readFile if it isn't opened yet. If the file was already read
+// (and closed by reopenReadFile or FDManager), the read position is restored
+func (b *Buffer) prepareReadFile() error {
+	if b.readFile != nil {
+		if b.fdManager != nil {
+			b.fdManager.touch(b)
+		}
+		return nil
+	}
+
+	readFile, err := b.openReadFile()
+	if err != nil {
+		return err
+	}
+	// fileConsumed is negative if the memory was drained during the current Read call
+	if fileConsumed := b.fileConsumed() - b.fileDropped; fileConsumed > 0 {
+		if _, err := io.CopyN(ioutil.Discard, readFile, fileConsumed); err != nil {
+			readFile.Close()
+			if err == io.EOF {
+				return b.spillLost(b.spillLostHandler, b.filename, "was truncated")
+			}
+			return errors.Wrap(err, "can't restore the read position")
+		}
+	}
+	if b.readAheadChunks > 0 && !b.useMmap() {
+		readFile = newReadAheadReader(readFile, b.readAheadChunkSize, b.readAheadChunks)
+	}
+	b.readFile = readFile
+
+	if b.fdManager != nil {
+		b.fdManager.opened(b)
+	}
+	return nil
+}
+
+// closeReadFile closes readFile if it is opened
+func (b *Buffer) closeReadFile() {
+	if b.readFile == nil {
+		return
+	}
+
+	b.dropReadFile()
+	if b.fdManager != nil {
+		b.fdManager.closed(b)
+	}
+}
+
+// dropReadFile closes readFile. Buffered data is discarded: the read position is restored
+// from the offset when the file is opened again
+func (b *Buffer) dropReadFile() {
+	b.readFile.Close()
+	b.readFile = nil
+	b.readBuf = nil
+}
+
+// finishReading marks reading as finished and removes the temp file. It returns an error
+// if the digest of read data doesn't match the expected one
+func (b *Buffer) finishReading() error {
+	if !b.readingFinished && b.useFile && b.offset < b.size {
+		// Reading isn't finished: the file ended before all data was read
+		return b.spillLost(b.spillLostHandler, b.filename, "was truncated")
+	}
+
+	// Verify the digest only once
+	verify := !b.readingFinished
+
+	b.readingFinished = true
+	if b.readFile != nil {
+		// Can close the file
+		b.closeReadFile()
+		b.removeTempFile()
+	}
+
+	if !verify {
+		return nil
+	}
+	return b.verifyDigest()
+}
+
+// finishReadingIfDrained finishes reading if all data was read. It allows to remove the temp file
+// right after the last data is read instead of on the next read that returns io.EOF
+func (b *Buffer) finishReadingIfDrained() error {
+	if b.readingFinished || b.offset < b.size {
+		return nil
+	}
+	return b.finishReading()
+}
+
+// openReadFile opens the temp file for sequential reading
+func (b *Buffer) openReadFile() (io.ReadCloser, error) {
+	if b.useMmap() {
+		return newMmapReader(b.tempFiles(), b.filename)
+	}
+
+	file, err := b.openSequentialTempFile()
+	if err != nil {
+		return nil, err
+	}
+	if b.pageCacheHints {
+		file = newAdvisedFile(file)
+	}
+	if b.persistentIndex != nil {
+		return newPersistentReader(file, b.persistentIndex), nil
+	}
+
+	var (
+		readFile io.ReadCloser = file
+		src      io.Reader     = file
+	)
+	if b.checksums != nil {
+		src = newChecksumReader(file, b.checksums)
+		readFile = newReadCloser(src, file)
+	}
+	if b.encrypt {
+		size, err := b.encryptedDataSize(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		reader, err := b.newDecryptReader(io.LimitReader(src, size))
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "can't create a decryption stream")
+		}
+		readFile = newReadCloser(reader, file)
+	}
+
+	return readFile, nil
+}
+
+// reopenReadFile reopens the temp file for reading and restores the read position.
+// It must be called after the temp file was replaced
+func (b *Buffer) reopenReadFile() error {
+	if b.readFile == nil {
+		return nil
+	}
+
+	b.closeReadFile()
+	if b.fileConsumed() == b.fileDropped {
+		// The file will be opened on the next read
+		return nil
+	}
+
+	// Open the new file and skip already read data
+	return b.prepareReadFile()
+}
+
 // ReadByte reads a single byte.
 //
 // It uses Buffer.Read underhood
@@ -431,30 +1254,116 @@ func (b *Buffer) ReadByte() (byte, error) {
 // returning a slice containing the data up to and including the delimiter.
 // If ReadBytes encounters an error before finding a delimiter,
 // it returns the data read before the error and the error itself (often io.EOF).
+// ReadBytes returns ErrTokenTooLong if the data exceeds the max token size (see SetMaxTokenSize)
 func (b *Buffer) ReadBytes(delim byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	var result []byte
+	err := b.readUntil(delim, func(p []byte) {
+		result = append(result, p...)
+	})
+	return result, err
+}
 
-	for {
-		c, err := b.ReadByte()
+// ReadString reads until the first occurrence of delim in the input,
+// returning a string containing the data up to and including the delimiter.
+// If ReadString encounters an error before finding a delimiter,
+// it returns the data read before the error and the error itself (often io.EOF).
+// ReadString returns ErrTokenTooLong if the data exceeds the max token size (see SetMaxTokenSize)
+func (b *Buffer) ReadString(delim byte) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result strings.Builder
+	err := b.readUntil(delim, func(p []byte) {
+		result.Write(p)
+	})
+	return result.String(), err
+}
+
+// readUntil reads data until the first occurrence of delim and passes it to fn in blocks.
+// The passed slices are valid only during the call of fn. It returns io.EOF if delim wasn't found
+// and ErrTokenTooLong if delim wasn't found within maxTokenSize
+func (b *Buffer) readUntil(delim byte, fn func(p []byte)) error {
+	if b.readingFinished {
+		return io.EOF
+	}
+
+	err := b.finishWriting()
+	if err != nil {
+		return err
+	}
+
+	var read int
+	// next passes the data till delim (if it is found) to fn and returns the number of consumed bytes
+	next := func(data []byte) (consumed int, found bool, err error) {
+		consumed = len(data)
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			consumed, found = i+1, true
+		}
+		if b.maxTokenSize > 0 && read+consumed > b.maxTokenSize {
+			consumed, found, err = b.maxTokenSize-read, false, ErrTokenTooLong
+		}
+		read += consumed
+
+		fn(data[:consumed])
+		b.hashRead(data[:consumed])
+		b.offset += int64(consumed)
+		return consumed, found, err
+	}
+
+	if b.buff.Len() != 0 {
+		consumed, found, err := next(b.buff.Bytes())
+		b.buff.Next(consumed)
 		if err != nil {
-			return result, err
+			return err
+		}
+		if found {
+			return b.finishReadingIfDrained()
 		}
+	}
 
-		result = append(result, c)
+	if !b.useFile {
+		err := b.finishReading()
+		if err != nil {
+			return err
+		}
+		return io.EOF
+	}
 
-		if c == delim {
-			return result, nil
+	if b.readBuf == nil {
+		err := b.prepareReadFile()
+		if err != nil {
+			return err
 		}
+		b.readBuf = bufio.NewReader(b.readFile)
 	}
-}
 
-// ReadString reads until the first occurrence of delim in the input,
-// returning a string containing the data up to and including the delimiter.
-// If ReadString encounters an error before finding a delimiter,
-// it returns the data read before the error and the error itself (often io.EOF).
-func (b *Buffer) ReadString(delim byte) (string, error) {
-	bytes, err := b.ReadBytes(delim)
-	return string(bytes), err
+	for {
+		// Fill the buffer of readBuf if it is empty
+		_, err := b.readBuf.Peek(1)
+		if err == io.EOF {
+			err := b.finishReading()
+			if err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+
+		buffered, _ := b.readBuf.Peek(b.readBuf.Buffered())
+		consumed, found, err := next(buffered)
+		b.readBuf.Discard(consumed)
+		if err != nil {
+			return err
+		}
+		if found {
+			return b.finishReadingIfDrained()
+		}
+	}
 }
 
 // ReadRune reads a single UTF-8 encoded Unicode character and returns the
@@ -481,17 +1390,25 @@ func (b *Buffer) ReadRune() (r rune, size int, err error) {
 // Next returns a slice containing the next n bytes from the buffer.
 // If an error occurred, it panics
 func (b *Buffer) Next(n int) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	slice := make([]byte, n)
 	n, err := b.buff.Read(slice)
 	if err != nil {
 		panic(err)
 	}
 	slice = slice[:n]
+	b.hashRead(slice)
 	return slice
 }
 
 // WriteTo writes data to w until the buffer is drained or an error occurs.
 func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	if dst, ok := w.(*Buffer); ok {
+		return transferBuffer(dst, b)
+	}
+
 	var n int64
 
 	data := make([]byte, 512)
@@ -516,9 +1433,31 @@ func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
 	}
 }
 
-// Len returns the number of bytes of the unread portion of the buffer
+// Len returns the number of bytes of the unread portion of the buffer. Use Len64 for Buffers
+// larger than 2 GB on 32-bit platforms
 func (b *Buffer) Len() int {
-	return b.size - b.offset
+	return int(b.Len64())
+}
+
+// Len64 returns the number of bytes of the unread portion of the buffer
+func (b *Buffer) Len64() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.unreadLen()
+}
+
+// unreadLen is a non-locking version of Len64
+func (b *Buffer) unreadLen() int64 {
+	return b.size + int64(len(b.staging)) - b.offset
+}
+
+// Size returns the total number of bytes written into the buffer
+func (b *Buffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size + int64(len(b.staging))
 }
 
 // Cap is equal to Buffer.Len()
@@ -528,81 +1467,216 @@ func (b *Buffer) Cap() int {
 
 // Reset resets buffer and remove file if needed
 func (b *Buffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reset()
+}
+
+// reset is a non-locking version of Reset
+func (b *Buffer) reset() {
+	// Wait for running ReadAt calls before modifying the memory
+	b.closeReadAtFile()
 	b.buff.Reset()
+	b.writtenMemory = nil
+	b.wipeMemory()
+	b.releaseArenaMemory()
+	b.releaseMemory()
+	wipe(b.staging)
+	b.staging = b.staging[:0]
 
 	if b.writeFile != nil {
 		b.writeFile.Close()
 	}
-	if b.readFile != nil {
-		b.readFile.Close()
+	b.closeReadFile()
+
+	b.removeTempFile()
+	b.removeIsolatedDir()
+	b.wipeKey()
+
+	if b.manager != nil && b.tracked {
+		b.manager.remove(b)
 	}
 
-	if b.filename != "" {
-		os.Remove(b.filename)
+	b.stopLifetimeTimer()
+
+	if b.hash != nil {
+		b.hash.Reset()
+	}
+	if b.readHash != nil {
+		b.readHash.Reset()
 	}
 
+	b.size = 0
+	b.offset = 0
+	b.spillLostReported.Store(false)
+	b.records = 0
+	b.writeErr = nil
 	b.writingFinished = false
 	b.readingFinished = false
 	b.writeFile = nil
-	b.readFile = nil
+	b.writeTempFile = nil
 	b.useFile = false
+	b.diskFailed = false
+}
+
+// createTempFile creates a new temp file in tempFileDir or in the isolated subdirectory.
+// required is the number of bytes that will be written into the file
+func (b *Buffer) createTempFile(required int64) (*os.File, error) {
+	dir, err := b.tempDir()
+	if err != nil {
+		return nil, err
+	}
+	if b.isolateTempDir {
+		if b.isolatedDir == "" {
+			isolatedDir, err := ioutil.TempDir(dir, "go-disk-buffer-*")
+			if err != nil {
+				if isNoSpace(err) {
+					return b.createTempFileOnFullDisk(dir, required, err)
+				}
+				return nil, errors.Wrap(err, "can't create a temp directory")
+			}
+			b.isolatedDir = isolatedDir
+		}
+		dir = b.isolatedDir
+	}
+
+	file, err := b.createTempFileIn(dir, required)
+	if err != nil && isNoSpace(err) {
+		return b.createTempFileOnFullDisk(dir, required, err)
+	}
+	return file, err
+}
+
+// createTempFileIn creates a new temp file in dir
+func (b *Buffer) createTempFileIn(dir string, required int64) (*os.File, error) {
+	err := b.checkFreeSpace(dir, required)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *os.File
+	err = b.retryPolicy.retry(func() (err error) {
+		file, err = b.tempFiles().Create(dir, "go-disk-buffer-*.tmp")
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create a temp file")
+	}
+	return file, nil
+}
+
+// newUnreadReader returns a reader of the unread data. It doesn't change the read position.
+// Writing must be finished before the call. The returned io.ReadCloser must be closed
+func (b *Buffer) newUnreadReader() (io.ReadCloser, error) {
+	memory := bytes.NewReader(b.buff.Bytes())
+	if b.filename == "" {
+		return ioutil.NopCloser(memory), nil
+	}
+
+	readFile, err := b.openReadFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, readFile, b.fileConsumed()-b.fileDropped); err != nil {
+		readFile.Close()
+		return nil, errors.Wrap(err, "can't skip read data")
+	}
+
+	return newReadCloser(io.MultiReader(memory, readFile), readFile), nil
+}
+
+// fileConsumed returns the number of bytes read from the temp file by Read
+func (b *Buffer) fileConsumed() int64 {
+	var (
+		memoryWritten  = b.size - b.fileSize
+		memoryConsumed = memoryWritten - int64(b.buff.Len())
+	)
+	return b.offset - memoryConsumed
+}
+
+// removeTempFile removes the temp file if it exists and returns the acquired space to the quota.
+// Files that don't belong to the Buffer are kept
+func (b *Buffer) removeTempFile() {
+	b.closeReadAtFile()
+	if b.filename != "" && !b.keepFile {
+		b.tempFiles().Remove(b.filename)
+	}
+	b.releaseSharedFile()
+	b.removeMirrorFile()
+	// Release the lock after the file is removed, so cleanup jobs can't remove the file in use
+	b.unlockTempFile()
+	b.closeUnlinkedFile()
+	b.releaseGroupFile()
+	b.keepFile = false
+	b.persistentIndex = nil
 	b.filename = ""
+	b.fileSize = 0
+	b.fileDropped = 0
+	b.filePunched = 0
+	b.checksums = nil
+
+	if b.quota != nil && b.quotaUsed != 0 {
+		b.quota.release(b, b.quotaUsed)
+	}
+	b.quotaUsed = 0
 }
 
-// sioDecryptReaderWrapper is a wrapper for sio.DecryptReader() function
+// removeIsolatedDir removes the isolated subdirectory with all its content if it exists
+func (b *Buffer) removeIsolatedDir() {
+	if b.isolatedDir != "" {
+		os.RemoveAll(b.isolatedDir)
+	}
+	b.isolatedDir = ""
+}
+
+// readCloser is a wrapper for readers that wrap a file (sio.DecryptReader(), for example)
 // that satisfy io.ReadCloser.
 // It reads from passed io.Reader and closes the original file
-type sioDecryptReaderWrapper struct {
+type readCloser struct {
 	r            io.Reader
-	originalFile *os.File
+	originalFile io.Closer
 }
 
-func newSioDecryptReaderWrapper(r io.Reader, file *os.File) *sioDecryptReaderWrapper {
-	return &sioDecryptReaderWrapper{
+func newReadCloser(r io.Reader, file io.Closer) *readCloser {
+	return &readCloser{
 		r:            r,
 		originalFile: file,
 	}
 }
 
-func (rw *sioDecryptReaderWrapper) Read(p []byte) (int, error) {
+func (rw *readCloser) Read(p []byte) (int, error) {
 	return rw.r.Read(p)
 }
 
-func (rw *sioDecryptReaderWrapper) Close() error {
+func (rw *readCloser) Close() error {
 	return rw.originalFile.Close()
 }
 
-// sioDecryptReaderAtWrapper is a wrapper for sio.DecryptReaderAt() function
-// that satisfies io.ReadCloser and io.ReaderAt.
-// It reads from passed io.ReaderAt and closes the original file
-type sioDecryptReaderAtWrapper struct {
+// readerAtCloser is an io.ReaderAt that must be closed
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// fileReaderAt is a wrapper for readers that wrap a file (sio.DecryptReaderAt(), for example)
+// that satisfies readerAtCloser. It reads from passed io.ReaderAt and closes the original file
+type fileReaderAt struct {
 	r            io.ReaderAt
-	originalFile *os.File
-	offset       int64      // Current read position for sequential Read() calls
-	mu           sync.Mutex // Mutex to protect offset for thread safety
+	originalFile io.Closer
 }
 
-func newSioDecryptReaderAtWrapper(r io.ReaderAt, file *os.File) *sioDecryptReaderAtWrapper {
-	return &sioDecryptReaderAtWrapper{
+func newFileReaderAt(r io.ReaderAt, file io.Closer) *fileReaderAt {
+	return &fileReaderAt{
 		r:            r,
 		originalFile: file,
 	}
 }
 
-func (rw *sioDecryptReaderAtWrapper) Read(p []byte) (int, error) {
-	// Implement sequential reading using ReadAt with internal offset
-	rw.mu.Lock()
-	defer rw.mu.Unlock()
-
-	n, err := rw.r.ReadAt(p, rw.offset)
-	rw.offset += int64(n)
-	return n, err
-}
-
-func (rw *sioDecryptReaderAtWrapper) ReadAt(b []byte, off int64) (n int, err error) {
-	return rw.r.ReadAt(b, off)
+func (fr *fileReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	return fr.r.ReadAt(p, off)
 }
 
-func (rw *sioDecryptReaderAtWrapper) Close() error {
-	return rw.originalFile.Close()
+func (fr *fileReaderAt) Close() error {
+	return fr.originalFile.Close()
 }
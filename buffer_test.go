@@ -3,13 +3,19 @@ package buffer
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,9 +54,16 @@ func TestBuffer_CheckBufferAndFileSize(t *testing.T) {
 		},
 		{
 			maxSize:    20,
-			data:       make([]byte, 1<<20),
+			data:       make([]byte, 20*jumboWriteFactor-1),
 			bufferSize: 20,
-			fileSize:   1<<20 - 20,
+			fileSize:   20*jumboWriteFactor - 21,
+		},
+		// Jumbo write
+		{
+			maxSize:    20,
+			data:       make([]byte, 1<<20),
+			bufferSize: 0,
+			fileSize:   1 << 20,
 		},
 	}
 
@@ -101,6 +114,31 @@ func TestBuffer_CheckBufferAndFileSize(t *testing.T) {
 	}
 }
 
+func TestBuffer_Size(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	data := []byte(generateRandomString(100))
+	writeByChunks(require, b, data, 7)
+	require.Equal(int64(100), b.Size())
+	require.Equal(int64(100), b.Len64())
+
+	res := make([]byte, 30)
+	_, err := b.Read(res)
+	require.Nil(err)
+	require.Equal(int64(100), b.Size())
+	require.Equal(int64(70), b.Len64())
+	require.Equal(70, b.Len())
+
+	// Sizes of large Buffers don't overflow on 32-bit platforms
+	b.size += 5 << 30
+	b.offset += 5 << 30
+	require.Equal(int64(5<<30+100), b.Size())
+	require.Equal(int64(70), b.Len64())
+}
+
 func TestBuffer_WriteAndRead(t *testing.T) {
 	tests := []struct {
 		maxSize       int
@@ -396,6 +434,43 @@ func TestBuffer_ReadString(t *testing.T) {
 	}
 }
 
+func TestBuffer_ReadString_Spilled(t *testing.T) {
+	require := require.New(t)
+
+	var lines []string
+	for _, size := range []int{10, 5000, 1, 20000, 300} {
+		lines = append(lines, generateRandomString(size)+"\n")
+	}
+	tail := generateRandomString(1000)
+	data := strings.Join(lines, "") + tail
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	_, err := b.Write([]byte(data))
+	require.Nil(err)
+
+	for _, line := range lines[:3] {
+		res, err := b.ReadString('\n')
+		require.Nil(err)
+		require.Equal(line, res)
+	}
+
+	// Read must continue from the read position after block reads
+	buf := make([]byte, len(lines[3]))
+	n, err := b.Read(buf)
+	require.Nil(err)
+	require.Equal(lines[3], string(buf[:n]))
+
+	res, err := b.ReadString('\n')
+	require.Nil(err)
+	require.Equal(lines[4], res)
+
+	res, err = b.ReadString('\n')
+	require.Equal(io.EOF, err)
+	require.Equal(tail, res)
+}
+
 func TestBuffer_Next(t *testing.T) {
 	tests := []struct {
 		originalData []byte
@@ -514,6 +589,119 @@ func TestBuffer_ReadFrom(t *testing.T) {
 	}
 }
 
+// sizeRecordingReader records sizes of passed slices
+type sizeRecordingReader struct {
+	r     io.Reader
+	sizes []int
+}
+
+func (r *sizeRecordingReader) Read(p []byte) (int, error) {
+	r.sizes = append(r.sizes, len(p))
+	return r.r.Read(p)
+}
+
+func TestBuffer_ReadFrom_Spill(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(100 << 10))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+
+	_, err := b.Write(slice[:10])
+	require.Nil(err)
+
+	r := &sizeRecordingReader{r: bytes.NewReader(slice[10:])}
+	n, err := b.ReadFrom(r)
+	require.Nil(err)
+	require.Equal(int64(len(slice)-10), n)
+
+	// The first chunk must fill the memory exactly
+	require.Equal(990, r.sizes[0])
+	require.Equal(readFromChunkSize, r.sizes[1])
+
+	res := readByChunks(require, b, 1024)
+	require.Equal(slice, res, "wrong content was read")
+}
+
+func TestBuffer_ReadFrom_Jumbo(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(100 << 10))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+
+	_, err := b.Write(slice[:10])
+	require.Nil(err)
+
+	// The size of bytes.Reader is known, so data must skip memory
+	n, err := b.ReadFrom(bytes.NewReader(slice[10:]))
+	require.Nil(err)
+	require.Equal(int64(len(slice)-10), n)
+	require.Equal(10, b.buff.Len())
+	require.Equal(int64(len(slice)-10), b.fileSize)
+
+	res := readByChunks(require, b, 1024)
+	require.Equal(slice, res, "wrong content was read")
+}
+
+func TestBuffer_InitialCapacity(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(1024)
+	defer b.Reset()
+
+	// Memory is allocated on the first write
+	require.Equal(0, b.buff.Cap())
+
+	// Small data is stored in the inline array
+	_, err := b.Write([]byte("a"))
+	require.Nil(err)
+	require.True(b.inlineUsed)
+	require.Equal(inlineMemorySize, b.buff.Cap())
+
+	large := []byte(generateRandomString(inlineMemorySize))
+	_, err = b.Write(large)
+	require.Nil(err)
+	require.False(b.inlineUsed)
+	require.Equal(1024, b.buff.Cap(), "the whole threshold must be allocated")
+	require.Equal(append([]byte("a"), large...), b.buff.Bytes())
+
+	b = NewBufferWithMaxMemorySize(1024)
+	defer b.Reset()
+
+	b.SetInitialCapacity(128)
+	_, err = b.Write(large)
+	require.Nil(err)
+	_, err = b.Write([]byte("a"))
+	require.Nil(err)
+	require.Equal(128, b.buff.Cap())
+
+	b = NewBufferWithInitialCapacity(1024, 256)
+	defer b.Reset()
+
+	_, err = b.Write(large)
+	require.Nil(err)
+	_, err = b.Write([]byte("a"))
+	require.Nil(err)
+	require.Equal(256, b.buff.Cap())
+
+	// The spill threshold isn't changed
+	slice := []byte(generateRandomString(2000))
+	_, err = b.Write(slice)
+	require.Nil(err)
+	require.Equal(1024, b.buff.Len())
+
+	// Jumbo writes don't allocate memory
+	b = NewBufferWithMaxMemorySize(1024)
+	defer b.Reset()
+
+	_, err = b.Write(make([]byte, 1024*jumboWriteFactor))
+	require.Nil(err)
+	require.Equal(0, b.buff.Cap())
+}
+
 func TestBuffer_WriteSmth(t *testing.T) {
 	tests := []struct {
 		desc  string
@@ -554,6 +742,40 @@ func TestBuffer_WriteSmth(t *testing.T) {
 	}
 }
 
+func TestBuffer_WriteRune_Allocs(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(1 << 20)
+	defer b.Reset()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		b.WriteRune('✓')
+	})
+	require.Zero(allocs)
+
+	res := readByChunks(require, b, 64)
+	require.Equal(strings.Repeat("✓", 101), string(res))
+}
+
+func TestBuffer_InlineMemory_Allocs(t *testing.T) {
+	require := require.New(t)
+
+	data := []byte(generateRandomString(inlineMemorySize))
+	res := make([]byte, len(data))
+
+	b := NewBufferWithMaxMemorySize(1 << 20)
+	defer b.Reset()
+
+	// Tiny payloads don't allocate memory
+	allocs := testing.AllocsPerRun(100, func() {
+		b.Write(data)
+		b.Read(res)
+		b.Reset()
+	})
+	require.Zero(allocs)
+	require.Equal(data, res)
+}
+
 func TestBuffer_WriteTo(t *testing.T) {
 	tests := []struct {
 		data []byte
@@ -591,6 +813,116 @@ func TestBuffer_WriteTo(t *testing.T) {
 	}
 }
 
+func TestBuffer_Drained(t *testing.T) {
+	data := generateRandomString(1000) + "\n"
+
+	tests := []struct {
+		desc  string
+		drain func(b *Buffer) error
+	}{
+		{
+			desc: "WriteTo",
+			drain: func(b *Buffer) error {
+				_, err := b.WriteTo(ioutil.Discard)
+				return err
+			},
+		},
+		{
+			desc: "Read",
+			drain: func(b *Buffer) error {
+				_, err := b.Read(make([]byte, len(data)))
+				return err
+			},
+		},
+		{
+			desc: "ReadV",
+			drain: func(b *Buffer) error {
+				_, err := b.ReadV([][]byte{make([]byte, 10), make([]byte, len(data)-10)})
+				return err
+			},
+		},
+		{
+			desc: "ReadString",
+			drain: func(b *Buffer) error {
+				_, err := b.ReadString('\n')
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+
+			_, err := b.WriteString(data)
+			require.Nil(err)
+			filename := b.filename
+
+			// The temp file is removed right after the last data is read
+			require.Nil(tt.drain(b))
+			require.Nil(b.readFile)
+			require.Empty(b.filename)
+			_, err = os.Stat(filename)
+			require.True(os.IsNotExist(err))
+
+			_, err = b.Read(make([]byte, 1))
+			require.Equal(io.EOF, err)
+		})
+	}
+}
+
+func TestBuffer_ShortReads(t *testing.T) {
+	tests := []struct {
+		desc string
+		wrap func(r io.Reader) io.Reader
+	}{
+		{desc: "HalfReader", wrap: iotest.HalfReader},
+		{desc: "OneByteReader", wrap: iotest.OneByteReader},
+		{desc: "DataErrReader", wrap: iotest.DataErrReader},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			data := []byte(generateRandomString(5000))
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+			writeByChunks(require, b, data, 33)
+
+			res := make([]byte, 150)
+			_, err := b.Read(res)
+			require.Nil(err)
+
+			// The file layer returns less data than requested
+			file := b.readFile
+			b.readFile = newReadCloser(tt.wrap(file), file)
+
+			// Short reads don't finish reading
+			res = append(res, readByChunks(require, b, 1000)...)
+			require.Equal(data, res)
+			require.Empty(b.filename)
+		})
+	}
+
+	t.Run("truncated file", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+		_, err := b.Write([]byte(generateRandomString(5000)))
+		require.Nil(err)
+		require.Nil(os.Truncate(b.filename, 1000))
+
+		_, err = b.WriteTo(ioutil.Discard)
+		require.True(errors.Is(err, ErrSpillLost))
+	})
+}
+
 func TestBuffer_ChangeTempDir(t *testing.T) {
 	if os.Getenv("CI_CD") == "true" {
 		// There are problems with permission (with GitHub Action, for example)
@@ -602,25 +934,19 @@ func TestBuffer_ChangeTempDir(t *testing.T) {
 		require := require.New(t)
 
 		var (
-			dir          = "./test"
+			dir          = t.TempDir()
 			maxMemory    = 100
 			originalData = []byte(generateRandomString(256))
 			chunk        = 64
 		)
 
-		err := os.MkdirAll(dir, 0755)
-		require.Nil(err)
-
 		buf := NewBufferWithMaxMemorySize(maxMemory)
-		err = buf.ChangeTempDir(dir)
+		err := buf.ChangeTempDir(dir)
 		require.Nil(err)
 
 		writeByChunks(require, buf, originalData, chunk)
 		data := readByChunks(require, buf, chunk)
 		require.Equal(originalData, data)
-
-		err = os.RemoveAll(dir)
-		require.Nil(err)
 	})
 
 	t.Run("Non-existing dir", func(t *testing.T) {
@@ -638,13 +964,7 @@ func TestBuffer_ChangeTempDir(t *testing.T) {
 		t.Parallel()
 		require := require.New(t)
 
-		var (
-			dir  = "./test"
-			file = "./test/123.txt"
-		)
-
-		err := os.MkdirAll(dir, 0755)
-		require.Nil(err)
+		file := filepath.Join(t.TempDir(), "123.txt")
 
 		f, err := os.Create(file)
 		require.Nil(err)
@@ -656,6 +976,42 @@ func TestBuffer_ChangeTempDir(t *testing.T) {
 	})
 }
 
+func TestBuffer_IsolatedTempDir(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-*")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	b := NewBufferWithMaxMemorySize(4)
+	err = b.ChangeTempDir(dir)
+	require.Nil(err)
+	b.EnableIsolatedTempDir()
+
+	_, err = b.Write([]byte(generateRandomString(64)))
+	require.Nil(err)
+
+	isolatedDir := filepath.Dir(b.filename)
+	require.NotEqual(b.tempFileDir, isolatedDir, "temp file must be created in a subdirectory")
+	require.Equal(b.tempFileDir, filepath.Dir(isolatedDir))
+
+	b.Reset()
+
+	_, err = os.Stat(isolatedDir)
+	require.True(os.IsNotExist(err), "subdirectory must be removed on Reset()")
+
+	// The subdirectory must be created again after Reset()
+	_, err = b.Write([]byte(generateRandomString(64)))
+	require.Nil(err)
+	require.NotEqual(isolatedDir, filepath.Dir(b.filename))
+
+	b.Reset()
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Empty(files)
+}
+
 func TestBuffer_FuzzTest(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 
@@ -797,6 +1153,18 @@ func generateRandomString(length int) string {
 	return string(filename)
 }
 
+// waitFor checks condition every tick till it returns true. It fails the test after timeout.
+// require.Eventually isn't used: testify v1.4.0 panics if a check finishes after the function returns
+func waitFor(require *require.Assertions, condition func() bool, timeout, tick time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.FailNow("condition never satisfied")
+		}
+		time.Sleep(tick)
+	}
+}
+
 // Benchmarks
 
 func BenchmarkBuffer(b *testing.B) {
@@ -1047,6 +1415,153 @@ func TestReadAt(t *testing.T) {
 	require.Equal(len(receivedData), n)
 }
 
+func TestReadAt_Concurrent(t *testing.T) {
+	tests := []struct {
+		desc    string
+		encrypt bool
+		mmap    bool
+	}{
+		{desc: "Plain"},
+		{desc: "With encryption", encrypt: true},
+		{desc: "With mmap", mmap: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			slice := []byte(generateRandomString(1 << 18))
+
+			b := NewBufferWithMaxMemorySize(1000)
+			defer b.Reset()
+
+			if tt.encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			if tt.mmap && b.EnableMmap() != nil {
+				t.Skip("mmap isn't supported")
+			}
+			writeByChunks(require, b, slice, 4096)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					data := make([]byte, 5000)
+					for j := 0; j < 50; j++ {
+						off := rand.Intn(len(slice) - len(data))
+						_, err := b.ReadAt(data, int64(off))
+						assert.Nil(t, err)
+						assert.Equal(t, slice[off:off+len(data)], data)
+					}
+				}()
+			}
+
+			// Sequential reading doesn't affect ReadAt
+			half := make([]byte, len(slice)/2)
+			_, err := b.Read(half)
+			require.Nil(err)
+			require.Equal(slice[:len(half)], half)
+
+			wg.Wait()
+		})
+	}
+}
+
+func TestReadAt_WhileWriting(t *testing.T) {
+	tests := []struct {
+		desc     string
+		encrypt  bool
+		finished bool
+	}{
+		{desc: "Plain"},
+		// Encrypted data can be read only after writing
+		{desc: "With encryption", encrypt: true, finished: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			slice := []byte(generateRandomString(1 << 16))
+
+			b := NewBufferWithMaxMemorySize(1000)
+			defer b.Reset()
+
+			if tt.encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			_, err := b.Write(slice[:5000])
+			require.Nil(err)
+
+			data := make([]byte, 3000)
+			_, err = b.ReadAt(data, 500)
+			require.Nil(err)
+			require.Equal(slice[500:3500], data)
+
+			// EOF beyond the written data
+			n, err := b.ReadAt(data, 4000)
+			require.Equal(io.EOF, err)
+			require.Equal(1000, n)
+			require.Equal(slice[4000:5000], data[:n])
+
+			_, err = b.Write(slice[5000:])
+			if tt.finished {
+				require.Equal(ErrBufferFinished, err)
+				return
+			}
+			require.Nil(err)
+
+			_, err = b.ReadAt(data, 5000)
+			require.Nil(err)
+			require.Equal(slice[5000:8000], data)
+			require.Equal(slice, readByChunks(require, b, 4096))
+		})
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1 << 18))
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			data := make([]byte, 100)
+			for {
+				// Serve the data written so far
+				size := int64(b.Len())
+				if size < int64(len(data)) {
+					continue
+				}
+				off := rand.Int63n(size - int64(len(data)) + 1)
+				_, err := b.ReadAt(data, off)
+				if !assert.Nil(t, err) || !assert.Equal(t, slice[off:off+int64(len(data))], data) {
+					return
+				}
+				if size == int64(len(slice)) {
+					return
+				}
+			}
+		}()
+
+		writeByChunks(require, b, slice, 1024)
+		<-done
+
+		require.Equal(slice, readByChunks(require, b, 4096))
+	})
+}
+
 func newBufWithSize(buf []byte, size int) *Buffer {
 	b := NewBufferWithMaxMemorySize(size)
 	if buf == nil || len(buf) == 0 {
@@ -0,0 +1,14 @@
+package buffer
+
+import (
+	"io"
+
+	"golang.org/x/text/encoding"
+)
+
+// ReadUTF8 returns a reader that interprets the content of the Buffer as text in enc (for example,
+// charmap.Windows1252 from golang.org/x/text/encoding/charmap) and converts it to UTF-8.
+// The Buffer is drained as the reader is read. See ReadTransformed
+func (b *Buffer) ReadUTF8(enc encoding.Encoding) io.Reader {
+	return b.ReadTransformed(enc.NewDecoder())
+}
@@ -0,0 +1,28 @@
+package buffer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestBuffer_ReadUTF8(t *testing.T) {
+	require := require.New(t)
+
+	text := bytes.Repeat([]byte("Grüße, café: 5€\n"), 20)
+	encoded, err := charmap.Windows1252.NewEncoder().Bytes(text)
+	require.Nil(err)
+	require.NotEqual(text, encoded)
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, encoded, 7)
+
+	res, err := ioutil.ReadAll(b.ReadUTF8(charmap.Windows1252))
+	require.Nil(err)
+	require.Equal(string(text), string(res))
+	require.Equal(0, b.Len())
+}
@@ -0,0 +1,212 @@
+package buffer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// checksumChunkSize is a size of chunks of a temp file that have their own checksums
+const checksumChunkSize = 64 << 10 // 64 KB
+
+var (
+	crc32Table = crc32.MakeTable(crc32.Castagnoli)
+
+	checksumChunkPool = sync.Pool{
+		New: func() interface{} {
+			return make([]byte, checksumChunkSize)
+		},
+	}
+)
+
+// ChecksumError is returned when data read from a temp file doesn't match its checksum.
+// It means that the temp file was corrupted or truncated
+type ChecksumError struct {
+	Filename string
+	// Offset is the offset of the corrupted chunk in the temp file
+	Offset int64
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("temp file '%s' is corrupted: checksum mismatch of chunk at offset %d", e.Filename, e.Offset)
+}
+
+// EnableChecksums makes the Buffer compute CRC-32 checksums of the temp file and verify them
+// during reading. If data read from the temp file doesn't match its checksum, *ChecksumError is returned.
+// It must be called before the first Write
+func (b *Buffer) EnableChecksums() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checksumsEnabled = true
+}
+
+// checksums contains checksums of chunks of a temp file
+type checksums struct {
+	filename string
+	sums     []uint32
+	// size is the total size of the temp file
+	size int64
+}
+
+// readChunk reads a chunk with index i into buf and verifies its checksum
+func (c *checksums) readChunk(r io.ReaderAt, i int, buf []byte) ([]byte, error) {
+	off := int64(i) * checksumChunkSize
+
+	length := c.size - off
+	if length > checksumChunkSize {
+		length = checksumChunkSize
+	}
+	buf = buf[:length]
+
+	n, err := r.ReadAt(buf, off)
+	if n < len(buf) {
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		// The file was truncated
+		return nil, &ChecksumError{Filename: c.filename, Offset: off}
+	}
+
+	if crc32.Checksum(buf, crc32Table) != c.sums[i] {
+		return nil, &ChecksumError{Filename: c.filename, Offset: off}
+	}
+
+	return buf, nil
+}
+
+// checksumWriter computes checksums of data written into the underlying writer
+type checksumWriter struct {
+	w io.WriteCloser
+	c *checksums
+	// crc is a checksum of the current incomplete chunk
+	crc uint32
+}
+
+func newChecksumWriter(w io.WriteCloser, c *checksums) *checksumWriter {
+	return &checksumWriter{
+		w: w,
+		c: c,
+	}
+}
+
+func (cw *checksumWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		bound := checksumChunkSize - int(cw.c.size%checksumChunkSize)
+		if bound > len(p) {
+			bound = len(p)
+		}
+
+		written, err := cw.w.Write(p[:bound])
+		cw.crc = crc32.Update(cw.crc, crc32Table, p[:written])
+		cw.c.size += int64(written)
+		n += written
+
+		if cw.c.size%checksumChunkSize == 0 {
+			cw.c.sums = append(cw.c.sums, cw.crc)
+			cw.crc = 0
+		}
+
+		if err != nil {
+			return n, err
+		}
+		p = p[bound:]
+	}
+
+	return n, nil
+}
+
+// Close saves the checksum of the last chunk and closes the underlying writer
+func (cw *checksumWriter) Close() error {
+	if cw.c.size%checksumChunkSize != 0 {
+		cw.c.sums = append(cw.c.sums, cw.crc)
+		cw.crc = 0
+	}
+
+	return cw.w.Close()
+}
+
+// checksumReaderAt verifies checksums of data read from the underlying io.ReaderAt.
+// Chunks are verified before their data is returned. It is safe for concurrent use
+type checksumReaderAt struct {
+	r io.ReaderAt
+	c *checksums
+}
+
+func newChecksumReaderAt(r io.ReaderAt, c *checksums) *checksumReaderAt {
+	return &checksumReaderAt{
+		r: r,
+		c: c,
+	}
+}
+
+func (cr *checksumReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	buf := checksumChunkPool.Get().([]byte)
+	defer checksumChunkPool.Put(buf)
+
+	for n < len(p) {
+		if off >= cr.c.size {
+			return n, io.EOF
+		}
+
+		i := int(off / checksumChunkSize)
+		chunk, err := cr.c.readChunk(cr.r, i, buf)
+		if err != nil {
+			return n, err
+		}
+
+		copied := copy(p[n:], chunk[off-int64(i)*checksumChunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+
+	return n, nil
+}
+
+// checksumReader verifies checksums of data read sequentially from the underlying io.ReaderAt.
+// It keeps the current chunk to avoid reading it multiple times
+type checksumReader struct {
+	r io.ReaderAt
+	c *checksums
+
+	off      int64
+	buf      []byte
+	chunk    []byte
+	chunkIdx int
+}
+
+func newChecksumReader(r io.ReaderAt, c *checksums) *checksumReader {
+	return &checksumReader{
+		r:        r,
+		c:        c,
+		buf:      make([]byte, checksumChunkSize),
+		chunkIdx: -1,
+	}
+}
+
+func (cr *checksumReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if cr.off >= cr.c.size {
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		}
+
+		i := int(cr.off / checksumChunkSize)
+		if i != cr.chunkIdx {
+			cr.chunk, err = cr.c.readChunk(cr.r, i, cr.buf)
+			if err != nil {
+				return n, err
+			}
+			cr.chunkIdx = i
+		}
+
+		copied := copy(p[n:], cr.chunk[cr.off-int64(i)*checksumChunkSize:])
+		n += copied
+		cr.off += int64(copied)
+	}
+
+	return n, nil
+}
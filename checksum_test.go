@@ -0,0 +1,144 @@
+package buffer
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Checksums(t *testing.T) {
+	tests := []struct {
+		desc    string
+		encrypt bool
+	}{
+		{desc: "Without encryption", encrypt: false},
+		{desc: "With encryption", encrypt: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				t.Run("", func(t *testing.T) {
+					t.Parallel()
+
+					require := require.New(t)
+
+					var (
+						sliceSize     = rand.Intn(1<<18) + 1
+						bufferSize    = rand.Intn(sliceSize)
+						readChunkSize = rand.Intn(1<<12) + 1
+					)
+
+					slice := []byte(generateRandomString(sliceSize))
+
+					newBuffer := func() *Buffer {
+						b := NewBufferWithMaxMemorySize(bufferSize)
+						if tt.encrypt {
+							require.Nil(b.EnableEncryption())
+						}
+						b.EnableChecksums()
+						writeByChunks(require, b, slice, 4096)
+
+						return b
+					}
+
+					// Read
+					b := newBuffer()
+					defer b.Reset()
+
+					res := readByChunks(require, b, readChunkSize)
+					require.Equal(slice, res, "wrong content was read")
+
+					// ReadAt
+					b = newBuffer()
+					defer b.Reset()
+
+					off := rand.Intn(sliceSize)
+					res = make([]byte, sliceSize-off)
+					n, err := b.ReadAt(res, int64(off))
+					require.Nil(err)
+					require.Equal(len(res), n)
+					require.Equal(slice[off:], res)
+				})
+			}
+		})
+	}
+}
+
+func TestBuffer_Checksums_Corruption(t *testing.T) {
+	const size = 3 * checksumChunkSize
+
+	corrupt := func(require *require.Assertions, filename string) {
+		f, err := os.OpenFile(filename, os.O_RDWR, 0)
+		require.Nil(err)
+		defer f.Close()
+
+		_, err = f.WriteAt([]byte{'!'}, checksumChunkSize+10)
+		require.Nil(err)
+	}
+	truncate := func(require *require.Assertions, filename string) {
+		require.Nil(os.Truncate(filename, checksumChunkSize+10))
+	}
+
+	tests := []struct {
+		desc    string
+		encrypt bool
+		damage  func(require *require.Assertions, filename string)
+	}{
+		{desc: "Corrupted file", damage: corrupt},
+		{desc: "Truncated file", damage: truncate},
+		{desc: "Corrupted encrypted file", encrypt: true, damage: corrupt},
+		{desc: "Truncated encrypted file", encrypt: true, damage: truncate},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			newBuffer := func() *Buffer {
+				b := NewBufferWithMaxMemorySize(0)
+				if tt.encrypt {
+					require.Nil(b.EnableEncryption())
+				}
+				b.EnableChecksums()
+
+				_, err := b.Write([]byte(generateRandomString(size)))
+				require.Nil(err)
+				require.Nil(b.finishWriting())
+				tt.damage(require, b.filename)
+
+				return b
+			}
+
+			var checksumErr *ChecksumError
+
+			// Read
+			b := newBuffer()
+			defer b.Reset()
+
+			data := make([]byte, 1024)
+			var err error
+			for err == nil {
+				_, err = b.Read(data)
+			}
+			require.True(errors.As(err, &checksumErr), "got unexpected error: %v", err)
+			require.Equal(int64(checksumChunkSize), checksumErr.Offset)
+
+			// ReadAt
+			b = newBuffer()
+			defer b.Reset()
+
+			data = make([]byte, size)
+			_, err = b.ReadAt(data, 0)
+			require.True(errors.As(err, &checksumErr), "got unexpected error: %v", err)
+			require.Equal(int64(checksumChunkSize), checksumErr.Offset)
+		})
+	}
+}
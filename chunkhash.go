@@ -0,0 +1,66 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ChunkedSum contains hashes of fixed-size chunks of a Buffer
+type ChunkedSum struct {
+	// Chunks contains hashes of the chunks in order
+	Chunks [][]byte
+	// Combined is a hash of the concatenated hashes of the chunks
+	Combined []byte
+}
+
+// ETag returns the combined hash in the format of S3 multipart ETags: hex of the combined hash,
+// a dash and the number of chunks. S3 uses MD5 (see crypto/md5)
+func (s ChunkedSum) ETag() string {
+	return hex.EncodeToString(s.Combined) + "-" + strconv.Itoa(len(s.Chunks))
+}
+
+// ChunkedSum computes hashes of chunks of chunkSize bytes of the unread portion in parallel (see ProcessChunks)
+// and the combined hash. newHash returns a hash for a chunk. If it is nil, SHA-256 is used.
+// The read position isn't changed. ChunkedSum finishes writing
+func (b *Buffer) ChunkedSum(chunkSize, workers int, newHash func() hash.Hash) (ChunkedSum, error) {
+	if chunkSize < 0 {
+		return ChunkedSum{}, errors.New("chunk size can't be negative")
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultParallelChunkSize
+	}
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	length := b.Len64()
+	sums := make([][]byte, (length+int64(chunkSize)-1)/int64(chunkSize))
+	_, err := b.ProcessChunks(chunkSize, workers, func(off int64, p []byte) error {
+		i := off / int64(chunkSize)
+		if i >= int64(len(sums)) {
+			return errors.New("Buffer was modified during hashing")
+		}
+
+		h := newHash()
+		h.Write(p)
+		// Chunks are disjoint, so no synchronization is needed
+		sums[i] = h.Sum(nil)
+		return nil
+	})
+	if err != nil {
+		return ChunkedSum{}, err
+	}
+
+	combined := newHash()
+	for _, sum := range sums {
+		combined.Write(sum)
+	}
+	return ChunkedSum{
+		Chunks:   sums,
+		Combined: combined.Sum(nil),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package buffer
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ChunkedSum(t *testing.T) {
+	require := require.New(t)
+
+	const chunkSize = 10 << 10
+
+	slice := []byte(generateRandomString(5*chunkSize + 123))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 4096)
+
+	sum, err := b.ChunkedSum(chunkSize, 3, md5.New)
+	require.Nil(err)
+	require.Len(sum.Chunks, 6)
+
+	combined := md5.New()
+	for i := range sum.Chunks {
+		end := (i + 1) * chunkSize
+		if end > len(slice) {
+			end = len(slice)
+		}
+		chunkSum := md5.Sum(slice[i*chunkSize : end])
+		require.Equal(chunkSum[:], sum.Chunks[i], "chunk %d", i)
+		combined.Write(chunkSum[:])
+	}
+	require.Equal(combined.Sum(nil), sum.Combined)
+	require.Regexp(`^[0-9a-f]{32}-6$`, sum.ETag())
+
+	// SHA-256 by default
+	sum, err = b.ChunkedSum(len(slice), 0, nil)
+	require.Nil(err)
+	require.Len(sum.Chunks, 1)
+	chunkSum := sha256.Sum256(slice)
+	require.Equal(chunkSum[:], sum.Chunks[0])
+
+	// The read position isn't changed
+	require.Equal(len(slice), b.Len())
+}
@@ -0,0 +1,247 @@
+package buffer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// sharedFile is a temp file shared by clones of a Buffer (see Clone). The file isn't modified
+// after writing. It is removed when the last Buffer releases it
+type sharedFile struct {
+	mu   sync.Mutex
+	refs int
+
+	strategy TempFileStrategy
+	filename string
+	// owned is false when the file doesn't belong to the Buffers and must not be removed
+	owned          bool
+	mirrorFilename string
+	fileLock       *os.File
+	// isolatedDir is the isolated directory of the Buffer that created the file (see EnableIsolatedTempDir)
+	isolatedDir string
+
+	quota     *Quota
+	quotaUser *Buffer
+	quotaUsed int64
+}
+
+// shareTempFile makes the temp file of b shared. The file, its mirror, lock, isolated directory
+// and quota space belong to the returned sharedFile after the call
+func (b *Buffer) shareTempFile() *sharedFile {
+	if b.sharedFile != nil {
+		return b.sharedFile
+	}
+
+	b.sharedFile = &sharedFile{
+		refs:           1,
+		strategy:       b.tempFiles(),
+		filename:       b.filename,
+		owned:          !b.keepFile,
+		mirrorFilename: b.mirrorFilename,
+		fileLock:       b.fileLock,
+		isolatedDir:    b.isolatedDir,
+		quota:          b.quota,
+		quotaUser:      b,
+		quotaUsed:      b.quotaUsed,
+	}
+	// The file must not be modified or removed by the Buffer anymore
+	b.keepFile = true
+	b.fileLock = nil
+	b.isolatedDir = ""
+	b.quotaUsed = 0
+
+	return b.sharedFile
+}
+
+func (s *sharedFile) acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs++
+}
+
+// release releases a reference. The file is removed when the last reference is released
+func (s *sharedFile) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	if s.owned {
+		s.strategy.Remove(s.filename)
+		if s.mirrorFilename != "" {
+			s.strategy.Remove(s.mirrorFilename)
+		}
+	}
+	if s.fileLock != nil {
+		s.fileLock.Close()
+	}
+	if s.isolatedDir != "" {
+		os.RemoveAll(s.isolatedDir)
+	}
+	if s.quota != nil {
+		s.quota.release(s.quotaUser, s.quotaUsed)
+	}
+}
+
+// releaseSharedFile releases the shared temp file. The mirror belongs to the shared file, so it isn't removed
+func (b *Buffer) releaseSharedFile() {
+	if b.sharedFile == nil {
+		return
+	}
+
+	b.sharedFile.release()
+	b.sharedFile = nil
+	b.mirrorFilename = ""
+}
+
+// Clone finishes writing and returns a new Buffer with the same data and read position. The Buffers
+// are independent: reads from one of them don't affect the other.
+//
+// Clone is cheap: only data stored in memory is copied. If the filesystem supports reflinks (XFS, btrfs, etc.
+// on Linux), the clone gets its own copy of the temp file that shares disk blocks with the original.
+// Otherwise, the temp file is shared by the Buffers and is removed when the last of them is reset or drained.
+// The shared file can't be compacted.
+// The clone has the same settings for reading (encryption, checksums, mmap, etc.), but it doesn't
+// verify the digest (see EnableDigestVerification) and isn't tracked by the Manager.
+// Buffers in a spill group can't be cloned
+func (b *Buffer) Clone() (*Buffer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.groupFile != nil {
+		return nil, errors.New("a Buffer in a spill group can't be cloned")
+	}
+	err := b.finishWriting()
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewBufferWithMaxMemorySize(b.maxInMemorySize)
+	c.writingFinished = true
+	c.readingFinished = b.readingFinished
+	c.size = b.size
+	c.offset = b.offset
+	c.diskFailed = b.diskFailed
+
+	c.tempFileDir = b.tempFileDir
+	c.tempFileStrategy = b.tempFileStrategy
+	c.spillLostHandler = b.spillLostHandler
+	c.mmapEnabled = b.mmapEnabled
+	c.pageCacheHints = b.pageCacheHints
+	c.readAheadChunkSize, c.readAheadChunks = b.readAheadChunkSize, b.readAheadChunks
+	c.readCacheBlockSize, c.readCacheBlocks = b.readCacheBlockSize, b.readCacheBlocks
+	c.decryptedBlockCacheSize = b.decryptedBlockCacheSize
+	c.maxTokenSize = b.maxTokenSize
+
+	if b.encrypt {
+		key, err := b.key()
+		if err != nil {
+			return nil, err
+		}
+		c.lockKey = b.lockKey
+		dst, err := c.allocateKey()
+		if err != nil {
+			return nil, err
+		}
+		copy(dst, key)
+
+		c.encrypt = true
+		c.encryptionConfig = b.encryptionConfig
+		c.aead = b.aead
+		c.wrappedKey = append([]byte(nil), b.wrappedKey...)
+		c.sealedHeader = b.sealedHeader
+	}
+
+	if b.filename != "" {
+		switch {
+		case c.reflinkTempFile(b):
+			// The clone has its own file
+		case b.unlinkedFile != nil:
+			c.unlinkedFile, err = dupFile(b.unlinkedFile)
+			if err != nil {
+				c.reset()
+				return nil, errors.Wrapf(err, "can't duplicate the descriptor of a temp file '%s'", b.filename)
+			}
+			fallthrough
+		default:
+			shared := b.shareTempFile()
+			shared.acquire()
+			c.sharedFile = shared
+			c.keepFile = true
+			c.filename = b.filename
+			c.mirrorFilename = b.mirrorFilename
+		}
+
+		c.useFile = b.useFile
+		c.fileSize = b.fileSize
+		c.fileDropped = b.fileDropped
+		c.filePunched = b.filePunched
+		// The checksums and the index aren't modified after writing
+		c.checksums = b.checksums
+		c.persistentIndex = b.persistentIndex
+	}
+
+	// Copy the memory tier. ReadAt needs the read part of the memory too
+	memory := b.writtenMemory
+	c.sensitive = b.sensitive
+	if c.sensitive || len(memory) <= len(c.inline) {
+		c.growMemory(len(memory))
+	} else {
+		c.buff = *bytes.NewBuffer(make([]byte, 0, len(memory)))
+	}
+	c.buff.Write(memory)
+	c.writtenMemory = c.buff.Bytes()
+	c.buff.Next(len(memory) - b.buff.Len())
+
+	return c, nil
+}
+
+// reflinkTempFile makes b use a copy of the temp file of src created with reflinkFile. The copy is
+// created in the same directory (or next to the isolated directory), so both files are on the same
+// filesystem. It reports whether
+// the copy was created
+func (b *Buffer) reflinkTempFile(src *Buffer) bool {
+	if src.unlinkedFile != nil {
+		return false
+	}
+
+	srcFile, err := src.tempFiles().Open(src.filename)
+	if err != nil {
+		return false
+	}
+	defer srcFile.Close()
+
+	dir := filepath.Dir(src.filename)
+	isolatedDir := src.isolatedDir
+	if src.sharedFile != nil {
+		isolatedDir = src.sharedFile.isolatedDir
+	}
+	if dir == isolatedDir {
+		// The isolated directory is removed with the original file
+		dir = filepath.Dir(dir)
+	}
+
+	dst, err := b.tempFiles().Create(dir, "go-disk-buffer-*.tmp")
+	if err != nil {
+		return false
+	}
+	err = reflinkFile(dst, srcFile)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		b.tempFiles().Remove(dst.Name())
+		return false
+	}
+
+	b.filename = dst.Name()
+	return true
+}
@@ -0,0 +1,172 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Clone(t *testing.T) {
+	tests := []struct {
+		desc    string
+		prepare func(b *Buffer) error
+	}{
+		{desc: "plain", prepare: func(b *Buffer) error { return nil }},
+		{desc: "encryption", prepare: func(b *Buffer) error { return b.EnableEncryption() }},
+		{desc: "unlinked", prepare: func(b *Buffer) error { return b.EnableUnlinkedTempFiles() }},
+		{desc: "isolated dir", prepare: func(b *Buffer) error { b.EnableIsolatedTempDir(); return nil }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			quota := NewQuota(1 << 20)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+			b.SetQuota(quota)
+			err := tt.prepare(b)
+			if err != nil {
+				t.Skipf("can't prepare the Buffer: %s", err)
+			}
+
+			data := []byte(generateRandomString(1000))
+			writeByChunks(require, b, data, 33)
+
+			res := make([]byte, 150)
+			_, err = b.Read(res)
+			require.Nil(err)
+
+			c, err := b.Clone()
+			require.Nil(err)
+			defer c.Reset()
+
+			// Writing is finished
+			_, err = b.Write(data)
+			require.Equal(ErrBufferFinished, err)
+
+			filename := c.filename
+			if c.sharedFile != nil {
+				require.Equal(b.filename, filename)
+			} else {
+				// The filesystem supports reflinks: the clone has its own file
+				require.NotEqual(b.filename, filename)
+			}
+			require.Equal(b.Len(), c.Len())
+
+			// ReadAt sees the whole data
+			res = make([]byte, 200)
+			_, err = c.ReadAt(res, 50)
+			require.Nil(err)
+			require.Equal(data[50:250], res)
+
+			// The Buffers are read independently
+			require.Equal(data[150:], readByChunks(require, b, 64))
+			b.Reset()
+			if tt.desc != "unlinked" {
+				_, err = os.Stat(filename)
+				require.Nil(err, "file of the clone must not be removed")
+			}
+
+			// Clone of the clone
+			c2, err := c.Clone()
+			require.Nil(err)
+			defer c2.Reset()
+			filename2 := c2.filename
+
+			require.Equal(data[150:], readByChunks(require, c, 50))
+			require.Equal(data[150:], readByChunks(require, c2, 70))
+
+			// The last reference removes the file
+			for _, name := range []string{filename, filename2} {
+				_, err = os.Stat(name)
+				require.True(os.IsNotExist(err))
+			}
+			require.Zero(quota.Used())
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+
+		data := []byte(generateRandomString(500))
+		writeByChunks(require, b, data, 33)
+
+		c, err := b.Clone()
+		require.Nil(err)
+		defer c.Reset()
+
+		require.Equal(data, readByChunks(require, b, 64))
+		require.Equal(data, readByChunks(require, c, 50))
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+
+		data := []byte(generateRandomString(1000))
+		writeByChunks(require, b, data, 33)
+
+		c, err := b.Clone()
+		require.Nil(err)
+		defer c.Reset()
+		if c.sharedFile == nil {
+			t.Skip("the clone has its own file")
+		}
+		filename := b.filename
+
+		// The shared file can't be replaced
+		require.NotNil(b.RotateEncryptionKey([]byte(generateRandomString(32))))
+		require.NotNil(c.RotateEncryptionKey([]byte(generateRandomString(32))))
+
+		require.Equal(data, readByChunks(require, b, 64))
+		require.Equal(data, readByChunks(require, c, 50))
+
+		_, err = os.Stat(filename)
+		require.True(os.IsNotExist(err))
+	})
+}
+
+func TestBuffer_Clone_Reflink(t *testing.T) {
+	require := require.New(t)
+
+	src, err := ioutil.TempFile("", "go-disk-buffer-*.tmp")
+	require.Nil(err)
+	defer os.Remove(src.Name())
+	defer src.Close()
+	dst, err := ioutil.TempFile("", "go-disk-buffer-*.tmp")
+	require.Nil(err)
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+	if err := reflinkFile(dst, src); err != nil {
+		t.Skipf("reflink isn't supported: %s", err)
+	}
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+	b.EnableIsolatedTempDir()
+
+	data := []byte(generateRandomString(1000))
+	writeByChunks(require, b, data, 33)
+
+	c, err := b.Clone()
+	require.Nil(err)
+	defer c.Reset()
+
+	require.Nil(c.sharedFile)
+	require.NotEqual(b.filename, c.filename)
+	require.NotEqual(b.isolatedDir, filepath.Dir(c.filename))
+
+	b.Reset()
+	require.Equal(data, readByChunks(require, c, 64))
+}
@@ -0,0 +1,143 @@
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCompacted is returned by ReadAt when the requested data was removed by Compact or PunchHoles
+var ErrCompacted = errors.New("read data was removed from the temp file")
+
+// Compact drops the already read prefix of the temp file: the unread data is copied into a new temp file
+// that replaces the current one. So, disk usage of a long-lived Buffer that is read incrementally tracks
+// the unread data, not all written data. The space is returned to the quota.
+//
+// Reading finishes writing, so there's nothing to drop while the Buffer is being written: Compact does
+// nothing and Write can be used as usual. Use FollowBuffer.EnableAutoCompaction to compact the data of a Buffer
+// that is read while it is being written. ReadAt can't read the dropped data after the compaction: it returns
+// ErrCompacted. Compact does nothing if the Buffer doesn't use a temp file or the file doesn't belong to the Buffer
+func (b *Buffer) Compact() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.writingFinished {
+		return nil
+	}
+	if b.writeErr != nil {
+		return b.writeErr
+	}
+	return b.compact()
+}
+
+// EnableAutoCompaction makes Read compact the temp file (see Compact) when at least minSize bytes of it
+// were read and the read prefix is larger than the unread data, so copying costs less than it frees.
+// Errors of the compaction are ignored: the temp file is kept as is. It doesn't affect writing
+func (b *Buffer) EnableAutoCompaction(minSize int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.autoCompactionSize = minSize
+}
+
+// autoCompact compacts the temp file if the conditions of EnableAutoCompaction are met
+func (b *Buffer) autoCompact() {
+	if !b.useFile || b.keepFile {
+		return
+	}
+
+	dropped := b.fileConsumed() - b.fileDropped
+	if dropped >= b.autoCompactionSize && dropped > b.fileSize-b.fileConsumed() {
+		b.compact()
+	}
+}
+
+// compact is a non-locking version of Compact. Writing must be finished
+func (b *Buffer) compact() error {
+	if !b.useFile || b.keepFile || b.readingFinished {
+		return nil
+	}
+	consumed := b.fileConsumed()
+	dropped := consumed - b.fileDropped
+	if dropped <= 0 {
+		return nil
+	}
+
+	src, err := b.openReadFile()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.CopyN(ioutil.Discard, src, dropped)
+	if err != nil {
+		return errors.Wrap(err, "can't skip read data")
+	}
+
+	var (
+		oldFilename       = b.filename
+		oldMirrorFilename = b.mirrorFilename
+		oldChecksums      = b.checksums
+		oldSealedHeader   = b.sealedHeader
+		oldFileDropped    = b.fileDropped
+		oldFilePunched    = b.filePunched
+		oldUnlinkedFile   = b.unlinkedFile
+	)
+	restore := func() {
+		b.filename = oldFilename
+		b.mirrorFilename = oldMirrorFilename
+		b.checksums = oldChecksums
+		b.sealedHeader = oldSealedHeader
+		b.fileDropped = oldFileDropped
+		b.filePunched = oldFilePunched
+		b.restoreUnlinkedFile(oldUnlinkedFile)
+		if b.fileLock != nil && b.fileLock.Name() != oldFilename {
+			// The lock of the old file was released by createWriteFile
+			b.lockTempFile()
+		}
+	}
+
+	unread := b.fileSize - consumed
+	// The sealed header of the new file must contain the size of the unread data
+	b.fileDropped = consumed
+	b.filePunched = 0
+	err = b.createWriteFile(unread)
+	if err != nil {
+		restore()
+		return err
+	}
+
+	n, err := io.Copy(b.writeFile, src)
+	if err == nil && n != unread {
+		err = errors.Errorf("temp file contains %d unread bytes, expected %d", n, unread)
+	}
+	closeErr := b.writeFile.Close()
+	if err == nil && closeErr != nil {
+		err = closeErr
+	}
+	b.writeFile = nil
+	b.writeTempFile = nil
+	if err != nil {
+		b.tempFiles().Remove(b.filename)
+		if b.mirrorFilename != oldMirrorFilename {
+			b.removeMirrorFile()
+		}
+		restore()
+		return errors.Wrap(err, "can't compact the temp file")
+	}
+
+	b.tempFiles().Remove(oldFilename)
+	if oldMirrorFilename != "" {
+		b.tempFiles().Remove(oldMirrorFilename)
+	}
+	b.replaceUnlinkedFile(oldUnlinkedFile)
+	b.closeReadAtFile()
+
+	if b.quota != nil && b.quotaUsed != 0 {
+		b.quota.release(b, dropped)
+		b.quotaUsed -= dropped
+	}
+
+	return b.reopenReadFile()
+}
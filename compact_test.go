@@ -0,0 +1,156 @@
+package buffer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Compact(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(10000))
+
+		quota := NewQuota(1 << 20)
+		b := NewBufferWithMaxMemorySize(100)
+		b.SetQuota(quota)
+		b.EnableChecksums()
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+
+		writeByChunks(require, b, slice, 7)
+
+		res := make([]byte, 6000)
+		_, err := b.Read(res)
+		require.Nil(err)
+
+		oldFilename := b.filename
+		require.Nil(b.Compact())
+		require.NotEqual(oldFilename, b.filename)
+		_, err = os.Stat(oldFilename)
+		require.True(os.IsNotExist(err), "old file must be removed")
+		require.Equal(int64(10000-6000), quota.Used(), "space must be returned to the quota")
+
+		// Read data must be unavailable
+		_, err = b.ReadAt(make([]byte, 10), 1000)
+		require.True(errors.Is(err, ErrCompacted), "got %v", err)
+		at := make([]byte, 100)
+		_, err = b.ReadAt(at, 50)
+		require.True(errors.Is(err, ErrCompacted), "got %v", err)
+		_, err = b.ReadAt(at, 7000)
+		require.Nil(err)
+		require.Equal(slice[7000:7100], at)
+
+		// Nothing to compact
+		filename := b.filename
+		require.Nil(b.Compact())
+		require.Equal(filename, b.filename)
+
+		res = append(res, readByChunks(require, b, 16)...)
+		require.Equal(slice, res)
+
+		b.Reset()
+		require.Equal(int64(0), quota.Used())
+	}
+}
+
+func TestBuffer_Compact_Writing(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(10000))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	// Nothing was read: writing isn't finished
+	writeByChunks(require, b, slice[:5000], 7)
+	filename := b.filename
+	require.Nil(b.Compact())
+	require.Equal(filename, b.filename)
+
+	_, err := b.Write(slice[5000:])
+	require.Nil(err)
+	require.Equal(slice, readByChunks(require, b, 16))
+}
+
+func TestBuffer_EnableAutoCompaction(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(10000))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	b.EnableAutoCompaction(1000)
+
+	writeByChunks(require, b, slice, 7)
+
+	var (
+		res       []byte
+		filenames = map[string]bool{}
+		chunk     = make([]byte, 500)
+	)
+	for {
+		n, err := b.Read(chunk)
+		res = append(res, chunk[:n]...)
+		if err != nil {
+			break
+		}
+		if b.filename == "" {
+			// All data was read: the file is removed
+			break
+		}
+		filenames[b.filename] = true
+
+		// Disk usage must track the unread data
+		stats, statErr := os.Stat(b.filename)
+		require.Nil(statErr)
+		require.True(stats.Size() <= 2*int64(b.Len())+1000, "file is too large: %d, unread: %d", stats.Size(), b.Len())
+	}
+	require.Equal(slice, res)
+	require.True(len(filenames) > 1, "file must be compacted")
+}
+
+func TestFollowBuffer_EnableAutoCompaction(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(10000))
+
+	fb := NewFollowBuffer(100)
+	defer fb.Reset()
+	fb.EnableAutoCompaction(1000)
+
+	_, err := fb.Write(slice)
+	require.Nil(err)
+
+	var (
+		res       []byte
+		filenames = map[string]bool{}
+		chunk     = make([]byte, 500)
+	)
+	for len(res) < len(slice) {
+		n, err := fb.Read(chunk)
+		require.Nil(err)
+		res = append(res, chunk[:n]...)
+
+		// The writer continues to write into a new Buffer
+		_, err = fb.Write([]byte("data"))
+		require.Nil(err)
+
+		b := fb.reading
+		if b.filename == "" {
+			continue
+		}
+		filenames[b.filename] = true
+
+		// Disk usage must track the unread data
+		stats, statErr := os.Stat(b.filename)
+		require.Nil(statErr)
+		require.True(stats.Size() <= 2*int64(b.Len())+1000, "file is too large: %d, unread: %d", stats.Size(), b.Len())
+	}
+	require.Equal(slice, res[:len(slice)])
+	require.True(len(filenames) > 1, "file must be compacted")
+}
@@ -0,0 +1,270 @@
+package buffer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidContainer is returned by Import when the stream isn't a valid container or its data
+// doesn't match the checksum
+var ErrInvalidContainer = errors.New("invalid container")
+
+var containerMagic = [4]byte{'G', 'D', 'B', 'C'}
+
+const (
+	containerVersion = 1
+
+	containerCompressed byte = 1 << iota
+	containerEncrypted
+)
+
+// ExportOptions configure the container written by Export
+type ExportOptions struct {
+	// Compress compresses data with gzip
+	Compress bool
+	// Key is a 32-byte key. Data is encrypted with the default format if it isn't nil.
+	// The encryption config of the Buffer is used (see SetEncryptionConfig)
+	Key []byte
+}
+
+// Export finishes writing and writes the unread data into w as a single versioned stream (container).
+// The container contains the data and the metadata required to read it: compression, encryption
+// parameters, the size and the CRC-32C checksum of the data. The read position isn't changed.
+// The container can be read with Import on another host. Export returns the size of the data.
+//
+// The format of the container:
+//
+//	magic ("GDBC") | version (1 byte) | flags (1 byte) | encryption format (1 byte) |
+//	min version (1 byte) | max version (1 byte) | cipher suites count (1 byte) | cipher suites |
+//	frames of data (size (4 bytes) | data) | empty frame | data size (8 bytes) | CRC-32C of data (4 bytes)
+func (b *Buffer) Export(w io.Writer, opts ExportOptions) (int64, error) {
+	if opts.Key != nil && len(opts.Key) != 32 {
+		return 0, errors.Errorf("invalid key size: %d, expected 32", len(opts.Key))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.finishWriting()
+	if err != nil {
+		return 0, err
+	}
+	src, err := b.newUnreadReader()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	cfg := b.encryptionConfig
+	if len(cfg.CipherSuites) > 255 {
+		return 0, errors.New("too many cipher suites")
+	}
+
+	var flags byte
+	if opts.Compress {
+		flags |= containerCompressed
+	}
+	if opts.Key != nil {
+		flags |= containerEncrypted
+	}
+	header := append(containerMagic[:], containerVersion, flags, defaultEncryptionFormat,
+		cfg.MinVersion, cfg.MaxVersion, byte(len(cfg.CipherSuites)))
+	header = append(header, cfg.CipherSuites...)
+	_, err = w.Write(header)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't write the header")
+	}
+
+	frames := &frameWriter{w: w}
+	var (
+		dst     io.Writer = frames
+		closers []io.Closer
+	)
+	if opts.Key != nil {
+		// Hide Close method: the encryption stream closes the underlying writer
+		enc, err := newDefaultEncryptWriter(struct{ io.Writer }{dst}, cfg, append([]byte(nil), opts.Key...))
+		if err != nil {
+			return 0, errors.Wrap(err, "can't create an encryption stream")
+		}
+		dst = enc
+		closers = append(closers, enc)
+	}
+	if opts.Compress {
+		gw := gzip.NewWriter(dst)
+		dst = gw
+		closers = append(closers, gw)
+	}
+
+	crc := crc32.New(crc32Table)
+	size, err := io.Copy(io.MultiWriter(dst, crc), src)
+	if err != nil {
+		return size, errors.Wrap(err, "can't export data")
+	}
+	// Close the outer streams first
+	for i := len(closers) - 1; i >= 0; i-- {
+		err = closers[i].Close()
+		if err != nil {
+			return size, errors.Wrap(err, "can't finish the stream")
+		}
+	}
+
+	// The empty frame and the trailer
+	trailer := make([]byte, 4, 4+12)
+	trailer = binary.LittleEndian.AppendUint64(trailer, uint64(size))
+	trailer = binary.LittleEndian.AppendUint32(trailer, crc.Sum32())
+	_, err = w.Write(trailer)
+	if err != nil {
+		return size, errors.Wrap(err, "can't write the trailer")
+	}
+	return size, nil
+}
+
+// Import reads a container written by Export from r and writes the data into the Buffer. key must be
+// the key passed to Export if the container is encrypted. It returns the size of the data.
+// ErrInvalidContainer is returned if the checksum doesn't match
+func (b *Buffer) Import(r io.Reader, key []byte) (int64, error) {
+	header := make([]byte, len(containerMagic)+6)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidContainer, "can't read the header")
+	}
+	if !bytes.HasPrefix(header, containerMagic[:]) {
+		return 0, errors.Wrap(ErrInvalidContainer, "invalid magic")
+	}
+	header = header[len(containerMagic):]
+	version, flags, format := header[0], header[1], header[2]
+	cfg := EncryptionConfig{
+		MinVersion: header[3],
+		MaxVersion: header[4],
+	}
+	if version != containerVersion {
+		return 0, errors.Errorf("unsupported container version: %d", version)
+	}
+	if n := int(header[5]); n > 0 {
+		cfg.CipherSuites = make([]byte, n)
+		_, err = io.ReadFull(r, cfg.CipherSuites)
+		if err != nil {
+			return 0, errors.Wrap(ErrInvalidContainer, "can't read the header")
+		}
+	}
+
+	frames := &frameReader{r: r}
+	var src io.Reader = frames
+	if flags&containerEncrypted != 0 {
+		if format != defaultEncryptionFormat {
+			return 0, errors.Errorf("unsupported encryption format: %d", format)
+		}
+		if len(key) != 32 {
+			return 0, errors.Errorf("invalid key size: %d, expected 32", len(key))
+		}
+		src, err = newDefaultDecryptReader(src, cfg, append([]byte(nil), key...))
+		if err != nil {
+			return 0, errors.Wrap(err, "can't create a decryption stream")
+		}
+		src = decryptReader{src}
+	}
+	if flags&containerCompressed != 0 {
+		gr, err := gzip.NewReader(src)
+		if err != nil {
+			return 0, errors.Wrap(err, "can't read gzip header")
+		}
+		defer gr.Close()
+		src = gr
+	}
+
+	crc := crc32.New(crc32Table)
+	n, err := b.ReadFrom(io.TeeReader(src, crc))
+	if err != nil {
+		return n, errors.Wrap(err, "can't import data")
+	}
+
+	// Skip the rest of the frames (the end of the encryption stream, for example)
+	_, err = io.Copy(ioutil.Discard, frames)
+	if err != nil {
+		return n, errors.Wrap(err, "can't import data")
+	}
+	return n, verifyContainerTrailer(r, n, crc)
+}
+
+func verifyContainerTrailer(r io.Reader, size int64, crc hash.Hash32) error {
+	trailer := make([]byte, 12)
+	_, err := io.ReadFull(r, trailer)
+	if err != nil {
+		return errors.Wrap(ErrInvalidContainer, "can't read the trailer")
+	}
+	if int64(binary.LittleEndian.Uint64(trailer)) != size || binary.LittleEndian.Uint32(trailer[8:]) != crc.Sum32() {
+		return errors.Wrap(ErrInvalidContainer, "data doesn't match the checksum")
+	}
+	return nil
+}
+
+// frameWriter writes every Write as a frame: size (4 bytes) | data
+type frameWriter struct {
+	w io.Writer
+}
+
+func (fw *frameWriter) Write(p []byte) (int, error) {
+	size := len(p)
+	for len(p) > 0 {
+		frame := p
+		if len(frame) > 1<<30 {
+			frame = frame[:1<<30]
+		}
+
+		var header [4]byte
+		binary.LittleEndian.PutUint32(header[:], uint32(len(frame)))
+		_, err := fw.w.Write(header[:])
+		if err != nil {
+			return size - len(p), err
+		}
+		n, err := fw.w.Write(frame)
+		if err != nil {
+			return size - len(p) + n, err
+		}
+		p = p[len(frame):]
+	}
+	return size, nil
+}
+
+// frameReader reads frames written by frameWriter till the empty frame
+type frameReader struct {
+	r io.Reader
+	// left is the number of unread bytes of the current frame
+	left uint32
+	done bool
+}
+
+func (fr *frameReader) Read(p []byte) (int, error) {
+	if fr.done {
+		return 0, io.EOF
+	}
+	if fr.left == 0 {
+		var size [4]byte
+		_, err := io.ReadFull(fr.r, size[:])
+		if err != nil {
+			return 0, errors.Wrap(ErrInvalidContainer, "can't read a frame")
+		}
+		fr.left = binary.LittleEndian.Uint32(size[:])
+		if fr.left == 0 {
+			fr.done = true
+			return 0, io.EOF
+		}
+	}
+
+	if uint32(len(p)) > fr.left {
+		p = p[:fr.left]
+	}
+	n, err := fr.r.Read(p)
+	fr.left -= uint32(n)
+	if err == io.EOF {
+		err = errors.Wrap(ErrInvalidContainer, "unexpected end of a frame")
+	}
+	return n, err
+}
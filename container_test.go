@@ -0,0 +1,76 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Export_Import(t *testing.T) {
+	key := []byte(generateRandomString(32))
+
+	tests := []struct {
+		desc string
+		opts ExportOptions
+	}{
+		{desc: "plain"},
+		{desc: "compressed", opts: ExportOptions{Compress: true}},
+		{desc: "encrypted", opts: ExportOptions{Key: key}},
+		{desc: "compressed and encrypted", opts: ExportOptions{Compress: true, Key: key}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			// Repeated random data is compressible
+			slice := bytes.Repeat([]byte(generateRandomString(1000)), 200)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+			writeByChunks(require, b, slice, 1000)
+
+			res := make([]byte, 10)
+			_, err := b.Read(res)
+			require.Nil(err)
+
+			var container bytes.Buffer
+			n, err := b.Export(&container, tt.opts)
+			require.Nil(err)
+			require.Equal(int64(len(slice)-10), n)
+			if tt.opts.Compress && tt.opts.Key == nil {
+				require.True(container.Len() < len(slice)/10, "data must be compressed")
+			}
+
+			res = append(res, readByChunks(require, b, 1000)...)
+			require.Equal(slice, res, "read position must not be changed")
+
+			imported := NewBufferWithMaxMemorySize(100)
+			defer imported.Reset()
+			n, err = imported.Import(bytes.NewReader(container.Bytes()), key)
+			require.Nil(err)
+			require.Equal(int64(len(slice)-10), n)
+			require.Equal(slice[10:], readByChunks(require, imported, 1000))
+
+			// Corrupted data
+			data := append([]byte(nil), container.Bytes()...)
+			data[len(data)/2] ^= 0xff
+			corrupted := NewBufferWithMaxMemorySize(100)
+			defer corrupted.Reset()
+			_, err = corrupted.Import(bytes.NewReader(data), key)
+			require.NotNil(err)
+
+			// Truncated stream
+			truncated := NewBufferWithMaxMemorySize(100)
+			defer truncated.Reset()
+			_, err = truncated.Import(bytes.NewReader(container.Bytes()[:container.Len()-5]), key)
+			require.True(errors.Is(err, ErrInvalidContainer), "got %v", err)
+		})
+	}
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	_, err := b.Import(bytes.NewReader([]byte("not a container")), nil)
+	require.True(t, errors.Is(err, ErrInvalidContainer), "got %v", err)
+}
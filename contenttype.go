@@ -0,0 +1,25 @@
+package buffer
+
+import (
+	"io"
+	"net/http"
+)
+
+// sniffLen is the max number of bytes considered by http.DetectContentType
+const sniffLen = 512
+
+// DetectContentType returns the MIME type of the unread portion of the Buffer determined by
+// http.DetectContentType. Only the first 512 bytes are read with ReadAt, so the read position
+// isn't changed. DetectContentType finishes writing
+func (b *Buffer) DetectContentType() (string, error) {
+	b.mu.Lock()
+	start := b.offset
+	b.mu.Unlock()
+
+	data := make([]byte, sniffLen)
+	n, err := b.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(data[:n]), nil
+}
@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_DetectContentType(t *testing.T) {
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 1000)
+
+	tests := []struct {
+		desc     string
+		data     string
+		skip     int
+		expected string
+	}{
+		{desc: "empty", data: "", expected: "text/plain; charset=utf-8"},
+		{desc: "png", data: png, expected: "image/png"},
+		{desc: "html", data: "<!DOCTYPE HTML><html><body></body></html>", expected: "text/html; charset=utf-8"},
+		{desc: "unread portion", data: "xxxx" + png, skip: 4, expected: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			for _, maxSize := range []int{0, 10, 2000} {
+				require := require.New(t)
+
+				b := NewBufferWithMaxMemorySize(maxSize)
+				defer b.Reset()
+
+				writeByChunks(require, b, []byte(tt.data), 7)
+				if tt.skip > 0 {
+					_, err := b.Read(make([]byte, tt.skip))
+					require.Nil(err)
+				}
+
+				contentType, err := b.DetectContentType()
+				require.Nil(err)
+				require.Equal(tt.expected, contentType)
+
+				// The read position must not be changed
+				require.Equal(tt.data[tt.skip:], string(readByChunks(require, b, 16)))
+			}
+		})
+	}
+}
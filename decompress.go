@@ -0,0 +1,97 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Compression is a compression format of data passed to ReadFromCompressed
+type Compression int
+
+const (
+	// CompressionAuto detects the format by magic bytes. Data in an unknown format is stored as is.
+	// zlib has a short header, so it should be set explicitly if plain data can start with a valid one
+	CompressionAuto Compression = iota
+	// CompressionGzip is the gzip format (RFC 1952). Concatenated gzip members are supported
+	CompressionGzip
+	// CompressionZlib is the zlib format (RFC 1950)
+	CompressionZlib
+	// CompressionBzip2 is the bzip2 format
+	CompressionBzip2
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// ReadFromGzip reads gzip-compressed data from r and writes the decompressed data into the Buffer.
+// It returns the number of decompressed bytes
+func (b *Buffer) ReadFromGzip(r io.Reader) (int64, error) {
+	return b.ReadFromCompressed(r, CompressionGzip)
+}
+
+// ReadFromCompressed reads data compressed with c from r and writes the decompressed data into
+// the Buffer, so consumers read plain data. It returns the number of decompressed bytes
+func (b *Buffer) ReadFromCompressed(r io.Reader, c Compression) (int64, error) {
+	if c == CompressionAuto {
+		br := bufio.NewReader(r)
+		c = detectCompression(br)
+		r = br
+		if c == CompressionAuto {
+			// Data isn't compressed
+			return b.ReadFrom(r)
+		}
+	}
+
+	var decompressor io.Reader
+	switch c {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, errors.Wrap(err, "can't read gzip header")
+		}
+		defer gr.Close()
+		decompressor = gr
+	case CompressionZlib:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return 0, errors.Wrap(err, "can't read zlib header")
+		}
+		defer zr.Close()
+		decompressor = zr
+	case CompressionBzip2:
+		decompressor = bzip2.NewReader(r)
+	default:
+		return 0, errors.Errorf("unknown compression: %d", c)
+	}
+
+	n, err := b.ReadFrom(decompressor)
+	if err != nil {
+		return n, errors.Wrap(err, "can't decompress data")
+	}
+	return n, nil
+}
+
+// detectCompression detects the compression format by magic bytes. It returns CompressionAuto
+// if the format is unknown
+func detectCompression(br *bufio.Reader) Compression {
+	// Errors are ignored: they will be returned on reading
+	header, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(header, bzip2Magic):
+		return CompressionBzip2
+	case len(header) >= 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0:
+		// zlib: the deflate method and a valid header checksum
+		return CompressionZlib
+	}
+	return CompressionAuto
+}
@@ -0,0 +1,71 @@
+package buffer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ReadFromCompressed(t *testing.T) {
+	// The prefix can't be detected as a zlib header
+	slice := []byte("data: " + generateRandomString(5000))
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(slice[:3000])
+	gw.Close()
+	// The second member
+	gw = gzip.NewWriter(&gzipped)
+	gw.Write(slice[3000:])
+	gw.Close()
+
+	var zlibbed bytes.Buffer
+	zw := zlib.NewWriter(&zlibbed)
+	zw.Write(slice)
+	zw.Close()
+
+	tests := []struct {
+		desc string
+		data []byte
+		c    Compression
+	}{
+		{desc: "gzip", data: gzipped.Bytes(), c: CompressionGzip},
+		{desc: "zlib", data: zlibbed.Bytes(), c: CompressionZlib},
+		{desc: "auto gzip", data: gzipped.Bytes(), c: CompressionAuto},
+		{desc: "auto zlib", data: zlibbed.Bytes(), c: CompressionAuto},
+		{desc: "auto plain", data: slice, c: CompressionAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+
+			n, err := b.ReadFromCompressed(bytes.NewReader(tt.data), tt.c)
+			require.Nil(err)
+			require.Equal(int64(len(slice)), n)
+			require.Equal(slice, readByChunks(require, b, 16))
+		})
+	}
+
+	t.Run("ReadFromGzip", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		n, err := b.ReadFromGzip(bytes.NewReader(gzipped.Bytes()))
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+
+		_, err = b.ReadFromGzip(bytes.NewReader(slice))
+		require.NotNil(err, "plain data must be rejected")
+
+		_, err = b.ReadFromGzip(bytes.NewReader(gzipped.Bytes()[:gzipped.Len()/2]))
+		require.NotNil(err, "truncated data must be rejected")
+	})
+}
@@ -0,0 +1,88 @@
+package buffer
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// deletionRetryPolicy defines how removals of temp files are retried when they are deferred.
+// With these values a file is removed during ~1.5 minutes
+var deletionRetryPolicy = RetryPolicy{
+	MaxRetries:     12,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     15 * time.Second,
+}
+
+var deletions struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+	hook    func(name string, err error)
+}
+
+// SetDeletionFailureHook sets a function that is called when a deferred removal of a temp file fails
+// after all retries. Removals are deferred on Windows when a file is still opened by another process
+// (an antivirus scanner, for example). If hook is nil, failures are logged with log.Printf
+func SetDeletionFailureHook(hook func(name string, err error)) {
+	deletions.mu.Lock()
+	defer deletions.mu.Unlock()
+
+	deletions.hook = hook
+}
+
+// PendingDeletions returns names of temp files whose removal is deferred and still being retried
+func PendingDeletions() []string {
+	deletions.mu.Lock()
+	defer deletions.mu.Unlock()
+
+	names := make([]string, 0, len(deletions.pending))
+	for name := range deletions.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deferRemoval retries removal of the file in a background goroutine with exponential backoff.
+// The failure hook is called if the file can't be removed after all retries
+func deferRemoval(name string, remove func(name string) error) {
+	deletions.mu.Lock()
+	if _, ok := deletions.pending[name]; ok {
+		deletions.mu.Unlock()
+		return
+	}
+	if deletions.pending == nil {
+		deletions.pending = make(map[string]struct{})
+	}
+	deletions.pending[name] = struct{}{}
+	deletions.mu.Unlock()
+
+	go func() {
+		var err error
+		for i := 0; i < deletionRetryPolicy.MaxRetries; i++ {
+			time.Sleep(deletionRetryPolicy.backoff(i))
+
+			err = remove(name)
+			if err == nil || os.IsNotExist(err) {
+				err = nil
+				break
+			}
+		}
+
+		deletions.mu.Lock()
+		delete(deletions.pending, name)
+		hook := deletions.hook
+		deletions.mu.Unlock()
+
+		if err == nil {
+			return
+		}
+		if hook != nil {
+			hook(name, err)
+			return
+		}
+		log.Printf("go-disk-buffer: can't remove temp file '%s': %s", name, err)
+	}()
+}
@@ -0,0 +1,62 @@
+package buffer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeferRemoval(t *testing.T) {
+	require := require.New(t)
+
+	oldPolicy := deletionRetryPolicy
+	deletionRetryPolicy = RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	defer func() {
+		deletionRetryPolicy = oldPolicy
+	}()
+
+	failures := make(chan string, 2)
+	SetDeletionFailureHook(func(name string, err error) {
+		failures <- name
+	})
+	defer SetDeletionFailureHook(nil)
+
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+	)
+	remove := func(name string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts[name]++
+		if name == "busy" || attempts[name] < 2 {
+			return errors.New("file is in use")
+		}
+		return nil
+	}
+
+	deferRemoval("busy", remove)
+	deferRemoval("unlocked", remove)
+	require.Equal([]string{"busy", "unlocked"}, PendingDeletions())
+
+	select {
+	case name := <-failures:
+		require.Equal("busy", name)
+	case <-time.After(5 * time.Second):
+		require.FailNow("failure wasn't reported")
+	}
+
+	waitFor(require, func() bool {
+		return len(PendingDeletions()) == 0
+	}, 5*time.Second, time.Millisecond)
+	require.Len(failures, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(3, attempts["busy"])
+	require.Equal(2, attempts["unlocked"])
+}
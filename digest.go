@@ -0,0 +1,63 @@
+package buffer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDigestMismatch is returned at the end of reading when the digest of read data doesn't match
+// the expected one
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// EnableDigestVerification makes the Buffer compute a digest of data with h as the Buffer is drained
+// and compare it with digest at the end of reading. If h is nil, SHA-256 is used. If digest is nil,
+// the hash of written data (see EnableHashing) is used: h must compute the same hash in this case.
+//
+// If the digests don't match, the last read returns ErrDigestMismatch instead of io.EOF (or nil).
+// The expected digest is kept after Reset(). It must be called before the first Read
+func (b *Buffer) EnableDigestVerification(h hash.Hash, digest []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h == nil {
+		h = sha256.New()
+	}
+	h.Reset()
+
+	b.readHash = h
+	b.expectedDigest = nil
+	if digest != nil {
+		b.expectedDigest = append([]byte{}, digest...)
+	}
+}
+
+// hashRead adds data consumed by reading to the digest
+func (b *Buffer) hashRead(data []byte) {
+	if b.readHash != nil {
+		b.readHash.Write(data)
+	}
+}
+
+// verifyDigest compares the digest of read data with the expected one
+func (b *Buffer) verifyDigest() error {
+	if b.readHash == nil {
+		return nil
+	}
+
+	expected := b.expectedDigest
+	if expected == nil {
+		if b.hash == nil {
+			return errors.New("can't verify digest: expected digest isn't passed and hashing is disabled")
+		}
+		expected = b.hash.Sum(nil)
+	}
+
+	actual := b.readHash.Sum(nil)
+	if !bytes.Equal(expected, actual) {
+		return errors.Wrapf(ErrDigestMismatch, "expected %x, got %x", expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_DigestVerification(t *testing.T) {
+	slice := []byte(generateRandomString(1000))
+	digest := sha256.Sum256(slice)
+
+	t.Run("Passed digest", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		b.EnableDigestVerification(nil, digest[:])
+
+		writeByChunks(require, b, slice, 7)
+		res := readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Recorded digest", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		b.EnableHashing(nil)
+		b.EnableDigestVerification(nil, nil)
+
+		writeByChunks(require, b, slice, 7)
+
+		var res []string
+		for {
+			line, err := b.ReadString('a')
+			res = append(res, line)
+			if err == io.EOF {
+				break
+			}
+			require.Nil(err)
+		}
+		require.Equal(string(slice), strings.Join(res, ""), "wrong content was read")
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		wrong := sha256.Sum256([]byte("hello"))
+		b.EnableDigestVerification(sha256.New(), wrong[:])
+
+		writeByChunks(require, b, slice, 7)
+
+		_, err := io.Copy(io.Discard, b)
+		require.Equal(ErrDigestMismatch, errors.Cause(err))
+	})
+}
@@ -0,0 +1,225 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDiskFullWaitInterval is an interval between retries of writes when DiskFullWait policy is used
+const DefaultDiskFullWaitInterval = time.Second
+
+// DiskFullPolicy defines what happens when a disk with temp files is full (ENOSPC).
+//
+// There's no policy that drops the oldest data: a Buffer isn't a ring buffer, all written data must be read
+type DiskFullPolicy int
+
+const (
+	// DiskFullFail makes the Buffer return ErrNoSpace immediately. It is the default policy
+	DiskFullFail DiskFullPolicy = iota
+	// DiskFullWait makes the Buffer block and retry until free space is available
+	DiskFullWait
+	// DiskFullSwitchDir makes the Buffer move the temp file into the next alternative directory
+	// and continue writing there. ErrNoSpace is returned when all directories are full
+	DiskFullSwitchDir
+)
+
+// SetDiskFullPolicy sets the policy for a full disk. dirs are alternative directories for temp files
+// used by DiskFullSwitchDir policy.
+//
+// Writes are retried or moved into another directory only if sparse files and writes through memory
+// mappings are disabled. Also the temp file isn't moved into another directory during asynchronous writes.
+// In these cases, only creation of a temp file follows the policy
+func (b *Buffer) SetDiskFullPolicy(policy DiskFullPolicy, dirs ...string) error {
+	absDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		stats, err := os.Stat(dir)
+		if err != nil {
+			return errors.Wrapf(err, "can't get stats of the directory '%s'", dir)
+		}
+		if !stats.IsDir() {
+			return errors.Errorf("'%s' is not a directory", dir)
+		}
+
+		path, err := filepath.Abs(dir)
+		if err != nil {
+			return errors.New("can't get an absolute path")
+		}
+		absDirs = append(absDirs, path)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.diskFullPolicy = policy
+	b.diskFullDirs = absDirs
+	b.diskFullWaitInterval = DefaultDiskFullWaitInterval
+
+	return nil
+}
+
+// IsDiskFull reports whether err is caused by a full disk or an exceeded disk quota
+func IsDiskFull(err error) bool {
+	return isDiskFullErrno(err)
+}
+
+// isNoSpace reports whether err means that there's not enough space to create a temp file
+func isNoSpace(err error) bool {
+	return IsDiskFull(err) || errors.Cause(err) == ErrNoSpace
+}
+
+// diskFullError converts err caused by a full disk into ErrNoSpace
+func diskFullError(dir string, err error) error {
+	if errors.Cause(err) == ErrNoSpace {
+		return err
+	}
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return errors.Wrapf(ErrNoSpace, "disk with directory '%s' is full: %s", dir, err)
+}
+
+// createTempFileOnFullDisk creates a temp file according to the disk-full policy after
+// creation of a temp file in dir failed with err
+func (b *Buffer) createTempFileOnFullDisk(dir string, required int64, err error) (*os.File, error) {
+	switch b.diskFullPolicy {
+	case DiskFullWait:
+		for isNoSpace(err) {
+			time.Sleep(b.diskFullWaitInterval)
+
+			var file *os.File
+			file, err = b.createTempFileIn(dir, required)
+			if err == nil {
+				return file, nil
+			}
+		}
+		return nil, err
+
+	case DiskFullSwitchDir:
+		for _, altDir := range b.diskFullDirs {
+			file, altErr := b.createTempFileIn(altDir, required)
+			if altErr == nil {
+				return file, nil
+			}
+			if !isNoSpace(altErr) {
+				return nil, altErr
+			}
+		}
+	}
+
+	return nil, diskFullError(dir, err)
+}
+
+// namedWriteCloser is an io.WriteCloser with a name (*os.File, for example)
+type namedWriteCloser interface {
+	io.WriteCloser
+	Name() string
+}
+
+// diskFullWriter writes data into a temp file and follows the disk-full policy when the disk is full
+type diskFullWriter struct {
+	file      namedWriteCloser
+	tempFiles TempFileStrategy
+	// written is the number of bytes written into the file
+	written int64
+
+	policy       DiskFullPolicy
+	waitInterval time.Duration
+	// dirs are remaining alternative directories
+	dirs []string
+	// onSwitch is called after the file was moved into another directory
+	onSwitch func(filename string)
+}
+
+func newDiskFullWriter(file namedWriteCloser, tempFiles TempFileStrategy, policy DiskFullPolicy,
+	waitInterval time.Duration, dirs []string, onSwitch func(filename string)) *diskFullWriter {
+
+	return &diskFullWriter{
+		file:         file,
+		tempFiles:    tempFiles,
+		policy:       policy,
+		waitInterval: waitInterval,
+		dirs:         dirs,
+		onSwitch:     onSwitch,
+	}
+}
+
+func (w *diskFullWriter) Write(p []byte) (n int, err error) {
+	for {
+		written, err := w.file.Write(p[n:])
+		n += written
+		w.written += int64(written)
+		if err == nil || !IsDiskFull(err) {
+			return n, err
+		}
+
+		switch w.policy {
+		case DiskFullWait:
+			time.Sleep(w.waitInterval)
+		case DiskFullSwitchDir:
+			switchErr := w.switchDir()
+			if switchErr != nil {
+				return n, err
+			}
+		default:
+			return n, err
+		}
+	}
+}
+
+// switchDir moves the file into the next alternative directory with enough free space
+func (w *diskFullWriter) switchDir() error {
+	for len(w.dirs) > 0 {
+		dir := w.dirs[0]
+		w.dirs = w.dirs[1:]
+
+		newFile, err := w.copyFile(dir)
+		if err != nil {
+			if IsDiskFull(err) {
+				continue
+			}
+			return err
+		}
+
+		oldFilename := w.file.Name()
+		w.file.Close()
+		w.tempFiles.Remove(oldFilename)
+
+		w.file = newFile
+		w.onSwitch(newFile.Name())
+
+		return nil
+	}
+
+	return errors.New("all directories are full")
+}
+
+// copyFile creates a new temp file in dir and copies the written data into it
+func (w *diskFullWriter) copyFile(dir string) (*os.File, error) {
+	oldFile, err := w.tempFiles.Open(w.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer oldFile.Close()
+
+	newFile, err := w.tempFiles.Create(dir, "go-disk-buffer-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.CopyN(newFile, oldFile, w.written)
+	if err != nil {
+		newFile.Close()
+		w.tempFiles.Remove(newFile.Name())
+		return nil, err
+	}
+
+	return newFile, nil
+}
+
+func (w *diskFullWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package buffer
+
+func isDiskFullErrno(error) bool {
+	return false
+}
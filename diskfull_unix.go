@@ -0,0 +1,17 @@
+//go:build unix
+
+package buffer
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func isDiskFullErrno(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.ENOSPC || errno == syscall.EDQUOT
+}
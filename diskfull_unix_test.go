@@ -0,0 +1,107 @@
+//go:build unix
+
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// fullFile is a file that fails with ENOSPC after limit bytes were written. The limit
+// is increased by step after every failure
+type fullFile struct {
+	*os.File
+	limit int64
+	step  int64
+
+	written int64
+}
+
+func (f *fullFile) Write(p []byte) (int, error) {
+	var err error
+	if f.written+int64(len(p)) > f.limit {
+		p = p[:f.limit-f.written]
+		err = &os.PathError{Op: "write", Path: f.Name(), Err: syscall.ENOSPC}
+		f.limit += f.step
+	}
+
+	n, _ := f.File.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+func TestDiskFullWriter(t *testing.T) {
+	data := []byte(generateRandomString(1 << 16))
+
+	t.Run("Wait", func(t *testing.T) {
+		require := require.New(t)
+
+		file, err := ioutil.TempFile("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.Remove(file.Name())
+
+		w := newDiskFullWriter(&fullFile{File: file, limit: 1000, step: 1000}, DefaultTempFileStrategy, DiskFullWait, time.Millisecond, nil, nil)
+		n, err := w.Write(data)
+		require.Nil(err)
+		require.Equal(len(data), n)
+		require.Nil(w.Close())
+
+		res, err := ioutil.ReadFile(file.Name())
+		require.Nil(err)
+		require.Equal(data, res)
+	})
+
+	t.Run("Switch dir", func(t *testing.T) {
+		require := require.New(t)
+
+		dir1, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir1)
+		dir2, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir2)
+
+		file, err := ioutil.TempFile(dir1, "go-disk-buffer-test-")
+		require.Nil(err)
+
+		var filename string
+		w := newDiskFullWriter(&fullFile{File: file, limit: 1000}, DefaultTempFileStrategy, DiskFullSwitchDir, 0, []string{dir2}, func(name string) {
+			filename = name
+		})
+		n, err := w.Write(data)
+		require.Nil(err)
+		require.Equal(len(data), n)
+		require.Nil(w.Close())
+
+		require.Equal(dir2, filepath.Dir(filename))
+		_, err = os.Stat(file.Name())
+		require.True(os.IsNotExist(err), "old file must be removed")
+
+		res, err := ioutil.ReadFile(filename)
+		require.Nil(err)
+		require.Equal(data, res)
+	})
+
+	t.Run("All dirs are full", func(t *testing.T) {
+		require := require.New(t)
+
+		file, err := ioutil.TempFile("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.Remove(file.Name())
+
+		w := newDiskFullWriter(&fullFile{File: file, limit: 1000}, DefaultTempFileStrategy, DiskFullSwitchDir, 0, nil, nil)
+		n, err := w.Write(data)
+		require.True(IsDiskFull(err))
+		require.Equal(1000, n)
+
+		err = diskFullError(os.TempDir(), err)
+		require.Equal(ErrNoSpace, errors.Cause(err))
+	})
+}
@@ -0,0 +1,22 @@
+package buffer
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Windows error codes. See https://learn.microsoft.com/en-us/windows/win32/debug/system-error-codes
+const (
+	errorHandleDiskFull syscall.Errno = 39
+	errorDiskFull       syscall.Errno = 112
+	errorDiskQuota      syscall.Errno = 1295
+)
+
+func isDiskFullErrno(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorHandleDiskFull || errno == errorDiskFull || errno == errorDiskQuota
+}
@@ -0,0 +1,18 @@
+package buffer
+
+// EnableEagerSpill creates and opens the temp file immediately, so problems with the temp dir
+// (permissions, a full disk, etc.) are reported at once rather than in the middle of writing.
+// The first write that exceeds maxInMemorySize doesn't pay for creation of the file either.
+//
+// Data is still stored in memory while it fits. If the temp file isn't used, it is removed when writing
+// is finished. After Reset() the temp file is created on demand. EnableEagerSpill must be called before
+// the first Write, after all other options
+func (b *Buffer) EnableEagerSpill() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.writeFile != nil || b.useFile || b.writingFinished {
+		return nil
+	}
+	return b.createWriteFile(0)
+}
@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EagerSpill(t *testing.T) {
+	t.Run("File isn't used", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		require.Nil(b.EnableEagerSpill())
+		filename := b.filename
+		_, err := os.Stat(filename)
+		require.Nil(err, "temp file must be created")
+
+		slice := []byte(generateRandomString(50))
+		writeByChunks(require, b, slice, 7)
+
+		res := readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+
+		_, err = os.Stat(filename)
+		require.True(os.IsNotExist(err), "unused temp file must be removed")
+	})
+
+	t.Run("File is used", func(t *testing.T) {
+		for _, encrypt := range []bool{false, true} {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+
+			if encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			require.Nil(b.EnableEagerSpill())
+			filename := b.filename
+
+			slice := []byte(generateRandomString(1000))
+			writeByChunks(require, b, slice, 7)
+			require.Equal(filename, b.filename, "temp file must be reused")
+
+			res := readByChunks(require, b, 16)
+			require.Equal(slice, res, "wrong content was read")
+		}
+	})
+
+	t.Run("Fail fast", func(t *testing.T) {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		require.Nil(b.ChangeTempDir(dir))
+		require.Nil(os.Remove(dir))
+
+		require.NotNil(b.EnableEagerSpill())
+	})
+}
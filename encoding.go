@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Encoding is a text encoding of binary data used by EncodeTo and DecodeFrom
+type Encoding int
+
+const (
+	// Base64 is the standard base64 encoding (RFC 4648)
+	Base64 Encoding = iota
+	// Base64URL is the URL-safe base64 encoding (RFC 4648)
+	Base64URL
+	// Hex is the hexadecimal encoding
+	Hex
+)
+
+// EncodeTo drains the Buffer and writes its content into w encoded with enc. The content is
+// encoded as a stream, so it isn't stored in memory at once. It returns the number of bytes
+// read from the Buffer
+func (b *Buffer) EncodeTo(w io.Writer, enc Encoding) (int64, error) {
+	var encoder io.WriteCloser
+	switch enc {
+	case Base64:
+		encoder = base64.NewEncoder(base64.StdEncoding, w)
+	case Base64URL:
+		encoder = base64.NewEncoder(base64.URLEncoding, w)
+	case Hex:
+		encoder = nopWriteCloser{hex.NewEncoder(w)}
+	default:
+		return 0, errors.Errorf("unknown encoding: %d", enc)
+	}
+
+	n, err := b.WriteTo(encoder)
+	if err != nil {
+		return n, err
+	}
+	// Flush the last partial block
+	err = encoder.Close()
+	if err != nil {
+		return n, errors.Wrap(err, "can't finish encoding")
+	}
+	return n, nil
+}
+
+// DecodeFrom reads data encoded with enc from r and writes the decoded data into the Buffer.
+// It returns the number of decoded bytes
+func (b *Buffer) DecodeFrom(r io.Reader, enc Encoding) (int64, error) {
+	var decoder io.Reader
+	switch enc {
+	case Base64:
+		decoder = base64.NewDecoder(base64.StdEncoding, r)
+	case Base64URL:
+		decoder = base64.NewDecoder(base64.URLEncoding, r)
+	case Hex:
+		decoder = hex.NewDecoder(r)
+	default:
+		return 0, errors.Errorf("unknown encoding: %d", enc)
+	}
+
+	n, err := b.ReadFrom(decoder)
+	if err != nil {
+		return n, errors.Wrap(err, "can't decode data")
+	}
+	return n, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
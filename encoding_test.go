@@ -0,0 +1,54 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EncodeTo_DecodeFrom(t *testing.T) {
+	slice := []byte(generateRandomString(1000))
+
+	tests := []struct {
+		enc     Encoding
+		encoded string
+	}{
+		{enc: Base64, encoded: base64.StdEncoding.EncodeToString(slice)},
+		{enc: Base64URL, encoded: base64.URLEncoding.EncodeToString(slice)},
+		{enc: Hex, encoded: hex.EncodeToString(slice)},
+	}
+	for _, tt := range tests {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		writeByChunks(require, b, slice, 7)
+
+		var encoded strings.Builder
+		n, err := b.EncodeTo(&encoded, tt.enc)
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+		require.Equal(tt.encoded, encoded.String())
+		require.Equal(0, b.Len())
+		b.Reset()
+
+		n, err = b.DecodeFrom(strings.NewReader(tt.encoded), tt.enc)
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+		require.Equal(slice, readByChunks(require, b, 16))
+		b.Reset()
+
+		_, err = b.DecodeFrom(strings.NewReader("!!!"), tt.enc)
+		require.NotNil(err)
+		b.Reset()
+	}
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	_, err := b.EncodeTo(&bytes.Buffer{}, Encoding(100))
+	require.NotNil(t, err)
+}
@@ -0,0 +1,223 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTampered is returned when encrypted data read from a temp file fails authentication.
+// It means that the temp file was modified outside of the Buffer
+var ErrTampered = errors.New("encrypted data was tampered with")
+
+// encryptionKeyCopy returns a copy of the encryption key. The key is copied because sio keeps
+// the passed slice and the key can be changed by RotateEncryptionKey
+func (b *Buffer) encryptionKeyCopy() ([]byte, error) {
+	key, err := b.key()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), key...), nil
+}
+
+// newEncryptWriter returns a writer that encrypts data and writes it into w.
+// Close of the returned writer closes w if it implements io.Closer
+func (b *Buffer) newEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	if b.aead != nil {
+		return newAEADWriter(w, b.aead)
+	}
+	key, err := b.encryptionKeyCopy()
+	if err != nil {
+		return nil, err
+	}
+	return newDefaultEncryptWriter(w, b.encryptionConfig, key)
+}
+
+// newDecryptReader returns a reader that decrypts data read from r
+func (b *Buffer) newDecryptReader(r io.Reader) (io.Reader, error) {
+	if b.aead != nil {
+		return newAEADReader(r, b.aead), nil
+	}
+
+	key, err := b.encryptionKeyCopy()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newDefaultDecryptReader(r, b.encryptionConfig, key)
+	if err != nil {
+		return nil, err
+	}
+	return decryptReader{reader}, nil
+}
+
+// newDecryptReaderAt returns a reader that decrypts data read from r. size is the size of encrypted data
+func (b *Buffer) newDecryptReaderAt(r io.ReaderAt, size int64) (io.ReaderAt, error) {
+	if b.aead != nil {
+		return newAEADReaderAt(r, size, b.aead)
+	}
+
+	key, err := b.encryptionKeyCopy()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newDefaultDecryptReaderAt(r, size, b.encryptionConfig, key)
+	if err != nil {
+		return nil, err
+	}
+	return decryptReaderAt{reader}, nil
+}
+
+// wrapDecryptionError converts authentication errors into ErrTampered
+func wrapDecryptionError(err error) error {
+	if err != nil && isAuthenticationError(err) {
+		return errors.Wrap(ErrTampered, err.Error())
+	}
+	return err
+}
+
+// decryptReader converts decryption errors of the underlying reader into ErrTampered
+type decryptReader struct {
+	r io.Reader
+}
+
+func (dr decryptReader) Read(p []byte) (int, error) {
+	n, err := dr.r.Read(p)
+	return n, wrapDecryptionError(err)
+}
+
+// decryptReaderAt converts decryption errors of the underlying reader into ErrTampered
+type decryptReaderAt struct {
+	r io.ReaderAt
+}
+
+func (dr decryptReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := dr.r.ReadAt(p, off)
+	return n, wrapDecryptionError(err)
+}
+
+// RotateEncryptionKey re-encrypts the temp file with newKey. The data is copied into a new temp file
+// as a stream, so memory usage doesn't depend on the file size. The Buffer can be used as usual after
+// the rotation: the current read position is kept, Write continues to append data if writing isn't finished.
+//
+// newKey must be 32 bytes long. If the rotation fails during writing, the Buffer can be only read.
+// Keys of files that don't belong to the Buffer (opened with OpenExported or OpenEncrypted) or are shared
+// with clones can't be rotated
+func (b *Buffer) RotateEncryptionKey(newKey []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.encrypt {
+		return errors.New("encryption is disabled")
+	}
+	if b.aead != nil {
+		return errors.New("key of custom cipher.AEAD can't be rotated")
+	}
+	if len(b.wrappedKey) != 0 {
+		return errors.New("key generated by KeyProvider can't be rotated")
+	}
+	if b.groupFile != nil {
+		return errors.New("key of a Buffer in a spill group can't be rotated")
+	}
+	if b.sharedFile != nil {
+		return errors.New("key of a temp file shared with clones can't be rotated")
+	}
+	if b.keepFile {
+		// The file doesn't belong to the Buffer (see OpenExported, for example): it must not be replaced
+		return errors.New("key of a file that doesn't belong to the Buffer can't be rotated")
+	}
+	if len(newKey) != len(b.encryptionKey) {
+		return errors.Errorf("invalid key size: %d, expected %d", len(newKey), len(b.encryptionKey))
+	}
+	key, err := b.key()
+	if err != nil {
+		return err
+	}
+
+	if b.filename == "" {
+		// There's no data on a disk
+		copy(key, newKey)
+		return nil
+	}
+
+	writing := !b.writingFinished
+	if b.writeFile != nil {
+		// Finish the current encryption stream
+		err := b.writeFile.Close()
+		b.writeFile = nil
+		b.writeTempFile = nil
+		if err != nil {
+			b.writingFinished = true
+			b.writeErr = errors.Wrap(err, "can't finish writing into a temp file")
+			return b.writeErr
+		}
+	}
+
+	var (
+		oldKey            = append([]byte(nil), key...)
+		oldFilename       = b.filename
+		oldMirrorFilename = b.mirrorFilename
+		oldChecksums      = b.checksums
+		oldSealedHeader   = b.sealedHeader
+		oldUnlinkedFile   = b.unlinkedFile
+	)
+	restore := func() {
+		copy(key, oldKey)
+		b.filename = oldFilename
+		b.mirrorFilename = oldMirrorFilename
+		b.checksums = oldChecksums
+		b.sealedHeader = oldSealedHeader
+		b.restoreUnlinkedFile(oldUnlinkedFile)
+		if b.fileLock != nil && b.fileLock.Name() != oldFilename {
+			// The lock of the old file was released by createWriteFile
+			b.lockTempFile()
+		}
+		// The old encryption stream is finished. So, we can't append data anymore
+		b.writingFinished = true
+	}
+
+	src, err := b.openReadFile()
+	if err != nil {
+		restore()
+		return err
+	}
+	defer src.Close()
+
+	copy(key, newKey)
+	err = b.createWriteFile(b.fileSize - b.fileDropped)
+	if err != nil {
+		restore()
+		return err
+	}
+
+	n, err := io.Copy(b.writeFile, src)
+	if err == nil && n != b.fileSize-b.fileDropped {
+		err = errors.Errorf("temp file contains %d bytes, expected %d", n, b.fileSize-b.fileDropped)
+	}
+	if err == nil && !writing {
+		err = b.writeFile.Close()
+		b.writeFile = nil
+		b.writeTempFile = nil
+	}
+	if err != nil {
+		if b.writeFile != nil {
+			b.writeFile.Close()
+			b.writeFile = nil
+			b.writeTempFile = nil
+		}
+		b.tempFiles().Remove(b.filename)
+		if b.mirrorFilename != oldMirrorFilename {
+			b.removeMirrorFile()
+		}
+		restore()
+		return errors.Wrap(err, "can't re-encrypt the temp file")
+	}
+
+	b.tempFiles().Remove(oldFilename)
+	if oldMirrorFilename != "" {
+		b.tempFiles().Remove(oldMirrorFilename)
+	}
+	b.replaceUnlinkedFile(oldUnlinkedFile)
+	b.closeReadAtFile()
+
+	return b.reopenReadFile()
+}
@@ -0,0 +1,37 @@
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+// EncryptionConfig configures the format of data encrypted with github.com/minio/sio (DARE).
+// Versions and cipher suites are constants of package sio (sio.Version20, sio.AES_256_GCM, etc.).
+// Zero values mean defaults of sio. Builds with tag 'nosio' accept only the zero config
+type EncryptionConfig struct {
+	// MinVersion is the minimal version of the format accepted during decryption
+	MinVersion byte
+	// MaxVersion is the version of the format used for encryption and the max version accepted
+	// during decryption
+	MaxVersion byte
+	// CipherSuites is a list of allowed cipher suites. The first supported one is used for encryption
+	CipherSuites []byte
+}
+
+// SetEncryptionConfig pins the format of encrypted data. It allows to keep temp files and exported files
+// (see ExportEncrypted) readable by other versions of a service. It doesn't affect a custom cipher.AEAD
+// (see EnableEncryptionWithAEAD). It must be called before the first Write
+func (b *Buffer) SetEncryptionConfig(cfg EncryptionConfig) error {
+	cfg.CipherSuites = append([]byte(nil), cfg.CipherSuites...)
+
+	err := cfg.validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid encryption config")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.encryptionConfig = cfg
+
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build !nosio
+
+package buffer
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/minio/sio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SetEncryptionConfig(t *testing.T) {
+	for _, version := range []byte{sio.Version10, sio.Version20} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(100 << 10))
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		require.Nil(b.EnableEncryption())
+		require.Nil(b.SetEncryptionConfig(EncryptionConfig{
+			MinVersion:   version,
+			MaxVersion:   version,
+			CipherSuites: []byte{sio.CHACHA20_POLY1305},
+		}))
+
+		writeByChunks(require, b, slice, 1024)
+		require.Nil(b.Flush())
+
+		// The first byte of a DARE package is the version
+		data, err := ioutil.ReadFile(b.filename)
+		require.Nil(err)
+		require.Equal(version, data[0])
+
+		res := make([]byte, 100)
+		n, err := b.ReadAt(res, 70000)
+		require.Nil(err)
+		require.Equal(slice[70000:70100], res[:n], "wrong content was read")
+
+		res = readByChunks(require, b, 1024)
+		require.Equal(slice, res, "wrong content was read")
+	}
+
+	b := NewBufferWithMaxMemorySize(10)
+	require.NotNil(t, b.SetEncryptionConfig(EncryptionConfig{MinVersion: sio.Version20, MaxVersion: sio.Version10}))
+	require.NotNil(t, b.SetEncryptionConfig(EncryptionConfig{CipherSuites: []byte{42}}))
+}
@@ -0,0 +1,158 @@
+//go:build nosio
+
+package buffer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// This file contains the encryption format implemented only with the standard library. It is used
+// instead of DARE (github.com/minio/sio) when the package is built with tag 'nosio'. Data is encrypted
+// with AES-256-GCM in chunks of 64 KB with per-chunk nonces and sequence numbers, see aeadWriter.
+// The key of a Buffer is used for several streams (see Compact, for example), and random 4-byte nonce
+// prefixes of AES-GCM could collide. So, every stream is encrypted with its own key derived from the key
+// of the Buffer and a random salt. The salt is stored before the stream:
+//
+//	salt (32 bytes) | aeadWriter output
+//
+// The format isn't compatible with DARE: files can be opened only by builds with tag 'nosio'
+
+// validate accepts only the default config: versions and cipher suites of DARE aren't supported
+func (cfg EncryptionConfig) validate() error {
+	if cfg.MinVersion != 0 || cfg.MaxVersion != 0 || len(cfg.CipherSuites) != 0 {
+		return errors.New("DARE versions and cipher suites aren't supported in builds with tag 'nosio'")
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create a cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamSaltSize is the size of the salt used to derive the key of a stream
+const streamSaltSize = 32
+
+// deriveStreamKey derives the key of a stream from key and salt with HKDF-SHA256 (RFC 5869)
+func deriveStreamKey(key, salt []byte) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(key)
+	prk := extract.Sum(nil)
+
+	// The key is 32 bytes long, so a single block of the output is enough
+	expand := hmac.New(sha256.New, prk)
+	expand.Write([]byte("go-disk-buffer stream key"))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// newDefaultEncryptWriter returns a writer that encrypts data with key and writes it into w.
+// Close of the returned writer closes w if it implements io.Closer
+func newDefaultEncryptWriter(w io.Writer, cfg EncryptionConfig, key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, streamSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read random data")
+	}
+	gcm, err := newGCM(deriveStreamKey(key, salt))
+	if err != nil {
+		return nil, err
+	}
+
+	aw, err := newAEADWriter(w, gcm)
+	if err != nil {
+		return nil, err
+	}
+	aw.header = salt
+	return aw, nil
+}
+
+// newDefaultDecryptReader returns a reader that decrypts data read from r with key
+func newDefaultDecryptReader(r io.Reader, cfg EncryptionConfig, key []byte) (io.Reader, error) {
+	return &streamDecryptReader{r: r, key: key}, nil
+}
+
+// streamDecryptReader reads the salt of a stream and decrypts the stream with the derived key
+type streamDecryptReader struct {
+	r   io.Reader
+	key []byte
+
+	ar  *aeadReader
+	err error
+}
+
+func (sr *streamDecryptReader) Read(p []byte) (int, error) {
+	if sr.ar == nil {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+
+		salt := make([]byte, streamSaltSize)
+		_, err := io.ReadFull(sr.r, salt)
+		if err != nil {
+			sr.err = errors.Wrap(ErrTampered, "can't read salt")
+			return 0, sr.err
+		}
+		gcm, err := newGCM(deriveStreamKey(sr.key, salt))
+		if err != nil {
+			sr.err = err
+			return 0, err
+		}
+		sr.ar = newAEADReader(sr.r, gcm)
+	}
+	return sr.ar.Read(p)
+}
+
+// newDefaultDecryptReaderAt returns a reader that decrypts data read from r with key. size is the size of encrypted data
+func newDefaultDecryptReaderAt(r io.ReaderAt, size int64, cfg EncryptionConfig, key []byte) (io.ReaderAt, error) {
+	salt := make([]byte, streamSaltSize)
+	n, err := r.ReadAt(salt, 0)
+	if n < len(salt) {
+		if err == nil || err == io.EOF {
+			err = errors.Wrap(ErrTampered, "can't read salt")
+		}
+		return nil, err
+	}
+	gcm, err := newGCM(deriveStreamKey(key, salt))
+	if err != nil {
+		return nil, err
+	}
+	return newAEADReaderAt(io.NewSectionReader(r, streamSaltSize, size-streamSaltSize), size-streamSaltSize, gcm)
+}
+
+// defaultEncryptionFormat identifies the default format (AES-256-GCM chunks of aeadWriter with
+// a salt of the stream key) in exported containers. Format 2 didn't have the salt
+const defaultEncryptionFormat = 3
+
+// defaultDecryptedSize returns the size of data encrypted into size bytes
+func defaultDecryptedSize(size int64) (int64, error) {
+	const (
+		prefixSize = streamSaltSize + 12 - 8
+		overhead   = 16
+		pkgSize    = aeadChunkSize + overhead
+	)
+
+	dataSize := size - prefixSize
+	chunks := (dataSize + pkgSize - 1) / pkgSize
+	// The final chunk contains at least the tag
+	if chunks <= 0 || dataSize-(chunks-1)*pkgSize < overhead {
+		return 0, errors.New("invalid size of encrypted data")
+	}
+	return dataSize - chunks*overhead, nil
+}
+
+// isAuthenticationError reports whether err is returned because data can't be authenticated.
+// aeadReader and aeadReaderAt return ErrTampered already
+func isAuthenticationError(err error) bool {
+	return false
+}
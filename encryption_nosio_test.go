@@ -0,0 +1,62 @@
+//go:build nosio
+
+package buffer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EncryptionNoSIO(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	require.NotNil(b.SetEncryptionConfig(EncryptionConfig{MaxVersion: 0x20}))
+	require.Nil(b.EnableEncryption())
+
+	slice := []byte(generateRandomString(200 << 10))
+	writeByChunks(require, b, slice, 4096)
+
+	require.Equal(slice, readByChunks(require, b, 4096))
+
+	// Sizes of encrypted data are checked by TestOpenEncrypted
+	for _, size := range []int64{0, 4, streamSaltSize + 4, streamSaltSize + 19} {
+		_, err := defaultDecryptedSize(size)
+		require.NotNil(err)
+	}
+	size, err := defaultDecryptedSize(streamSaltSize + 4 + 16)
+	require.Nil(err)
+	require.Equal(int64(0), size)
+}
+
+func TestBuffer_EncryptionNoSIO_StreamKeys(t *testing.T) {
+	require := require.New(t)
+
+	key := []byte(generateRandomString(32))
+	encrypt := func() []byte {
+		var buf bytes.Buffer
+		w, err := newDefaultEncryptWriter(&buf, EncryptionConfig{}, key)
+		require.Nil(err)
+		_, err = w.Write(make([]byte, 1024))
+		require.Nil(err)
+		require.Nil(w.Close())
+		return buf.Bytes()
+	}
+
+	// Streams under the same key must have different salts and so different keys
+	first, second := encrypt(), encrypt()
+	require.NotEqual(first[:streamSaltSize], second[:streamSaltSize])
+	require.NotEqual(deriveStreamKey(key, first[:streamSaltSize]), deriveStreamKey(key, second[:streamSaltSize]))
+	require.NotEqual(first[streamSaltSize:], second[streamSaltSize:])
+
+	r, err := newDefaultDecryptReader(bytes.NewReader(first), EncryptionConfig{}, key)
+	require.Nil(err)
+	data, err := ioutil.ReadAll(r)
+	require.Nil(err)
+	require.Equal(make([]byte, 1024), data)
+}
@@ -0,0 +1,62 @@
+//go:build !nosio
+
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/minio/sio"
+	"github.com/pkg/errors"
+)
+
+// This file contains the default encryption format: DARE implemented by github.com/minio/sio.
+// Build with tag 'nosio' to use the format implemented only with the standard library (see encryption_nosio.go)
+
+// sioConfig returns a config for sio with key
+func (cfg EncryptionConfig) sioConfig(key []byte) sio.Config {
+	return sio.Config{
+		MinVersion:   cfg.MinVersion,
+		MaxVersion:   cfg.MaxVersion,
+		CipherSuites: cfg.CipherSuites,
+		Key:          key,
+	}
+}
+
+// validate checks the config with a dummy key
+func (cfg EncryptionConfig) validate() error {
+	_, err := sio.EncryptWriter(ioutil.Discard, cfg.sioConfig(make([]byte, 32)))
+	return err
+}
+
+// newDefaultEncryptWriter returns a writer that encrypts data with key and writes it into w.
+// Close of the returned writer closes w if it implements io.Closer
+func newDefaultEncryptWriter(w io.Writer, cfg EncryptionConfig, key []byte) (io.WriteCloser, error) {
+	return sio.EncryptWriter(w, cfg.sioConfig(key))
+}
+
+// newDefaultDecryptReader returns a reader that decrypts data read from r with key
+func newDefaultDecryptReader(r io.Reader, cfg EncryptionConfig, key []byte) (io.Reader, error) {
+	return sio.DecryptReader(r, cfg.sioConfig(key))
+}
+
+// newDefaultDecryptReaderAt returns a reader that decrypts data read from r with key. size is the size of encrypted data
+func newDefaultDecryptReaderAt(r io.ReaderAt, size int64, cfg EncryptionConfig, key []byte) (io.ReaderAt, error) {
+	return sio.DecryptReaderAt(r, cfg.sioConfig(key))
+}
+
+// defaultEncryptionFormat identifies the default format (DARE) in exported containers
+const defaultEncryptionFormat = 1
+
+// defaultDecryptedSize returns the size of data encrypted into size bytes
+func defaultDecryptedSize(size int64) (int64, error) {
+	decrypted, err := sio.DecryptedSize(uint64(size))
+	return int64(decrypted), err
+}
+
+// isAuthenticationError reports whether err is returned because data can't be authenticated
+// or has an invalid format. sio returns sio.Error only in these cases
+func isAuthenticationError(err error) bool {
+	var sioErr sio.Error
+	return errors.As(err, &sioErr)
+}
@@ -0,0 +1,190 @@
+package buffer
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Tampered(t *testing.T) {
+	const size = 200 << 10
+
+	tests := []struct {
+		desc   string
+		damage func(require *require.Assertions, filename string)
+	}{
+		{
+			desc: "Modified file",
+			damage: func(require *require.Assertions, filename string) {
+				f, err := os.OpenFile(filename, os.O_RDWR, 0)
+				require.Nil(err)
+				defer f.Close()
+
+				_, err = f.WriteAt([]byte("!!!"), 70<<10)
+				require.Nil(err)
+			},
+		},
+		{
+			desc: "Truncated file",
+			damage: func(require *require.Assertions, filename string) {
+				require.Nil(os.Truncate(filename, 100<<10))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			newBuffer := func() *Buffer {
+				b := NewBufferWithMaxMemorySize(0)
+				require.Nil(b.EnableEncryption())
+
+				_, err := b.Write([]byte(generateRandomString(size)))
+				require.Nil(err)
+				require.Nil(b.finishWriting())
+				tt.damage(require, b.filename)
+
+				return b
+			}
+
+			// Read
+			b := newBuffer()
+			defer b.Reset()
+
+			data := make([]byte, 1024)
+			var err error
+			for err == nil {
+				_, err = b.Read(data)
+			}
+			require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+
+			// ReadAt
+			b = newBuffer()
+			defer b.Reset()
+
+			data = make([]byte, size)
+			_, err = b.ReadAt(data, 0)
+			require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+		})
+	}
+}
+
+func TestBuffer_RotateEncryptionKey(t *testing.T) {
+	newKey := func() []byte {
+		key := make([]byte, 32)
+		rand.Read(key)
+		return key
+	}
+
+	t.Run("During writing", func(t *testing.T) {
+		require := require.New(t)
+
+		data := []byte(generateRandomString(300 << 10))
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+		b.EnableChecksums()
+
+		writeByChunks(require, b, data[:100<<10], 4096)
+		oldFilename := b.filename
+
+		key := newKey()
+		require.Nil(b.RotateEncryptionKey(key))
+		require.NotEqual(oldFilename, b.filename)
+		require.Equal(key, b.encryptionKey[:])
+
+		_, err := os.Stat(oldFilename)
+		require.True(os.IsNotExist(err), "old temp file must be removed")
+
+		// Continue writing
+		_, err = b.Write(data[100<<10:])
+		require.Nil(err)
+
+		require.Equal(data, readByChunks(require, b, 1000))
+	})
+
+	t.Run("During reading", func(t *testing.T) {
+		require := require.New(t)
+
+		data := []byte(generateRandomString(300 << 10))
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+		b.EnableReadAhead(0, 2)
+
+		_, err := b.Write(data)
+		require.Nil(err)
+
+		res := make([]byte, 150<<10)
+		_, err = io.ReadFull(b, res)
+		require.Nil(err)
+
+		require.Nil(b.RotateEncryptionKey(newKey()))
+
+		res = append(res, readByChunks(require, b, 1000)...)
+		require.Equal(data, res)
+
+		// Rotation doesn't resume writing
+		_, err = b.Write([]byte("test"))
+		require.Equal(ErrBufferFinished, err)
+	})
+
+	t.Run("ReadAt", func(t *testing.T) {
+		require := require.New(t)
+
+		data := []byte(generateRandomString(100 << 10))
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+
+		_, err := b.Write(data)
+		require.Nil(err)
+
+		res := make([]byte, 10)
+		_, err = b.ReadAt(res, 5000)
+		require.Nil(err)
+
+		require.Nil(b.RotateEncryptionKey(newKey()))
+
+		_, err = b.ReadAt(res, 50000)
+		require.Nil(err)
+		require.Equal(data[50000:50010], res)
+	})
+
+	t.Run("No file", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+
+		key := newKey()
+		require.Nil(b.RotateEncryptionKey(key))
+		require.Equal(key, b.encryptionKey[:])
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+
+		require.NotNil(b.RotateEncryptionKey(newKey()), "encryption is disabled")
+
+		require.Nil(b.EnableEncryption())
+		require.NotNil(b.RotateEncryptionKey([]byte("short key")))
+	})
+}
@@ -0,0 +1,66 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+)
+
+// Equal reports whether the unread portions of the Buffers are equal. The content is compared by chunks
+// with ReadAt, so the read positions aren't changed. Equal finishes writing of both Buffers
+func (b *Buffer) Equal(other *Buffer) (bool, error) {
+	if b == other {
+		return true, nil
+	}
+
+	other.mu.Lock()
+	otherStart, otherSize := other.offset, other.size
+	other.mu.Unlock()
+
+	if otherSize-otherStart != b.Len64() {
+		return false, nil
+	}
+	return b.EqualReader(io.NewSectionReader(other, otherStart, otherSize-otherStart))
+}
+
+// EqualReader reports whether the unread portion of the Buffer is equal to the data read from r.
+// r is read till the first difference. The Buffer is read with ReadAt, so the read position isn't changed.
+// EqualReader finishes writing
+func (b *Buffer) EqualReader(r io.Reader) (bool, error) {
+	b.mu.Lock()
+	err := b.finishWriting()
+	start, size := b.offset, b.size
+	b.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		chunk      = make([]byte, readFromChunkSize)
+		otherChunk = make([]byte, readFromChunkSize)
+	)
+	for off := start; off < size; {
+		n, err := b.ReadAt(chunk, off)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if n == 0 {
+			break
+		}
+
+		otherN, err := io.ReadFull(r, otherChunk[:n])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return false, err
+		}
+		if otherN != n || !bytes.Equal(chunk[:n], otherChunk[:n]) {
+			return false, nil
+		}
+		off += int64(n)
+	}
+
+	// r must not contain more data
+	otherN, err := io.ReadFull(r, otherChunk[:1])
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return otherN == 0, nil
+}
@@ -0,0 +1,55 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Equal(t *testing.T) {
+	require := require.New(t)
+
+	data := []byte(generateRandomString(3*readFromChunkSize + 17))
+
+	b1 := NewBufferWithMaxMemorySize(100)
+	defer b1.Reset()
+	_, err := b1.Write(data)
+	require.Nil(err)
+
+	b2 := NewBufferWithMaxMemorySize(len(data))
+	defer b2.Reset()
+	_, err = b2.Write(data)
+	require.Nil(err)
+
+	ok, err := b1.Equal(b2)
+	require.Nil(err)
+	require.True(ok)
+
+	ok, err = b1.EqualReader(bytes.NewReader(data))
+	require.Nil(err)
+	require.True(ok)
+
+	// The read positions aren't changed
+	require.Equal(len(data), b1.Len())
+	require.Equal(len(data), b2.Len())
+
+	// Only the unread portions are compared
+	_, err = b1.Read(make([]byte, 10))
+	require.Nil(err)
+	ok, err = b1.Equal(b2)
+	require.Nil(err)
+	require.False(ok)
+	ok, err = b1.EqualReader(bytes.NewReader(data[10:]))
+	require.Nil(err)
+	require.True(ok)
+
+	// Different content, shorter and longer readers
+	changed := append([]byte(nil), data[10:]...)
+	changed[len(changed)-1] ^= 0xff
+	for _, r := range [][]byte{changed, data[10 : len(data)-1], append(data[10:len(data):len(data)], 'a')} {
+		ok, err = b1.EqualReader(bytes.NewReader(r))
+		require.Nil(err)
+		require.False(ok)
+	}
+}
@@ -0,0 +1,56 @@
+package buffer
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ExpectedSize tells the Buffer how many bytes will be written (Content-Length of a request, for example).
+// If the data fits into memory, the internal buffer is allocated with exactly n bytes (unless
+// the Buffer uses a MemoryBudget or an Arena). Otherwise,
+// the temp file is created at once, space for n bytes is preallocated where possible (fallocate on Linux),
+// and all data goes straight to the file without filling memory first.
+//
+// n is only a hint: more or less data can be written. ExpectedSize must be called before the first Write
+func (b *Buffer) ExpectedSize(n int64) error {
+	if n < 0 {
+		return errors.New("expected size can't be negative")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.size != 0 || b.useFile || b.writingFinished {
+		return errors.New("expected size must be set before the first Write")
+	}
+
+	if n <= int64(b.maxInMemorySize) {
+		// Memory of a budget or an arena is allocated by writes: the budget counts only stored bytes,
+		// and the arena provides whole blocks
+		if b.buff.Cap() == 0 && !b.sensitive && b.memoryBudget == nil && b.arena == nil && n > 0 {
+			b.buff.Grow(int(n))
+		}
+		return nil
+	}
+
+	// The temp file can be already created by EnableEagerSpill
+	if b.writeFile == nil {
+		err := b.createWriteFile(n)
+		if err != nil {
+			return err
+		}
+	}
+	b.useFile = true
+	if b.groupFile != nil {
+		// The shared file doesn't belong to the Buffer
+		return nil
+	}
+
+	err := preallocate(b.filename, n)
+	if err != nil && IsDiskFull(err) {
+		return diskFullError(filepath.Dir(b.filename), err)
+	}
+	// Other errors are ignored: preallocation is only an optimization
+	return nil
+}
@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ExpectedSize(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		defer b.Reset()
+
+		require.Nil(b.ExpectedSize(100))
+		capacity := b.buff.Cap()
+		require.True(capacity >= 100 && capacity < 1000, "wrong capacity: %d", capacity)
+		require.Empty(b.filename)
+
+		slice := []byte(generateRandomString(100))
+		writeByChunks(require, b, slice, 7)
+		require.Equal(capacity, b.buff.Cap(), "buffer must not grow")
+		require.Equal(slice, readByChunks(require, b, 16))
+	})
+
+	t.Run("memory budget", func(t *testing.T) {
+		require := require.New(t)
+
+		budget := NewMemoryBudget(50)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		b.SetMemoryBudget(budget)
+		defer b.Reset()
+
+		// The memory must not be allocated past the budget
+		require.Nil(b.ExpectedSize(100))
+		require.Equal(0, b.buff.Cap())
+
+		slice := []byte(generateRandomString(100))
+		writeByChunks(require, b, slice, 7)
+		require.Equal(int64(50), budget.Used())
+		require.Equal(slice, readByChunks(require, b, 16))
+	})
+
+	t.Run("arena", func(t *testing.T) {
+		require := require.New(t)
+
+		arena, err := NewArena(1000, 1)
+		require.Nil(err)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		b.SetArena(arena)
+		defer b.Reset()
+
+		require.Nil(b.ExpectedSize(100))
+		require.Equal(0, b.buff.Cap())
+
+		// The data must be stored in the block of the arena
+		slice := []byte(generateRandomString(100))
+		writeByChunks(require, b, slice, 7)
+		require.Equal(0, arena.Free())
+		require.Equal(slice, readByChunks(require, b, 16))
+
+		b.Reset()
+		require.Equal(1, arena.Free())
+	})
+
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(1000)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+
+		require.Nil(b.ExpectedSize(5000))
+		require.NotEmpty(b.filename, "temp file must be created at once")
+
+		// Data must go straight to the file
+		slice := []byte(generateRandomString(5000))
+		writeByChunks(require, b, slice, 7)
+		require.Equal(0, b.buff.Len())
+		require.Equal(slice, readByChunks(require, b, 16))
+
+		require.NotNil(b.ExpectedSize(10), "expected size can't be set after writing")
+		b.Reset()
+	}
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+	require.NotNil(t, b.ExpectedSize(-1))
+}
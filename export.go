@@ -0,0 +1,213 @@
+package buffer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sealedKeyMagic   = "GDBK"
+	sealedKeyVersion = 1
+	sealedKeyPrefix  = len(sealedKeyMagic) + 1
+)
+
+// ExportEncrypted finishes writing and saves the unread data into a new file at path encrypted
+// with DARE (github.com/minio/sio). The file is encrypted with the encryption key of the Buffer,
+// or with a new random key if encryption is disabled or a custom cipher.AEAD is used. The read position of the Buffer isn't changed.
+//
+// ExportEncrypted returns the key and the data size sealed with kek (key encryption key, 32 bytes).
+// The file and the sealed key can be moved to another host and opened there with OpenExported
+func (b *Buffer) ExportEncrypted(path string, kek []byte) (sealedKey []byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(kek) != 32 {
+		return nil, errors.Errorf("invalid key encryption key size: %d, expected 32", len(kek))
+	}
+
+	err = b.finishWriting()
+	if err != nil {
+		return nil, err
+	}
+
+	var key [32]byte
+	if b.encrypt && b.aead == nil {
+		encryptionKey, err := b.key()
+		if err != nil {
+			return nil, err
+		}
+		copy(key[:], encryptionKey)
+	} else {
+		_, err := rand.Read(key[:])
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read random data")
+		}
+	}
+
+	src, err := b.newUnreadReader()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't create file '%s'", path)
+	}
+
+	size, err := exportEncrypted(file, src, b.encryptionConfig, key[:])
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrapf(closeErr, "can't close file '%s'", path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return sealKey(key[:], size, kek)
+}
+
+func exportEncrypted(dst io.Writer, src io.Reader, cfg EncryptionConfig, key []byte) (int64, error) {
+	// Hide Close method of dst: the encryption stream closes the underlying writer
+	w, err := newDefaultEncryptWriter(struct{ io.Writer }{dst}, cfg, key)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't create an encryption stream")
+	}
+
+	size, err := io.Copy(w, src)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't export data")
+	}
+	err = w.Close()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't finish the encryption stream")
+	}
+
+	return size, nil
+}
+
+// OpenExported opens a file saved by Buffer.ExportEncrypted. The returned Buffer is read-only,
+// and the file isn't removed after reading or on Reset()
+func OpenExported(path string, sealedKey, kek []byte) (*Buffer, error) {
+	key, size, err := unsealKey(sealedKey, kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return openEncryptedFile(path, key, size)
+}
+
+// OpenEncrypted opens a file encrypted with DARE (by this package or by github.com/minio/sio directly)
+// with key. The returned Buffer is read-only, and the file isn't removed after reading or on Reset()
+func OpenEncrypted(path string, key []byte) (*Buffer, error) {
+	stats, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get stats of file '%s'", path)
+	}
+
+	size, err := defaultDecryptedSize(stats.Size())
+	if err != nil {
+		return nil, errors.Wrapf(err, "file '%s' has invalid size", path)
+	}
+
+	return openEncryptedFile(path, key, size)
+}
+
+// openEncryptedFile creates a read-only Buffer over a file encrypted with DARE
+func openEncryptedFile(path string, key []byte, size int64) (*Buffer, error) {
+	if len(key) != 32 {
+		return nil, errors.Errorf("invalid key size: %d, expected 32", len(key))
+	}
+
+	stats, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get stats of file '%s'", path)
+	}
+	if !stats.Mode().IsRegular() {
+		return nil, errors.Errorf("'%s' is not a regular file", path)
+	}
+
+	b := NewBufferWithMaxMemorySize(0)
+	b.encrypt = true
+	copy(b.encryptionKey[:], key)
+
+	b.filename = path
+	b.keepFile = true
+	b.useFile = true
+	b.writingFinished = true
+	b.size = size
+	b.fileSize = size
+
+	return b, nil
+}
+
+// sealKey encrypts the key and the data size with kek. The result has the following format:
+//
+//	magic ("GDBK") | version (1 byte) | nonce | AES-GCM(key | size)
+func sealKey(key []byte, size int64, kek []byte) ([]byte, error) {
+	aead, err := newKeyEncryptionAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(key)+8)
+	copy(plaintext, key)
+	binary.LittleEndian.PutUint64(plaintext[len(key):], uint64(size))
+
+	sealed := make([]byte, sealedKeyPrefix+aead.NonceSize())
+	copy(sealed, sealedKeyMagic)
+	sealed[len(sealedKeyMagic)] = sealedKeyVersion
+
+	nonce := sealed[sealedKeyPrefix:]
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read random data")
+	}
+
+	return aead.Seal(sealed, nonce, plaintext, sealed[:sealedKeyPrefix]), nil
+}
+
+// unsealKey decrypts the key and the data size sealed with sealKey
+func unsealKey(sealed []byte, kek []byte) (key []byte, size int64, err error) {
+	aead, err := newKeyEncryptionAEAD(kek)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(sealed) < sealedKeyPrefix+aead.NonceSize() || !bytes.HasPrefix(sealed, []byte(sealedKeyMagic)) {
+		return nil, 0, errors.New("invalid sealed key")
+	}
+	if v := sealed[len(sealedKeyMagic)]; v != sealedKeyVersion {
+		return nil, 0, errors.Errorf("unsupported version of sealed key: %d", v)
+	}
+
+	nonce := sealed[sealedKeyPrefix : sealedKeyPrefix+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, sealed[sealedKeyPrefix+aead.NonceSize():], sealed[:sealedKeyPrefix])
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "can't decrypt sealed key")
+	}
+	if len(plaintext) != 32+8 {
+		return nil, 0, errors.New("invalid sealed key")
+	}
+
+	return plaintext[:32], int64(binary.LittleEndian.Uint64(plaintext[32:])), nil
+}
+
+func newKeyEncryptionAEAD(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != 32 {
+		return nil, errors.Errorf("invalid key encryption key size: %d, expected 32", len(kek))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create a cipher")
+	}
+	return cipher.NewGCM(block)
+}
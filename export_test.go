@@ -0,0 +1,184 @@
+package buffer
+
+import (
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ExportEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-*")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kek := make([]byte, 32)
+	rand.Read(kek)
+
+	tests := []struct {
+		desc      string
+		maxMemory int
+		encrypt   bool
+		size      int
+		// read is the number of bytes read before the export
+		read int
+	}{
+		{desc: "Only memory", maxMemory: 1000, size: 500},
+		{desc: "Memory and file", maxMemory: 1000, size: 200 << 10},
+		{desc: "Encrypted", maxMemory: 1000, encrypt: true, size: 200 << 10},
+		{desc: "Partially read", maxMemory: 1000, encrypt: true, size: 200 << 10, read: 100 << 10},
+		{desc: "Empty", maxMemory: 1000, size: 0},
+	}
+
+	for i, tt := range tests {
+		tt := tt
+		path := filepath.Join(dir, string(rune('a'+i)))
+
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			data := []byte(generateRandomString(tt.size))
+
+			b := NewBufferWithMaxMemorySize(tt.maxMemory)
+			defer b.Reset()
+			if tt.encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+
+			_, err := b.Write(data)
+			require.Nil(err)
+
+			_, err = io.ReadFull(b, make([]byte, tt.read))
+			require.Nil(err)
+
+			sealedKey, err := b.ExportEncrypted(path, kek)
+			require.Nil(err)
+
+			// The Buffer can be read as usual
+			require.Equal(string(data[tt.read:]), string(readByChunks(require, b, 4096)))
+
+			// Open the exported file
+			exported, err := OpenExported(path, sealedKey, kek)
+			require.Nil(err)
+			require.Equal(len(data)-tt.read, exported.Len())
+
+			_, err = exported.Write([]byte("test"))
+			require.Equal(ErrBufferFinished, err)
+
+			// The exported file doesn't belong to the Buffer
+			require.NotNil(exported.RotateEncryptionKey(kek))
+
+			res := make([]byte, 10)
+			if len(data)-tt.read >= 20 {
+				_, err = exported.ReadAt(res, 10)
+				require.Nil(err)
+				require.Equal(data[tt.read+10:tt.read+20], res)
+			}
+
+			require.Equal(string(data[tt.read:]), string(readByChunks(require, exported, 4096)))
+			exported.Reset()
+
+			// The file must be kept
+			_, err = os.Stat(path)
+			require.Nil(err)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		require := require.New(t)
+
+		path := filepath.Join(dir, "errors")
+
+		b := NewBufferString("hello")
+		defer b.Reset()
+
+		_, err := b.ExportEncrypted(path, []byte("short key"))
+		require.NotNil(err)
+
+		sealedKey, err := b.ExportEncrypted(path, kek)
+		require.Nil(err)
+
+		// File already exists
+		_, err = b.ExportEncrypted(path, kek)
+		require.NotNil(err)
+
+		// Wrong key encryption key
+		wrongKEK := make([]byte, 32)
+		rand.Read(wrongKEK)
+		_, err = OpenExported(path, sealedKey, wrongKEK)
+		require.NotNil(err)
+
+		// Corrupted sealed key
+		sealedKey[len(sealedKey)-1] ^= 0xff
+		_, err = OpenExported(path, sealedKey, kek)
+		require.NotNil(err)
+	})
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-*")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	data := []byte(generateRandomString(200 << 10))
+
+	// Encrypt with the default format directly
+	path := filepath.Join(dir, "encrypted")
+	file, err := os.Create(path)
+	require.Nil(err)
+	w, err := newDefaultEncryptWriter(file, EncryptionConfig{}, key)
+	require.Nil(err)
+	_, err = w.Write(data)
+	require.Nil(err)
+	require.Nil(w.Close())
+
+	b, err := OpenEncrypted(path, key)
+	require.Nil(err)
+	defer b.Reset()
+
+	require.Equal(len(data), b.Len())
+
+	res := make([]byte, 100)
+	_, err = b.ReadAt(res, 100<<10)
+	require.Nil(err)
+	require.Equal(data[100<<10:100<<10+100], res)
+
+	// The file doesn't belong to the Buffer
+	require.NotNil(b.RotateEncryptionKey(key))
+
+	require.Equal(data, readByChunks(require, b, 4096))
+	_, err = os.Stat(path)
+	require.Nil(err)
+
+	// Wrong key
+	wrongKey := make([]byte, 32)
+	rand.Read(wrongKey)
+
+	b, err = OpenEncrypted(path, wrongKey)
+	require.Nil(err)
+	defer b.Reset()
+
+	_, err = b.Read(res)
+	require.True(errors.Is(err, ErrTampered))
+
+	// Not encrypted file
+	path = filepath.Join(dir, "plain")
+	require.Nil(ioutil.WriteFile(path, []byte("hello"), 0600))
+
+	_, err = OpenEncrypted(path, key)
+	require.NotNil(err)
+
+	// Non-existing file
+	_, err = OpenEncrypted(filepath.Join(dir, "123"), key)
+	require.NotNil(err)
+}
@@ -0,0 +1,53 @@
+package buffer
+
+// fadviseChunkSize is the amount of data read from the temp file before the page cache of the read
+// data is released
+const fadviseChunkSize = 8 << 20 // 8 MB
+
+// EnablePageCacheHints makes the Buffer advise the kernel about access to the temp file (see posix_fadvise):
+// the file is read sequentially, and the pages of read data are released, so draining of a multi-GB Buffer
+// doesn't evict useful pages of other processes from the page cache. The behavior depends on the kernel.
+// The hints are supported only on Linux, it does nothing on other platforms. It must be called before the first Read
+func (b *Buffer) EnablePageCacheHints() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pageCacheHints = true
+}
+
+// advisedFile releases the page cache of data read from the file
+type advisedFile struct {
+	readableFile
+
+	fd uintptr
+	// off is the offset of sequential reads, advised is the end of the released data
+	off     int64
+	advised int64
+}
+
+// newAdvisedFile advises sequential access to the file. It returns the file as is if it doesn't
+// have a file descriptor
+func newAdvisedFile(file readableFile) readableFile {
+	f, ok := file.(interface{ Fd() uintptr })
+	if !ok {
+		return file
+	}
+
+	fd := f.Fd()
+	// The hints are optional, so errors are ignored
+	fadviseSequential(fd)
+	return &advisedFile{
+		readableFile: file,
+		fd:           fd,
+	}
+}
+
+func (af *advisedFile) Read(p []byte) (int, error) {
+	n, err := af.readableFile.Read(p)
+	af.off += int64(n)
+	if af.off-af.advised >= fadviseChunkSize {
+		fadviseDontNeed(af.fd, af.advised, af.off-af.advised)
+		af.advised = af.off
+	}
+	return n, err
+}
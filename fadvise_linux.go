@@ -0,0 +1,28 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || ppc64 || ppc64le || s390x)
+
+package buffer
+
+import (
+	"syscall"
+)
+
+const (
+	fadvSequential = 2 // POSIX_FADV_SEQUENTIAL
+	fadvDontNeed   = 4 // POSIX_FADV_DONTNEED
+)
+
+func fadvise(fd uintptr, off, size int64, advice int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, fd, uintptr(off), uintptr(size), uintptr(advice), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func fadviseSequential(fd uintptr) error {
+	return fadvise(fd, 0, 0, fadvSequential)
+}
+
+func fadviseDontNeed(fd uintptr, off, size int64) error {
+	return fadvise(fd, off, size, fadvDontNeed)
+}
@@ -0,0 +1,11 @@
+//go:build !linux || !(amd64 || arm64 || riscv64 || loong64 || ppc64 || ppc64le || s390x)
+
+package buffer
+
+func fadviseSequential(fd uintptr) error {
+	return nil
+}
+
+func fadviseDontNeed(fd uintptr, off, size int64) error {
+	return nil
+}
@@ -0,0 +1,39 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnablePageCacheHints(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(fadviseChunkSize + 1<<20))
+
+		b := NewBufferWithMaxMemorySize(100)
+		b.EnablePageCacheHints()
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+		writeByChunks(require, b, slice, 1<<16)
+
+		_, err := b.Read(make([]byte, 1000))
+		require.Nil(err)
+		advised, ok := b.readFile.(*advisedFile)
+		if !ok {
+			// The file is wrapped by decryption
+			advised = b.readFile.(*readCloser).originalFile.(*advisedFile)
+		}
+
+		res := make([]byte, len(slice)-1000)
+		_, err = io.ReadFull(b, res)
+		require.Nil(err)
+		require.Equal(slice[1000:], res)
+		require.True(advised.advised > 0, "read data must be released")
+
+		b.Reset()
+	}
+}
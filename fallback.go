@@ -0,0 +1,82 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// EnableMemoryFallback makes the Buffer continue in memory if creation of a temp file or a write
+// into it fails. The Buffer keeps up to maxMemorySize bytes in memory in total. If a write into
+// the temp file fails, data that is already stored on a disk is loaded into memory, and the temp
+// file is removed. It is useful when a disk is best-effort and losing data is worse than temporary
+// memory growth.
+//
+// After the fallback, the Buffer doesn't use a disk till Reset()
+func (b *Buffer) EnableMemoryFallback(maxMemorySize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.memoryFallbackSize = maxMemorySize
+}
+
+// writeToMemoryFallback writes data into memory after the fallback. It returns ErrNoSpace
+// if data doesn't fit into memory
+func (b *Buffer) writeToMemoryFallback(data []byte) (n int, err error) {
+	if b.buff.Len()+len(data) > b.memoryFallbackSize {
+		return 0, errors.Wrapf(ErrNoSpace, "can't store %d bytes: memory fallback limit is %d bytes", len(data), b.memoryFallbackSize)
+	}
+	return b.buff.Write(data)
+}
+
+// fallbackToMemory moves all data into memory after writeErr occurred during writing data into
+// the temp file. written is the number of bytes of data that were written into the temp file.
+// writeErr is returned if the fallback is impossible
+func (b *Buffer) fallbackToMemory(data []byte, written int, writeErr error) (n int, err error) {
+	remaining := data[written:]
+	if b.buff.Len()+int(b.fileSize)+len(remaining) > b.memoryFallbackSize {
+		return written, writeErr
+	}
+
+	if b.useFile {
+		err = b.loadTempFile()
+		if err != nil {
+			// The state of the temp file is unknown. So, the data can't be read
+			b.writingFinished = true
+			b.writeErr = errors.Wrapf(writeErr, "can't fall back to memory: %s", err)
+			return written, b.writeErr
+		}
+	}
+	b.diskFailed = true
+
+	n, err = b.buff.Write(remaining)
+	return written + n, err
+}
+
+// loadTempFile loads data from the temp file into memory and removes the file
+func (b *Buffer) loadTempFile() error {
+	err := b.writeFile.Close()
+	b.writeFile = nil
+	if err != nil {
+		return errors.Wrap(err, "can't finish writing into a temp file")
+	}
+
+	readFile, err := b.openReadFile()
+	if err != nil {
+		return err
+	}
+	defer readFile.Close()
+
+	// Don't modify the buffer if the file can't be read
+	data := make([]byte, b.fileSize)
+	_, err = io.ReadFull(readFile, data)
+	if err != nil {
+		return errors.Wrap(err, "can't read data from a temp file")
+	}
+	b.buff.Write(data)
+
+	b.removeTempFile()
+	b.useFile = false
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_MemoryFallback(t *testing.T) {
+	t.Run("Can't create temp file", func(t *testing.T) {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		require.Nil(b.ChangeTempDir(dir))
+		require.Nil(os.Remove(dir))
+		b.EnableMemoryFallback(100)
+
+		slice := []byte(generateRandomString(100))
+		writeByChunks(require, b, slice, 7)
+		require.Empty(b.filename)
+
+		// Memory limit is exceeded
+		_, err = b.Write([]byte("a"))
+		require.Equal(ErrNoSpace, errors.Cause(err))
+
+		res := readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Can't write into temp file", func(t *testing.T) {
+		for _, encrypt := range []bool{false, true} {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(10)
+			defer b.Reset()
+
+			if encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			b.SetQuota(NewQuota(50))
+			b.EnableMemoryFallback(100)
+
+			slice := []byte(generateRandomString(100))
+			writeByChunks(require, b, slice, 7)
+
+			// Data must be moved into memory
+			require.Empty(b.filename)
+			require.Equal(100, b.buff.Len())
+
+			res := readByChunks(require, b, 16)
+			require.Equal(slice, res, "wrong content was read")
+		}
+	})
+
+	t.Run("Memory limit is exceeded", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		b.SetQuota(NewQuota(50))
+		b.EnableMemoryFallback(60)
+
+		_, err := b.Write([]byte(generateRandomString(60)))
+		require.Nil(err)
+
+		_, err = b.Write([]byte(generateRandomString(10)))
+		require.Equal(ErrNoSpace, errors.Cause(err))
+		require.NotEmpty(b.filename, "temp file must be kept")
+	})
+}
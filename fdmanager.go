@@ -0,0 +1,104 @@
+package buffer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// FDManager limits the number of temp files opened for sequential reading by Buffers that share it.
+// When the limit is exceeded, the file of the least recently read Buffer is closed. It is reopened
+// on the next read, and the read position is preserved. It keeps a process with thousands of spilled
+// Buffers under its file descriptor limit. FDManager is thread-safe.
+//
+// Files opened for writing and for ReadAt aren't managed. Reopening of an encrypted temp file requires
+// decrypting of already read data, so the limit should be big enough to avoid frequent reopening
+type FDManager struct {
+	mu      sync.Mutex
+	maxOpen int
+	// lru contains Buffers with opened files. The most recently used Buffer is at the front
+	lru   *list.List
+	elems map[*Buffer]*list.Element
+}
+
+// NewFDManager creates a new FDManager that keeps at most maxOpen files opened
+func NewFDManager(maxOpen int) *FDManager {
+	if maxOpen < 1 {
+		maxOpen = 1
+	}
+
+	return &FDManager{
+		maxOpen: maxOpen,
+		lru:     list.New(),
+		elems:   make(map[*Buffer]*list.Element),
+	}
+}
+
+// Open returns the number of files opened by managed Buffers
+func (m *FDManager) Open() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lru.Len()
+}
+
+// SetFDManager makes the Buffer use m to limit the number of opened temp files.
+// It must be called before the first Read
+func (b *Buffer) SetFDManager(m *FDManager) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.fdManager = m
+}
+
+// touch marks the file of b as recently used
+func (m *FDManager) touch(b *Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.elems[b]; ok {
+		m.lru.MoveToFront(elem)
+	}
+}
+
+// opened registers the opened file of b and closes files of other Buffers if the limit is exceeded.
+// b.mu must be held
+func (m *FDManager) opened(b *Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.elems[b]; ok {
+		m.lru.MoveToFront(elem)
+	} else {
+		m.elems[b] = m.lru.PushFront(b)
+	}
+
+	elem := m.lru.Back()
+	for m.lru.Len() > m.maxOpen && elem != nil {
+		prev := elem.Prev()
+
+		victim := elem.Value.(*Buffer)
+		// Skip Buffers that are in use: waiting for them can cause a deadlock
+		if victim != b && victim.mu.TryLock() {
+			if victim.readFile != nil {
+				victim.dropReadFile()
+			}
+			victim.mu.Unlock()
+
+			m.lru.Remove(elem)
+			delete(m.elems, victim)
+		}
+
+		elem = prev
+	}
+}
+
+// closed unregisters the closed file of b
+func (m *FDManager) closed(b *Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.elems[b]; ok {
+		m.lru.Remove(elem)
+		delete(m.elems, b)
+	}
+}
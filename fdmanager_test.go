@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFDManager(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		m := NewFDManager(2)
+
+		var (
+			buffers []*Buffer
+			slices  [][]byte
+			results [][]byte
+		)
+		for i := 0; i < 5; i++ {
+			b := NewBufferWithMaxMemorySize(10)
+			defer b.Reset()
+
+			if encrypt {
+				require.Nil(b.EnableEncryption())
+			}
+			b.SetFDManager(m)
+
+			slice := []byte(generateRandomString(1000))
+			writeByChunks(require, b, slice, 7)
+
+			buffers = append(buffers, b)
+			slices = append(slices, slice)
+			results = append(results, nil)
+		}
+
+		// Interleave reads, so files are closed and reopened
+		chunk := make([]byte, 33)
+		for done := 0; done < len(buffers); {
+			done = 0
+			for i, b := range buffers {
+				n, _ := b.Read(chunk)
+				results[i] = append(results[i], chunk[:n]...)
+				if n == 0 {
+					done++
+				}
+
+				require.LessOrEqual(m.Open(), 2)
+			}
+		}
+
+		for i := range buffers {
+			require.Equal(slices[i], results[i], "wrong content was read")
+		}
+		require.Equal(0, m.Open())
+	}
+}
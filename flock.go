@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrFileLocked is returned when the temp file is locked by another Buffer or process
+	ErrFileLocked = errors.New("temp file is locked")
+	// ErrFileLockingUnsupported is returned by EnableFileLocking on platforms without flock
+	ErrFileLockingUnsupported = errors.New("locking of files isn't supported on this platform")
+)
+
+// EnableFileLocking makes the Buffer hold an exclusive advisory lock (flock) on the temp file for its
+// lifetime, so cleanup jobs that honor flock don't remove files in use. If the Buffer already has
+// a temp file (see OpenExported, for example), it is locked immediately, and ErrFileLocked is returned
+// if the file is used by another process.
+//
+// Mirrors aren't locked. The lock is released after the file is removed on Reset
+func (b *Buffer) EnableFileLocking() error {
+	if !fileLockingSupported {
+		return ErrFileLockingUnsupported
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lockFiles = true
+	if b.filename == "" || b.fileLock != nil || b.unlinkedFile != nil {
+		return nil
+	}
+	return b.lockTempFile()
+}
+
+// lockTempFile locks the current temp file. The previous lock is released
+func (b *Buffer) lockTempFile() error {
+	b.unlockTempFile()
+
+	lock, err := lockFile(b.filename)
+	if err != nil {
+		return err
+	}
+	b.fileLock = lock
+	return nil
+}
+
+// setFileLock replaces the lock of the temp file
+func (b *Buffer) setFileLock(lock *os.File) {
+	b.unlockTempFile()
+	b.fileLock = lock
+}
+
+// unlockTempFile releases the lock of the temp file if it exists
+func (b *Buffer) unlockTempFile() {
+	if b.fileLock != nil {
+		b.fileLock.Close()
+		b.fileLock = nil
+	}
+}
+
+// lockFile opens a file and takes an exclusive lock on it. The lock is held till the returned file is closed
+func lockFile(filename string) (*os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open a temp file '%s' for locking", filename)
+	}
+	err = flock(file)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "can't lock a temp file '%s'", filename)
+	}
+	return file, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package buffer
+
+import (
+	"os"
+)
+
+const fileLockingSupported = false
+
+func flock(*os.File) error {
+	return ErrFileLockingUnsupported
+}
@@ -0,0 +1,87 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableFileLocking(t *testing.T) {
+	if !fileLockingSupported {
+		t.Skip("locking of files isn't supported")
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1000))
+
+		b := NewBufferWithMaxMemorySize(10)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+		require.Nil(b.EnableFileLocking())
+
+		writeByChunks(require, b, slice, 7)
+		require.Nil(b.Flush())
+
+		filename := b.filename
+		_, err := lockFile(filename)
+		require.True(errors.Is(err, ErrFileLocked), "file must be locked, got %v", err)
+
+		if encrypt {
+			require.Nil(b.RotateEncryptionKey([]byte(generateRandomString(32))))
+			require.NotEqual(filename, b.filename)
+			_, err = lockFile(b.filename)
+			require.True(errors.Is(err, ErrFileLocked), "new file must be locked, got %v", err)
+		}
+
+		res := readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+
+		b.Reset()
+		require.Nil(b.fileLock, "lock must be released")
+	}
+}
+
+func TestBuffer_EnableFileLockingExisting(t *testing.T) {
+	if !fileLockingSupported {
+		t.Skip("locking of files isn't supported")
+	}
+
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	key := []byte(generateRandomString(32))
+	path := filepath.Join(dir, "data")
+	file, err := os.Create(path)
+	require.Nil(err)
+	w, err := newDefaultEncryptWriter(file, EncryptionConfig{}, key)
+	require.Nil(err)
+	_, err = w.Write([]byte(generateRandomString(100)))
+	require.Nil(err)
+	require.Nil(w.Close())
+
+	first, err := OpenEncrypted(path, key)
+	require.Nil(err)
+	require.Nil(first.EnableFileLocking())
+
+	second, err := OpenEncrypted(path, key)
+	require.Nil(err)
+	err = second.EnableFileLocking()
+	require.True(errors.Is(err, ErrFileLocked), "second Buffer must not lock the file, got %v", err)
+
+	first.Reset()
+	require.Nil(second.EnableFileLocking(), "lock must be released on Reset")
+	second.Reset()
+
+	_, err = os.Stat(path)
+	require.Nil(err, "file must be kept")
+}
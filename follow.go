@@ -0,0 +1,329 @@
+package buffer
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FollowBuffer is a Buffer that can be read while it is being written, like 'tail -f'. When Read reaches
+// the end of the written data, it blocks till more data is written or writing is finished with Close.
+// So, a live log can be consumed while it is being filled.
+//
+// Data is stored in a chain of Buffers: when the reader catches up with the writer, the current Buffer
+// is finished and new data is written into a new one. So, at most two Buffers are used at once.
+// Write and Close are safe for concurrent use with Read. Read must not be called concurrently
+type FollowBuffer struct {
+	maxInMemorySize int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// reading is the Buffer that is being read, writing is the Buffer that is being written.
+	// They are the same Buffer till the reader catches up with the writer
+	reading *Buffer
+	writing *Buffer
+	// size is the number of bytes written into writing
+	size   int64
+	closed bool
+
+	// autoCompactionSize is passed to EnableAutoCompaction of new Buffers. Auto compaction is disabled if it is 0
+	autoCompactionSize int64
+
+	// highWatermark and lowWatermark bound the unread data (see SetWatermarks). blocked reports whether
+	// Write waits for the reader to drain the data below lowWatermark
+	highWatermark int64
+	lowWatermark  int64
+	blocked       bool
+
+	// deadline is the deadline of Read. deadlineTimer wakes up the reader when the deadline is exceeded
+	deadline      time.Time
+	deadlineTimer *time.Timer
+
+	// available receives a value when data is written or writing is finished, done is closed when
+	// writing is finished
+	available chan struct{}
+	done      chan struct{}
+}
+
+// NewFollowBuffer creates a new FollowBuffer. Every Buffer of the chain stores up to maxInMemorySize bytes in memory
+func NewFollowBuffer(maxInMemorySize int) *FollowBuffer {
+	fb := &FollowBuffer{
+		maxInMemorySize: maxInMemorySize,
+		available:       make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+	fb.cond = sync.NewCond(&fb.mu)
+	return fb
+}
+
+// EnableAutoCompaction makes Read drop the already read prefix of the temp files (see Buffer.EnableAutoCompaction).
+// So, disk usage of a long-lived FollowBuffer tracks the unread data, not all written data. It must be called
+// before the first Write
+func (fb *FollowBuffer) EnableAutoCompaction(minSize int64) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.autoCompactionSize = minSize
+}
+
+// SetWatermarks enables back-pressure: when the unread data exceeds high bytes, Write blocks till
+// the reader drains it to low bytes. So, a slow reader bounds the disk usage. The data is checked
+// before writing: a single Write can exceed high. Write and Read must be called from different goroutines.
+// Back-pressure is disabled if high is 0
+func (fb *FollowBuffer) SetWatermarks(high, low int64) error {
+	if high < 0 || low < 0 {
+		return errors.New("watermarks can't be negative")
+	}
+	if low > high {
+		return errors.Errorf("low watermark %d exceeds high watermark %d", low, high)
+	}
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.highWatermark, fb.lowWatermark = high, low
+	fb.blocked = false
+	// Wake up blocked writers
+	fb.cond.Broadcast()
+
+	return nil
+}
+
+// Write appends data and wakes up the reader. It returns ErrBufferFinished after Close. If back-pressure
+// is enabled (see SetWatermarks), Write blocks while the reader drains the unread data
+func (fb *FollowBuffer) Write(p []byte) (int, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	for fb.blocked && !fb.closed {
+		if fb.unreadLen() <= fb.lowWatermark {
+			fb.blocked = false
+			break
+		}
+		fb.cond.Wait()
+	}
+
+	if fb.closed {
+		return 0, ErrBufferFinished
+	}
+	if fb.writing == nil {
+		fb.writing = NewBufferWithMaxMemorySize(fb.maxInMemorySize)
+		if fb.autoCompactionSize > 0 {
+			fb.writing.EnableAutoCompaction(fb.autoCompactionSize)
+		}
+		if fb.reading == nil {
+			fb.reading = fb.writing
+		}
+	}
+
+	n, err := fb.writing.Write(p)
+	fb.size += int64(n)
+	if n > 0 {
+		fb.cond.Broadcast()
+		fb.notify()
+	}
+	if fb.highWatermark > 0 && fb.unreadLen() > fb.highWatermark {
+		fb.blocked = true
+	}
+	return n, err
+}
+
+// Close finishes writing. Read returns io.EOF after all data is read
+func (fb *FollowBuffer) Close() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.finish()
+	return nil
+}
+
+// finish finishes writing and wakes up the reader
+func (fb *FollowBuffer) finish() {
+	if !fb.closed {
+		fb.closed = true
+		close(fb.done)
+		fb.notify()
+	}
+	fb.cond.Broadcast()
+}
+
+// notify sends a notification to Available without blocking
+func (fb *FollowBuffer) notify() {
+	select {
+	case fb.available <- struct{}{}:
+	default:
+		// There's a pending notification
+	}
+}
+
+// Available returns a channel that receives a value when new data becomes readable or writing is finished.
+// Notifications are coalesced: a single value can stand for several writes. So, event-loop style consumers
+// can wait for data without polling Len. After a notification, read till Len returns 0 and check Done
+func (fb *FollowBuffer) Available() <-chan struct{} {
+	return fb.available
+}
+
+// Done returns a channel that is closed when writing is finished with Close or Reset
+func (fb *FollowBuffer) Done() <-chan struct{} {
+	return fb.done
+}
+
+// SetReadDeadline sets the deadline for Read. If Read waits for data after the deadline, it returns
+// os.ErrDeadlineExceeded. Data written before the deadline can still be read. A deadline can be extended
+// after it was exceeded. A zero value disables the deadline
+func (fb *FollowBuffer) SetReadDeadline(t time.Time) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	fb.deadline = t
+	if fb.deadlineTimer != nil {
+		fb.deadlineTimer.Stop()
+		fb.deadlineTimer = nil
+	}
+	if t.IsZero() {
+		return
+	}
+
+	// Wake up the reader when the deadline is exceeded
+	fb.deadlineTimer = time.AfterFunc(time.Until(t), func() {
+		fb.mu.Lock()
+		fb.cond.Broadcast()
+		fb.mu.Unlock()
+	})
+}
+
+// Read reads the written data. If all written data is read, it blocks till more data is written.
+// It returns io.EOF when writing is finished and all data is read, os.ErrDeadlineExceeded when
+// the deadline is exceeded (see SetReadDeadline)
+func (fb *FollowBuffer) Read(p []byte) (int, error) {
+	return fb.ReadContext(context.Background(), p)
+}
+
+// ReadContext is like Read, but it stops waiting for data when ctx is done and returns ctx.Err()
+func (fb *FollowBuffer) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Wake up the reader when ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		fb.mu.Lock()
+		fb.cond.Broadcast()
+		fb.mu.Unlock()
+	})
+	defer stop()
+
+	for {
+		b, err := fb.nextReading(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		// The Buffer isn't written anymore, so it can be read without holding the lock
+		n, err := b.Read(p)
+		if n > 0 {
+			if err == io.EOF {
+				err = nil
+			}
+			fb.wakeUpWriter()
+			return n, err
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		// The Buffer is drained
+		fb.mu.Lock()
+		b.Reset()
+		fb.reading = fb.writing
+		fb.mu.Unlock()
+	}
+}
+
+// nextReading returns a Buffer that can be read. It waits for data if all written data is read
+func (fb *FollowBuffer) nextReading(ctx context.Context) (*Buffer, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	for {
+		if fb.reading != nil && fb.reading != fb.writing {
+			return fb.reading, nil
+		}
+		if fb.reading != nil && fb.size > 0 {
+			// Catch up with the writer: new data is written into a new Buffer
+			fb.writing = nil
+			fb.size = 0
+			return fb.reading, nil
+		}
+		if fb.closed {
+			return nil, io.EOF
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !fb.deadline.IsZero() && !time.Now().Before(fb.deadline) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		fb.cond.Wait()
+	}
+}
+
+// wakeUpWriter wakes up Write blocked by back-pressure
+func (fb *FollowBuffer) wakeUpWriter() {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.blocked {
+		fb.cond.Broadcast()
+	}
+}
+
+// Len returns the number of bytes of the unread data. Use Len64 for more than 2 GB of unread data
+// on 32-bit platforms
+func (fb *FollowBuffer) Len() int {
+	return int(fb.Len64())
+}
+
+// Len64 returns the number of bytes of the unread data
+func (fb *FollowBuffer) Len64() int64 {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	return fb.unreadLen()
+}
+
+// unreadLen is a non-locking version of Len64
+func (fb *FollowBuffer) unreadLen() int64 {
+	var n int64
+	if fb.reading != nil {
+		n += fb.reading.Len64()
+	}
+	if fb.writing != nil && fb.writing != fb.reading {
+		n += fb.writing.Len64()
+	}
+	return n
+}
+
+// Reset resets the Buffers and removes their temp files. The FollowBuffer can't be used after Reset
+func (fb *FollowBuffer) Reset() {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.reading != nil {
+		fb.reading.Reset()
+	}
+	if fb.writing != nil {
+		fb.writing.Reset()
+	}
+	fb.reading, fb.writing = nil, nil
+	fb.size = 0
+	if fb.deadlineTimer != nil {
+		fb.deadlineTimer.Stop()
+		fb.deadlineTimer = nil
+	}
+	fb.finish()
+}
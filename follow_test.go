@@ -0,0 +1,284 @@
+package buffer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowBuffer(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(1 << 16))
+
+	fb := NewFollowBuffer(100)
+	defer fb.Reset()
+
+	go func() {
+		for i := 0; i < len(slice); i += 1000 {
+			end := i + 1000
+			if end > len(slice) {
+				end = len(slice)
+			}
+			fb.Write(slice[i:end])
+			if i%10000 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		fb.Close()
+	}()
+
+	res, err := ioutil.ReadAll(fb)
+	require.Nil(err)
+	require.Equal(slice, res)
+	require.Equal(0, fb.Len())
+
+	_, err = fb.Write([]byte("data"))
+	require.Equal(ErrBufferFinished, err)
+}
+
+func TestFollowBuffer_Blocks(t *testing.T) {
+	require := require.New(t)
+
+	fb := NewFollowBuffer(100)
+	defer fb.Reset()
+
+	_, err := fb.Write([]byte("hello"))
+	require.Nil(err)
+	require.Equal(5, fb.Len())
+
+	data := make([]byte, 10)
+	n, err := fb.Read(data)
+	require.Nil(err)
+	require.Equal("hello", string(data[:n]))
+
+	read := make(chan string)
+	go func() {
+		n, _ := fb.Read(data)
+		read <- string(data[:n])
+	}()
+
+	select {
+	case <-read:
+		require.FailNow("Read must block till data is written")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	_, err = fb.Write([]byte(" world"))
+	require.Nil(err)
+	require.Equal(" world", <-read)
+
+	require.Nil(fb.Close())
+	_, err = fb.Read(data)
+	require.Equal(io.EOF, err)
+}
+
+func TestFollowBuffer_Deadline(t *testing.T) {
+	t.Run("SetReadDeadline", func(t *testing.T) {
+		require := require.New(t)
+
+		fb := NewFollowBuffer(100)
+		defer fb.Reset()
+
+		_, err := fb.Write([]byte("hello"))
+		require.Nil(err)
+
+		// Written data can be read after the deadline
+		fb.SetReadDeadline(time.Now().Add(-time.Second))
+		data := make([]byte, 10)
+		n, err := fb.Read(data)
+		require.Nil(err)
+		require.Equal("hello", string(data[:n]))
+
+		_, err = fb.Read(data)
+		require.True(errors.Is(err, os.ErrDeadlineExceeded))
+
+		// The reader is woken up
+		fb.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		start := time.Now()
+		_, err = fb.Read(data)
+		require.True(errors.Is(err, os.ErrDeadlineExceeded))
+		require.True(time.Since(start) >= 20*time.Millisecond)
+
+		// The deadline is disabled
+		fb.SetReadDeadline(time.Time{})
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			fb.Write([]byte(" world"))
+		}()
+		n, err = fb.Read(data)
+		require.Nil(err)
+		require.Equal(" world", string(data[:n]))
+	})
+
+	t.Run("ReadContext", func(t *testing.T) {
+		require := require.New(t)
+
+		fb := NewFollowBuffer(100)
+		defer fb.Reset()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		data := make([]byte, 10)
+		_, err := fb.ReadContext(ctx, data)
+		require.Equal(context.DeadlineExceeded, err)
+
+		// The FollowBuffer can be read after the cancellation
+		_, err = fb.Write([]byte("hello"))
+		require.Nil(err)
+		n, err := fb.ReadContext(context.Background(), data)
+		require.Nil(err)
+		require.Equal("hello", string(data[:n]))
+	})
+}
+
+func TestFollowBuffer_Watermarks(t *testing.T) {
+	t.Run("Blocks", func(t *testing.T) {
+		require := require.New(t)
+
+		fb := NewFollowBuffer(100)
+		defer fb.Reset()
+		require.NotNil(fb.SetWatermarks(100, 1000))
+		require.Nil(fb.SetWatermarks(1000, 100))
+
+		_, err := fb.Write([]byte(generateRandomString(1001)))
+		require.Nil(err)
+
+		written := make(chan error)
+		go func() {
+			_, err := fb.Write([]byte("data"))
+			written <- err
+		}()
+		select {
+		case <-written:
+			require.FailNow("Write must block till the data is drained")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		// The data is still above the low watermark
+		_, err = io.ReadFull(fb, make([]byte, 500))
+		require.Nil(err)
+		select {
+		case <-written:
+			require.FailNow("Write must block till the data is drained below the low watermark")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		_, err = io.ReadFull(fb, make([]byte, 401))
+		require.Nil(err)
+		require.Nil(<-written)
+		require.Equal(104, fb.Len())
+		require.Equal(int64(104), fb.Len64())
+
+		// Close wakes up blocked writers
+		_, err = fb.Write([]byte(generateRandomString(1000)))
+		require.Nil(err)
+		go func() {
+			_, err := fb.Write([]byte("data"))
+			written <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		require.Nil(fb.Close())
+		require.Equal(ErrBufferFinished, <-written)
+	})
+
+	t.Run("Above 2 GB", func(t *testing.T) {
+		require := require.New(t)
+
+		fb := NewFollowBuffer(100)
+		defer fb.Reset()
+		// Watermarks must not overflow on 32-bit platforms
+		require.Nil(fb.SetWatermarks(5<<30, 4<<30))
+
+		_, err := fb.Write([]byte(generateRandomString(1000)))
+		require.Nil(err)
+		_, err = fb.Write([]byte("data"))
+		require.Nil(err)
+		require.Equal(int64(1004), fb.Len64())
+	})
+
+	t.Run("Slow reader", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1 << 16))
+
+		fb := NewFollowBuffer(100)
+		defer fb.Reset()
+		require.Nil(fb.SetWatermarks(4096, 1024))
+
+		maxLen := make(chan int)
+		go func() {
+			var max int
+			for i := 0; i < len(slice); i += 512 {
+				fb.Write(slice[i : i+512])
+				if l := fb.Len(); l > max {
+					max = l
+				}
+			}
+			fb.Close()
+			maxLen <- max
+		}()
+
+		var res []byte
+		data := make([]byte, 100)
+		for {
+			n, err := fb.Read(data)
+			res = append(res, data[:n]...)
+			if err == io.EOF {
+				break
+			}
+			require.Nil(err)
+			time.Sleep(10 * time.Microsecond)
+		}
+		require.Equal(slice, res)
+		require.True(<-maxLen <= 4096+512, "unread data must be bounded")
+	})
+}
+
+func TestFollowBuffer_Available(t *testing.T) {
+	require := require.New(t)
+
+	fb := NewFollowBuffer(100)
+	defer fb.Reset()
+
+	select {
+	case <-fb.Available():
+		require.FailNow("there's no data yet")
+	default:
+	}
+
+	// Notifications are coalesced
+	for i := 0; i < 3; i++ {
+		_, err := fb.Write([]byte("data"))
+		require.Nil(err)
+	}
+	<-fb.Available()
+	require.Equal(12, fb.Len())
+	select {
+	case <-fb.Available():
+		require.FailNow("notifications must be coalesced")
+	default:
+	}
+
+	res := make([]byte, 12)
+	_, err := io.ReadFull(fb, res)
+	require.Nil(err)
+
+	select {
+	case <-fb.Done():
+		require.FailNow("writing isn't finished")
+	default:
+	}
+
+	require.Nil(fb.Close())
+	<-fb.Available()
+	<-fb.Done()
+	require.Nil(fb.Close(), "Close can be called twice")
+}
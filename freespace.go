@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// EnableFreeSpaceCheck makes the Buffer check free space on a disk before creating a temp file and
+// before writes of largeWriteSize or more bytes (if largeWriteSize > 0). If there's less than
+// reserve bytes of free space left after the write, the Buffer fails fast with ErrNoSpace instead of
+// failing halfway through a large write.
+//
+// The check is supported on Linux, macOS, FreeBSD and Windows. It is skipped on other platforms
+func (b *Buffer) EnableFreeSpaceCheck(reserve int64, largeWriteSize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.freeSpaceCheck = true
+	b.freeSpaceReserve = reserve
+	b.freeSpaceLargeWrite = largeWriteSize
+}
+
+// checkFreeSpace returns ErrNoSpace if dir doesn't have enough free space to store required bytes
+func (b *Buffer) checkFreeSpace(dir string, required int64) error {
+	if !b.freeSpaceCheck {
+		return nil
+	}
+
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	free, ok, err := freeSpace(dir)
+	if err != nil {
+		return errors.Wrapf(err, "can't get free space of '%s'", dir)
+	}
+	if !ok {
+		// Not supported
+		return nil
+	}
+
+	if uint64(required+b.freeSpaceReserve) > free {
+		return errors.Wrapf(ErrNoSpace, "can't store %d bytes in '%s': only %d bytes are free", required, dir, free)
+	}
+	return nil
+}
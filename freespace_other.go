@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package buffer
+
+func freeSpace(string) (free uint64, ok bool, err error) {
+	return 0, false, nil
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package buffer
+
+import "syscall"
+
+// freeSpace returns the number of bytes available to unprivileged users
+func freeSpace(dir string) (free uint64, ok bool, err error) {
+	var stats syscall.Statfs_t
+	err = syscall.Statfs(dir, &stats)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return uint64(stats.Bavail) * uint64(stats.Bsize), true, nil
+}
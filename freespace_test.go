@@ -0,0 +1,52 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_FreeSpaceCheck(t *testing.T) {
+	if _, ok, _ := freeSpace("."); !ok {
+		t.Skip("free space check isn't supported")
+	}
+
+	t.Run("Before creation of temp file", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		b.EnableFreeSpaceCheck(1<<62, 0)
+
+		// Data is stored in memory
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+
+		_, err = b.Write([]byte(generateRandomString(100)))
+		require.Equal(ErrNoSpace, errors.Cause(err))
+		require.Empty(b.filename)
+	})
+
+	t.Run("Large writes", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		b.EnableFreeSpaceCheck(0, 1024)
+
+		_, err := b.Write([]byte(generateRandomString(2048)))
+		require.Nil(err)
+
+		// Only large writes are checked
+		b.EnableFreeSpaceCheck(1<<62, 1024)
+
+		_, err = b.Write([]byte(generateRandomString(100)))
+		require.Nil(err)
+
+		_, err = b.Write([]byte(generateRandomString(1024)))
+		require.Equal(ErrNoSpace, errors.Cause(err))
+	})
+}
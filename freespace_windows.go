@@ -0,0 +1,23 @@
+package buffer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// freeSpace returns the number of bytes available to the current user
+func freeSpace(dir string) (free uint64, ok bool, err error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false, err
+	}
+
+	r, _, err := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, false, err
+	}
+
+	return free, true, nil
+}
@@ -0,0 +1,31 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// EnableHashing makes the Buffer compute a hash of all written data with h. If h is nil,
+// SHA-256 is used. The hash is reset on Reset(). It must be called before the first Write
+func (b *Buffer) EnableHashing(h hash.Hash) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h == nil {
+		h = sha256.New()
+	}
+	h.Reset()
+
+	b.hash = h
+}
+
+// Sum returns a hash of all data written into the Buffer. It returns nil if hashing is disabled
+func (b *Buffer) Sum() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.hash == nil {
+		return nil
+	}
+	return b.hash.Sum(nil)
+}
@@ -0,0 +1,54 @@
+package buffer
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Sum(t *testing.T) {
+	require := require.New(t)
+
+	data := []byte(generateRandomString(1000))
+
+	// Hashing is disabled
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	writeByChunks(require, b, data, 64)
+	require.Nil(b.Sum())
+
+	// SHA-256
+	b = NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	b.EnableHashing(nil)
+	writeByChunks(require, b, data, 64)
+
+	sha256Sum := sha256.Sum256(data)
+	require.Equal(sha256Sum[:], b.Sum())
+
+	// Reading doesn't change the hash
+	require.Equal(data, readByChunks(require, b, 128))
+	require.Equal(sha256Sum[:], b.Sum())
+
+	// Reset resets the hash
+	b.Reset()
+	_, err := b.Write([]byte("hello"))
+	require.Nil(err)
+
+	sha256Sum = sha256.Sum256([]byte("hello"))
+	require.Equal(sha256Sum[:], b.Sum())
+
+	// Custom hash
+	b = NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	b.EnableHashing(md5.New())
+	writeByChunks(require, b, data, 64)
+
+	md5Sum := md5.Sum(data)
+	require.Equal(md5Sum[:], b.Sum())
+}
@@ -0,0 +1,224 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	spillHeaderMagic   = "GDBH"
+	spillHeaderVersion = 1
+	spillHeaderSize    = len(spillHeaderMagic) + 1 + 8 + 8 + 4
+
+	// spillChunkSize is a size of plaintext chunks of encrypted temp files: both DARE packages
+	// and chunks sealed with cipher.AEAD contain 64 KB of data
+	spillChunkSize = 64 << 10
+
+	// maxSealedHeaderSize limits the size of a sealed header read from a temp file
+	maxSealedHeaderSize = 1 << 10
+)
+
+// spillHeader describes the data of an encrypted temp file. The header is sealed with the encryption
+// key and stored after the encrypted data, because the sizes are known only when writing is finished.
+// The sealed header is followed by its size (4 bytes):
+//
+//	encrypted data | sealed header | size of the sealed header
+//
+// It allows to detect truncation and tail-stripping of the temp file before decryption
+type spillHeader struct {
+	plainSize  int64
+	cipherSize int64
+	chunkSize  uint32
+}
+
+func (h spillHeader) marshal() []byte {
+	data := make([]byte, spillHeaderSize)
+	copy(data, spillHeaderMagic)
+	data[len(spillHeaderMagic)] = spillHeaderVersion
+
+	rest := data[len(spillHeaderMagic)+1:]
+	binary.LittleEndian.PutUint64(rest, uint64(h.plainSize))
+	binary.LittleEndian.PutUint64(rest[8:], uint64(h.cipherSize))
+	binary.LittleEndian.PutUint32(rest[16:], h.chunkSize)
+
+	return data
+}
+
+func (h *spillHeader) unmarshal(data []byte) error {
+	if len(data) != spillHeaderSize || !bytes.HasPrefix(data, []byte(spillHeaderMagic)) {
+		return errors.New("invalid header")
+	}
+	if version := data[len(spillHeaderMagic)]; version != spillHeaderVersion {
+		return errors.Errorf("unsupported header version: %d", version)
+	}
+
+	rest := data[len(spillHeaderMagic)+1:]
+	h.plainSize = int64(binary.LittleEndian.Uint64(rest))
+	h.cipherSize = int64(binary.LittleEndian.Uint64(rest[8:]))
+	h.chunkSize = binary.LittleEndian.Uint32(rest[16:])
+
+	return nil
+}
+
+// sealedWriter encrypts data and appends the sealed spillHeader on Close
+type sealedWriter struct {
+	w   io.WriteCloser
+	enc io.WriteCloser
+
+	// cipherSize is the number of bytes written by the encryption stream
+	cipherSize int64
+	plainSize  int64
+
+	// header is sealed into sealedHeader on Close
+	headerEnc    io.WriteCloser
+	sealedHeader bytes.Buffer
+}
+
+// newSealedWriter returns a writer that encrypts data and writes it into w
+func (b *Buffer) newSealedWriter(w io.WriteCloser) (*sealedWriter, error) {
+	sw := &sealedWriter{w: w}
+
+	var err error
+	// w is closed after the header is written, so the encryption stream must not close it
+	sw.enc, err = b.newEncryptWriter(sealedWriterFunc(sw.write))
+	if err != nil {
+		return nil, err
+	}
+	// Create the stream for the header now: the key can be changed before Close
+	sw.headerEnc, err = b.newEncryptWriter(&sw.sealedHeader)
+	if err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *sealedWriter) Write(p []byte) (n int, err error) {
+	n, err = sw.enc.Write(p)
+	sw.plainSize += int64(n)
+	return n, err
+}
+
+// write is called by the encryption stream
+func (sw *sealedWriter) write(p []byte) (n int, err error) {
+	n, err = sw.w.Write(p)
+	sw.cipherSize += int64(n)
+	return n, err
+}
+
+func (sw *sealedWriter) Close() error {
+	err := sw.close()
+	closeErr := sw.w.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (sw *sealedWriter) close() error {
+	err := sw.enc.Close()
+	if err != nil {
+		return err
+	}
+
+	header := spillHeader{
+		plainSize:  sw.plainSize,
+		cipherSize: sw.cipherSize,
+		chunkSize:  spillChunkSize,
+	}
+	_, err = sw.headerEnc.Write(header.marshal())
+	if err == nil {
+		err = sw.headerEnc.Close()
+	}
+	if err != nil {
+		return errors.Wrap(err, "can't seal the header")
+	}
+
+	sealed := sw.sealedHeader.Bytes()
+	sealed = binary.LittleEndian.AppendUint32(sealed, uint32(len(sealed)))
+	_, err = sw.w.Write(sealed)
+	if err != nil {
+		return errors.Wrap(err, "can't write the header")
+	}
+	return nil
+}
+
+// sealedWriterFunc is an io.Writer that calls the function
+type sealedWriterFunc func(p []byte) (int, error)
+
+func (f sealedWriterFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// readSpillHeader reads the sealed header of an encrypted temp file of size bytes and checks
+// that the file contains exactly the data described by the header. Errors are wrapped into ErrTampered
+func (b *Buffer) readSpillHeader(r io.ReaderAt, size int64) (spillHeader, error) {
+	var header spillHeader
+
+	tampered := func(msg string) error {
+		return errors.Wrap(ErrTampered, msg)
+	}
+
+	var sizeBuf [4]byte
+	if size < int64(len(sizeBuf)) {
+		return header, tampered("temp file is too short")
+	}
+	_, err := r.ReadAt(sizeBuf[:], size-int64(len(sizeBuf)))
+	if err != nil {
+		return header, errors.Wrap(err, "can't read the header size")
+	}
+
+	sealedSize := int64(binary.LittleEndian.Uint32(sizeBuf[:]))
+	headerOffset := size - int64(len(sizeBuf)) - sealedSize
+	if sealedSize > maxSealedHeaderSize || headerOffset < 0 {
+		return header, tampered("invalid header size")
+	}
+
+	src, err := b.newDecryptReader(io.NewSectionReader(r, headerOffset, sealedSize))
+	if err != nil {
+		return header, errors.Wrap(err, "can't create a decryption stream")
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(src, int64(spillHeaderSize)+1))
+	if err != nil {
+		return header, tampered(err.Error())
+	}
+	if err := header.unmarshal(data); err != nil {
+		return header, tampered(err.Error())
+	}
+
+	switch {
+	case header.cipherSize != headerOffset:
+		return header, tampered("temp file was truncated or extended")
+	case header.plainSize != b.fileSize-b.fileDropped:
+		return header, tampered("temp file contains unexpected amount of data")
+	case header.chunkSize != spillChunkSize:
+		return header, tampered("temp file has unexpected chunk size")
+	}
+	return header, nil
+}
+
+// encryptedDataSize returns the size of encrypted data stored in the temp file. The sealed header
+// is checked if the file has it
+func (b *Buffer) encryptedDataSize(file readableFile) (int64, error) {
+	stats, err := file.Stat()
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't get stats of a temp file '%s'", b.filename)
+	}
+	if !b.sealedHeader {
+		return stats.Size(), nil
+	}
+
+	var r io.ReaderAt = file
+	if b.checksums != nil {
+		// Report damage of the file as checksum errors
+		r = newChecksumReaderAt(file, b.checksums)
+	}
+	header, err := b.readSpillHeader(r, stats.Size())
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't read the header of a temp file '%s'", b.filename)
+	}
+	return header.cipherSize, nil
+}
@@ -0,0 +1,127 @@
+package buffer
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SpillHeader(t *testing.T) {
+	const size = 3 * spillChunkSize
+
+	tests := []struct {
+		desc   string
+		damage func(require *require.Assertions, filename string, size int64)
+	}{
+		{
+			desc: "Tail is stripped",
+			damage: func(require *require.Assertions, filename string, size int64) {
+				require.Nil(os.Truncate(filename, size-10))
+			},
+		},
+		{
+			desc: "Header is removed",
+			damage: func(require *require.Assertions, filename string, size int64) {
+				// Keep the size of the sealed header, so it points to the encrypted data
+				f, err := os.OpenFile(filename, os.O_RDWR, 0)
+				require.Nil(err)
+				defer f.Close()
+
+				data := make([]byte, 4)
+				_, err = f.ReadAt(data, size-4)
+				require.Nil(err)
+				_, err = f.WriteAt(data, size-100)
+				require.Nil(err)
+				require.Nil(f.Truncate(size - 96))
+			},
+		},
+		{
+			desc: "File is extended",
+			damage: func(require *require.Assertions, filename string, _ int64) {
+				f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+				require.Nil(err)
+				defer f.Close()
+
+				_, err = f.Write([]byte("!!!!"))
+				require.Nil(err)
+			},
+		},
+	}
+
+	encryptions := []struct {
+		desc   string
+		enable func(require *require.Assertions, b *Buffer)
+	}{
+		{
+			desc: "Default",
+			enable: func(require *require.Assertions, b *Buffer) {
+				require.Nil(b.EnableEncryption())
+			},
+		},
+		{
+			desc: "AEAD",
+			enable: func(require *require.Assertions, b *Buffer) {
+				require.Nil(b.EnableEncryptionWithAEAD(newTestAEAD(require, aeadMinNonceSize)))
+			},
+		},
+	}
+
+	for _, enc := range encryptions {
+		for _, tt := range tests {
+			enc, tt := enc, tt
+
+			t.Run(enc.desc+"/"+tt.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				newBuffer := func() *Buffer {
+					b := NewBufferWithMaxMemorySize(0)
+					enc.enable(require, b)
+
+					_, err := b.Write([]byte(generateRandomString(size)))
+					require.Nil(err)
+					require.Nil(b.finishWriting())
+
+					stats, err := os.Stat(b.filename)
+					require.Nil(err)
+					tt.damage(require, b.filename, stats.Size())
+
+					return b
+				}
+
+				// The damage must be detected before any data is returned
+				b := newBuffer()
+				defer b.Reset()
+
+				n, err := b.Read(make([]byte, 1024))
+				require.Equal(0, n)
+				require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+
+				b = newBuffer()
+				defer b.Reset()
+
+				_, err = b.ReadAt(make([]byte, 1024), 0)
+				require.True(errors.Is(err, ErrTampered), "got unexpected error: %v", err)
+			})
+		}
+	}
+
+	t.Run("Valid file", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+
+		slice := []byte(generateRandomString(size + 10))
+		writeByChunks(require, b, slice, 4096)
+
+		res := make([]byte, 10)
+		_, err := b.ReadAt(res, size)
+		require.Nil(err)
+		require.Equal(slice[size:], res)
+
+		require.Equal(slice, readByChunks(require, b, 4096))
+	})
+}
@@ -0,0 +1,61 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+)
+
+// Index returns the index of the first instance of sep in the unread portion of the Buffer,
+// or -1 if sep isn't present. The content stored on a disk is searched by chunks with ReadAt,
+// so the read position isn't changed. Index finishes writing
+func (b *Buffer) Index(sep []byte) (int64, error) {
+	b.mu.Lock()
+	err := b.finishWriting()
+	start, size := b.offset, b.size
+	b.mu.Unlock()
+	if err != nil {
+		return -1, err
+	}
+
+	if len(sep) == 0 {
+		return 0, nil
+	}
+
+	chunkSize := readFromChunkSize
+	if chunkSize < 2*len(sep) {
+		chunkSize = 2 * len(sep)
+	}
+	chunk := make([]byte, chunkSize)
+
+	// kept is the number of bytes at the beginning of chunk copied from the end of the previous one:
+	// sep can cross the border of chunks
+	var kept int
+	for off := start; off < size; {
+		n, err := b.ReadAt(chunk[kept:], off)
+		if err != nil && err != io.EOF {
+			return -1, err
+		}
+		if n == 0 {
+			break
+		}
+
+		data := chunk[:kept+n]
+		if i := bytes.Index(data, sep); i >= 0 {
+			return off - int64(kept) + int64(i) - start, nil
+		}
+		off += int64(n)
+
+		kept = len(sep) - 1
+		if kept > len(data) {
+			kept = len(data)
+		}
+		copy(chunk, data[len(data)-kept:])
+	}
+	return -1, nil
+}
+
+// Contains reports whether sep is within the unread portion of the Buffer. See Index
+func (b *Buffer) Contains(sep []byte) (bool, error) {
+	i, err := b.Index(sep)
+	return i >= 0, err
+}
@@ -0,0 +1,68 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Index(t *testing.T) {
+	const maxMemorySize = 100
+
+	slice := bytes.Repeat([]byte("a"), 3*readFromChunkSize)
+	sep := []byte("marker")
+
+	for _, pos := range []int{
+		0,
+		50,
+		maxMemorySize - 3, // memory and file
+		maxMemorySize,
+		readFromChunkSize - 2, // chunks of the file
+		len(slice) - len(sep),
+	} {
+		require := require.New(t)
+
+		data := append([]byte(nil), slice...)
+		copy(data[pos:], sep)
+
+		b := NewBufferWithMaxMemorySize(maxMemorySize)
+		writeByChunks(require, b, data, 1000)
+
+		i, err := b.Index(sep)
+		require.Nil(err)
+		require.Equal(int64(pos), i, "position: %d", pos)
+
+		ok, err := b.Contains(sep)
+		require.Nil(err)
+		require.True(ok)
+
+		// The read position isn't changed
+		require.Equal(len(data), b.Len())
+
+		// The index is relative to the unread portion
+		_, err = b.Read(make([]byte, 10))
+		require.Nil(err)
+		i, err = b.Index(sep)
+		require.Nil(err)
+		if pos >= 10 {
+			require.Equal(int64(pos-10), i)
+		} else {
+			require.Equal(int64(-1), i)
+		}
+
+		b.Reset()
+	}
+
+	b := NewBufferWithMaxMemorySize(maxMemorySize)
+	defer b.Reset()
+	writeByChunks(require.New(t), b, slice, 1000)
+
+	ok, err := b.Contains(sep)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	i, err := b.Index(nil)
+	require.Nil(t, err)
+	require.Equal(t, int64(0), i)
+}
@@ -0,0 +1,81 @@
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrKeyLockingUnsupported is returned by EnableKeyLocking on platforms without mlock
+	ErrKeyLockingUnsupported = errors.New("locking of memory isn't supported on this platform")
+
+	errKeyWiped = errors.New("encryption key was wiped by Reset, encryption must be enabled again")
+)
+
+// EnableKeyLocking makes the Buffer keep the encryption key in a separate memory region locked
+// with mlock, so the key isn't swapped to a disk. The region isn't managed by GC, so the key isn't
+// copied or left in heap dumps. The key is wiped and the region is released on Reset: encryption
+// must be enabled again to reuse the Buffer.
+//
+// Note that ciphers created for reading and writing keep their own copies of the key
+func (b *Buffer) EnableKeyLocking() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lockKey {
+		return nil
+	}
+
+	key, err := lockMemory(len(b.encryptionKey))
+	if err != nil {
+		return errors.Wrap(err, "can't lock memory for the encryption key")
+	}
+	copy(key, b.encryptionKey[:])
+	wipe(b.encryptionKey[:])
+
+	b.lockKey = true
+	b.lockedKey = key
+
+	return nil
+}
+
+// key returns the encryption key. It returns an error if the locked key was wiped
+func (b *Buffer) key() ([]byte, error) {
+	if !b.lockKey {
+		return b.encryptionKey[:], nil
+	}
+	if b.lockedKey == nil {
+		return nil, errKeyWiped
+	}
+	return b.lockedKey, nil
+}
+
+// allocateKey returns memory for a new encryption key. It locks a new memory region
+// if the locked key was wiped
+func (b *Buffer) allocateKey() ([]byte, error) {
+	if b.lockKey && b.lockedKey == nil {
+		key, err := lockMemory(len(b.encryptionKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "can't lock memory for the encryption key")
+		}
+		b.lockedKey = key
+	}
+	return b.key()
+}
+
+// wipeKey wipes the locked encryption key and releases its memory
+func (b *Buffer) wipeKey() {
+	if b.lockedKey == nil {
+		return
+	}
+
+	wipe(b.lockedKey)
+	unlockMemory(b.lockedKey)
+	b.lockedKey = nil
+}
+
+// wipe fills data with zeros
+func wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
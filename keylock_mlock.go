@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package buffer
+
+import (
+	"syscall"
+)
+
+// lockMemory maps a memory region of size bytes and locks it
+func lockMemory(size int) ([]byte, error) {
+	data, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Mlock(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+	return data, nil
+}
+
+// unlockMemory unlocks and unmaps a memory region returned by lockMemory
+func unlockMemory(data []byte) {
+	syscall.Munlock(data)
+	syscall.Munmap(data)
+}
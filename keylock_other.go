@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package buffer
+
+func lockMemory(int) ([]byte, error) {
+	return nil, ErrKeyLockingUnsupported
+}
+
+func unlockMemory([]byte) {}
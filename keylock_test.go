@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableKeyLocking(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	require.Nil(b.EnableEncryption())
+	key := b.encryptionKey
+
+	err := b.EnableKeyLocking()
+	if errors.Is(err, ErrKeyLockingUnsupported) {
+		t.Skip("locking of memory isn't supported")
+	}
+	if err != nil {
+		// RLIMIT_MEMLOCK can be too low
+		t.Skipf("can't lock memory: %s", err)
+	}
+
+	// The key must be moved into the locked memory
+	require.Equal(key[:], b.lockedKey)
+	require.Equal(make([]byte, len(key)), b.encryptionKey[:])
+
+	slice := []byte(generateRandomString(1000))
+	writeByChunks(require, b, slice, 7)
+	require.Equal(slice, readByChunks(require, b, 16))
+
+	// Reset wipes the key
+	b.Reset()
+	require.Nil(b.lockedKey)
+
+	_, err = b.Write(slice)
+	require.True(errors.Is(err, errKeyWiped), "got unexpected error: %v", err)
+
+	b.Reset()
+	require.Nil(b.EnableEncryption())
+	require.NotNil(b.lockedKey)
+
+	writeByChunks(require, b, slice, 7)
+	require.Equal(slice, readByChunks(require, b, 16))
+}
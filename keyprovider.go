@@ -0,0 +1,80 @@
+package buffer
+
+import (
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+// KeyProvider generates data encryption keys. It allows to generate and wrap keys with an external
+// key management service (AWS KMS, Vault, age recipients, etc.). KeyProvider must be safe for concurrent use
+type KeyProvider interface {
+	// GenerateKey returns a new 32-byte data encryption key and the same key wrapped by the provider
+	GenerateKey() (key, wrappedKey []byte, err error)
+	// UnwrapKey returns the key wrapped by GenerateKey
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// LocalKeyProvider generates random keys. The keys aren't wrapped: they are kept only in memory.
+// It is used by EnableEncryption
+var LocalKeyProvider KeyProvider = localKeyProvider{}
+
+type localKeyProvider struct{}
+
+func (localKeyProvider) GenerateKey() (key, wrappedKey []byte, err error) {
+	key = make([]byte, 32)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't read random data")
+	}
+	return key, nil, nil
+}
+
+func (localKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return nil, errors.New("local keys can't be unwrapped")
+}
+
+// EnableEncryptionWithKeyProvider enables encryption with a key generated by p. The wrapped key
+// is stored in the Buffer and can be obtained with WrappedKey, for example, to open an exported
+// file (see ExportEncrypted and OpenEncrypted) after the key is unwrapped with p
+func (b *Buffer) EnableEncryptionWithKeyProvider(p KeyProvider) error {
+	if p == nil {
+		return errors.New("key provider can't be nil")
+	}
+
+	key, wrappedKey, err := p.GenerateKey()
+	if err != nil {
+		return errors.Wrap(err, "can't generate an encryption key")
+	}
+	if len(key) != 32 {
+		return errors.Errorf("invalid key size: %d, expected 32", len(key))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dst, err := b.allocateKey()
+	if err != nil {
+		return err
+	}
+	copy(dst, key)
+	wipe(key)
+
+	b.encrypt = true
+	b.aead = nil
+	b.wrappedKey = append([]byte(nil), wrappedKey...)
+
+	return nil
+}
+
+// WrappedKey returns the encryption key wrapped by the KeyProvider. It returns nil if encryption
+// is disabled or the key isn't wrapped
+func (b *Buffer) WrappedKey() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.encrypt || b.aead != nil || len(b.wrappedKey) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b.wrappedKey...)
+}
@@ -0,0 +1,89 @@
+package buffer
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testKeyProvider wraps keys by XORing them with a mask
+type testKeyProvider struct {
+	mask []byte
+	err  error
+}
+
+func (p testKeyProvider) GenerateKey() (key, wrappedKey []byte, err error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+
+	key = make([]byte, 32)
+	rand.Read(key)
+	wrappedKey, _ = p.UnwrapKey(key)
+	return key, wrappedKey, nil
+}
+
+func (p testKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	key := make([]byte, len(wrappedKey))
+	for i := range key {
+		key[i] = wrappedKey[i] ^ p.mask[i]
+	}
+	return key, nil
+}
+
+func TestBuffer_EnableEncryptionWithKeyProvider(t *testing.T) {
+	t.Run("Wrapped key", func(t *testing.T) {
+		require := require.New(t)
+
+		mask := make([]byte, 32)
+		rand.Read(mask)
+		p := testKeyProvider{mask: mask}
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+		require.Nil(b.EnableEncryptionWithKeyProvider(p))
+
+		slice := []byte(generateRandomString(1000))
+		writeByChunks(require, b, slice, 7)
+
+		wrappedKey := b.WrappedKey()
+		require.NotEmpty(wrappedKey)
+		key, err := p.UnwrapKey(wrappedKey)
+		require.Nil(err)
+		require.Equal(b.encryptionKey[:], key)
+
+		// The key can't be replaced without the provider
+		require.NotNil(b.RotateEncryptionKey(make([]byte, 32)))
+
+		require.Equal(slice, readByChunks(require, b, 16))
+	})
+
+	t.Run("Local provider", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+		require.Nil(b.EnableEncryption())
+		require.Nil(b.WrappedKey())
+		require.NotEqual(make([]byte, 32), b.encryptionKey[:])
+
+		_, err := LocalKeyProvider.UnwrapKey([]byte("key"))
+		require.NotNil(err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		providerErr := errors.New("kms is unavailable")
+		err := b.EnableEncryptionWithKeyProvider(testKeyProvider{err: providerErr})
+		require.True(errors.Is(err, providerErr))
+		require.False(b.encrypt)
+
+		require.NotNil(b.EnableEncryptionWithKeyProvider(nil))
+	})
+}
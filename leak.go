@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// LeakReport describes a Buffer that stored data on a disk and was garbage collected without Reset()
+type LeakReport struct {
+	// Filename is the path of the leaked temp file
+	Filename string
+	// FileSize is the number of bytes written into the temp file
+	FileSize int64
+	// Stack is the stack trace of the Buffer creation
+	Stack []byte
+}
+
+var leakDetection struct {
+	mu      sync.Mutex
+	enabled bool
+	report  func(LeakReport)
+}
+
+// EnableLeakDetection enables leak detection for Buffers created after the call. A stack trace is recorded
+// on creation of every Buffer. If a Buffer that stored data on a disk is garbage collected without Reset(),
+// report is called (in a separate goroutine). If report is nil, leaks are logged with log.Printf.
+//
+// Leaked temp files aren't removed. Recording of stack traces is expensive, so leak detection
+// should be used only during development and testing
+func EnableLeakDetection(report func(LeakReport)) {
+	if report == nil {
+		report = logLeak
+	}
+
+	leakDetection.mu.Lock()
+	defer leakDetection.mu.Unlock()
+
+	leakDetection.enabled = true
+	leakDetection.report = report
+}
+
+// DisableLeakDetection disables leak detection for Buffers created after the call
+func DisableLeakDetection() {
+	leakDetection.mu.Lock()
+	defer leakDetection.mu.Unlock()
+
+	leakDetection.enabled = false
+	leakDetection.report = nil
+}
+
+func logLeak(r LeakReport) {
+	log.Printf("go-disk-buffer: Buffer with temp file '%s' (%d bytes) was garbage collected without Reset(), created at:\n%s",
+		r.Filename, r.FileSize, r.Stack)
+}
+
+// trackLeaks records the creation stack of b and sets a finalizer that reports the leak
+func trackLeaks(b *Buffer) {
+	leakDetection.mu.Lock()
+	enabled, report := leakDetection.enabled, leakDetection.report
+	leakDetection.mu.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	stack := debug.Stack()
+	runtime.SetFinalizer(b, func(b *Buffer) {
+		if b.filename == "" || (b.keepFile && b.sharedFile == nil) {
+			return
+		}
+		report(LeakReport{
+			Filename: b.filename,
+			FileSize: b.fileSize,
+			Stack:    stack,
+		})
+	})
+}
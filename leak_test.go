@@ -0,0 +1,56 @@
+package buffer
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakDetection(t *testing.T) {
+	require := require.New(t)
+
+	leaks := make(chan LeakReport, 2)
+	EnableLeakDetection(func(r LeakReport) {
+		leaks <- r
+	})
+
+	func() {
+		b := NewBufferWithMaxMemorySize(10)
+		_, err := b.Write([]byte(generateRandomString(100)))
+		require.Nil(err)
+		require.Nil(b.Flush())
+
+		// Must not be reported
+		b = NewBufferWithMaxMemorySize(10)
+		_, err = b.Write([]byte(generateRandomString(100)))
+		require.Nil(err)
+		b.Reset()
+	}()
+
+	DisableLeakDetection()
+
+	var report LeakReport
+	timeout := time.After(5 * time.Second)
+loop:
+	for {
+		runtime.GC()
+		select {
+		case report = <-leaks:
+			break loop
+		case <-timeout:
+			require.FailNow("leak wasn't reported")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer os.Remove(report.Filename)
+
+	require.Equal(int64(90), report.FileSize)
+	require.Contains(string(report.Stack), "TestLeakDetection")
+
+	runtime.GC()
+	time.Sleep(50 * time.Millisecond)
+	require.Len(leaks, 0, "Buffer after Reset() must not be reported")
+}
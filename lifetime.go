@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"time"
+)
+
+// SetMaxLifetime limits the lifetime of the Buffer. When d is exceeded, onExpire is called.
+// If onExpire is nil, the Buffer is reset. The lifetime starts at the call of SetMaxLifetime
+// and ends on Reset(). A new call replaces the previous limit, d <= 0 removes it.
+//
+// onExpire is called in a separate goroutine
+func (b *Buffer) SetMaxLifetime(d time.Duration, onExpire func(b *Buffer)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopLifetimeTimer()
+	if d <= 0 {
+		return
+	}
+
+	gen := b.lifetimeGen
+	b.lifetimeTimer = time.AfterFunc(d, func() {
+		b.mu.Lock()
+		if gen != b.lifetimeGen {
+			// The timer was stopped or replaced
+			b.mu.Unlock()
+			return
+		}
+		b.lifetimeTimer = nil
+		if onExpire == nil {
+			// Reset under the same lock, so a new limit set in between isn't discarded
+			b.reset()
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+
+		onExpire(b)
+	})
+}
+
+// stopLifetimeTimer stops the lifetime timer. It must be called with locked b.mu
+func (b *Buffer) stopLifetimeTimer() {
+	b.lifetimeGen++
+	if b.lifetimeTimer != nil {
+		b.lifetimeTimer.Stop()
+		b.lifetimeTimer = nil
+	}
+}
@@ -0,0 +1,109 @@
+package buffer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SetMaxLifetime(t *testing.T) {
+	t.Run("Reset", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(4)
+		defer b.Reset()
+
+		_, err := b.Write([]byte(generateRandomString(32)))
+		require.Nil(err)
+		filename := b.filename
+
+		b.SetMaxLifetime(20*time.Millisecond, nil)
+
+		waitFor(require, func() bool {
+			return b.Len() == 0
+		}, time.Second, 5*time.Millisecond)
+
+		_, err = os.Stat(filename)
+		require.True(os.IsNotExist(err), "temp file must be removed")
+	})
+
+	t.Run("Callback", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferString("hello")
+		defer b.Reset()
+
+		expired := make(chan *Buffer, 1)
+		b.SetMaxLifetime(10*time.Millisecond, func(b *Buffer) {
+			expired <- b
+		})
+
+		select {
+		case expiredBuf := <-expired:
+			require.True(b == expiredBuf)
+		case <-time.After(time.Second):
+			t.Fatal("callback wasn't called")
+		}
+
+		// The callback doesn't reset the Buffer
+		require.Equal(5, b.Len())
+	})
+
+	t.Run("Stopped by Reset", func(t *testing.T) {
+		require := require.New(t)
+
+		called := make(chan struct{}, 1)
+
+		b := NewBufferString("hello")
+		b.SetMaxLifetime(20*time.Millisecond, func(*Buffer) {
+			called <- struct{}{}
+		})
+		b.Reset()
+
+		select {
+		case <-called:
+			t.Fatal("callback must not be called after Reset()")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		require.Equal(0, b.Len())
+	})
+	t.Run("Replaced on expiry", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferString("hello")
+		defer b.Reset()
+
+		b.SetMaxLifetime(time.Millisecond, nil)
+
+		// Hold the lock while the timer fires and the new SetMaxLifetime call waits for it
+		b.mu.Lock()
+		time.Sleep(10 * time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			b.SetMaxLifetime(time.Hour, nil)
+			close(done)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		// Wake up the timer and take the lock back. The timer has waited for more than 1ms,
+		// so the mutex switches to the starvation mode and is handed off in FIFO order:
+		// first to the timer, then to the new SetMaxLifetime call
+		b.mu.Unlock()
+		b.mu.Lock()
+		time.Sleep(10 * time.Millisecond)
+		b.mu.Unlock()
+		<-done
+
+		// Wait for the timer to finish
+		time.Sleep(10 * time.Millisecond)
+
+		b.mu.Lock()
+		armed := b.lifetimeTimer != nil
+		b.mu.Unlock()
+		require.True(armed, "new limit must not be discarded by the expired timer")
+	})
+}
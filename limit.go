@@ -0,0 +1,92 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLimitExceeded is returned by ReadFromLimit when the reader contains more data than the limit
+var ErrLimitExceeded = errors.New("data exceeds the limit")
+
+// ReadFromN reads at most n bytes from r and writes them into the Buffer. exhausted reports whether
+// r returned io.EOF. r isn't read after n bytes, so the rest of its data can be read later (into the next
+// Buffer, for example). If r contains exactly n bytes, exhausted can be false: call ReadFromN again to check it
+func (b *Buffer) ReadFromN(r io.Reader, n int64) (written int64, exhausted bool, err error) {
+	if n < 0 {
+		return 0, false, errors.New("n can't be negative")
+	}
+
+	lr := &limitedReader{r: r, left: n}
+	written, err = b.ReadFrom(lr)
+	return written, lr.eof, err
+}
+
+// ReadFromLimit reads data from r until EOF and writes it into the Buffer. If r contains more than limit
+// bytes, ReadFromLimit writes only the first limit bytes and returns ErrLimitExceeded. One extra byte
+// is read from r to detect it. It allows to buffer bodies up to a size and reject larger ones
+func (b *Buffer) ReadFromLimit(r io.Reader, limit int64) (int64, error) {
+	if limit < 0 {
+		return 0, errors.New("limit can't be negative")
+	}
+
+	lr := &limitedReader{r: r, left: limit, probe: true}
+	n, err := b.ReadFrom(lr)
+	if err != nil {
+		return n, err
+	}
+	if lr.exceeded {
+		return n, ErrLimitExceeded
+	}
+	return n, nil
+}
+
+// limitedReader reads at most left bytes from r. If probe is true, it reads one more byte to check
+// whether r contains more data
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	probe bool
+
+	// eof reports whether r returned io.EOF
+	eof bool
+	// exceeded reports whether r contains more than the limit
+	exceeded bool
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.left <= 0 {
+		if !lr.probe {
+			return 0, io.EOF
+		}
+		return lr.readProbe()
+	}
+
+	if int64(len(p)) > lr.left {
+		p = p[:lr.left]
+	}
+	n, err := lr.r.Read(p)
+	lr.left -= int64(n)
+	if err == io.EOF {
+		lr.eof = true
+	}
+	return n, err
+}
+
+// readProbe reads one byte after the limit. The byte is dropped
+func (lr *limitedReader) readProbe() (int, error) {
+	var probe [1]byte
+	for {
+		n, err := lr.r.Read(probe[:])
+		if n > 0 {
+			lr.exceeded = true
+			return 0, io.EOF
+		}
+		if err == io.EOF {
+			lr.eof = true
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
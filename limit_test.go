@@ -0,0 +1,104 @@
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ReadFromN(t *testing.T) {
+	data := generateRandomString(1000)
+
+	tests := []struct {
+		desc      string
+		n         int64
+		exhausted bool
+	}{
+		{desc: "more data", n: 400},
+		{desc: "exact size", n: 1000},
+		{desc: "less data", n: 2000, exhausted: true},
+		{desc: "zero", n: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			r := strings.NewReader(data)
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+
+			written, exhausted, err := b.ReadFromN(iotest.HalfReader(r), tt.n)
+			require.Nil(err)
+			require.Equal(tt.exhausted, exhausted)
+
+			size := tt.n
+			if size > int64(len(data)) {
+				size = int64(len(data))
+			}
+			require.Equal(size, written)
+
+			res, err := ioutil.ReadAll(b)
+			require.Nil(err)
+			require.Equal(data[:size], string(res))
+
+			// The rest of the data can be read
+			rest, err := ioutil.ReadAll(r)
+			require.Nil(err)
+			require.Equal(data[size:], string(rest))
+		})
+	}
+}
+
+func TestBuffer_ReadFromLimit(t *testing.T) {
+	data := generateRandomString(1000)
+
+	tests := []struct {
+		desc  string
+		limit int64
+		err   error
+	}{
+		{desc: "exceeded", limit: 400, err: ErrLimitExceeded},
+		{desc: "exceeded by one byte", limit: 999, err: ErrLimitExceeded},
+		{desc: "exact size", limit: 1000},
+		{desc: "less data", limit: 2000},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(100)
+			defer b.Reset()
+
+			n, err := b.ReadFromLimit(iotest.OneByteReader(strings.NewReader(data)), tt.limit)
+			require.Equal(tt.err, err)
+
+			size := tt.limit
+			if size > int64(len(data)) {
+				size = int64(len(data))
+			}
+			require.Equal(size, n)
+
+			res, err := ioutil.ReadAll(b)
+			require.Nil(err)
+			require.Equal(data[:size], string(res))
+		})
+	}
+
+	t.Run("reader error", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		_, err := b.ReadFromLimit(iotest.TimeoutReader(strings.NewReader(data)), 10)
+		require.NotNil(err)
+		require.NotEqual(ErrLimitExceeded, err)
+		require.NotEqual(io.EOF, err)
+	})
+}
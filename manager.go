@@ -0,0 +1,195 @@
+package buffer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manager keeps track of Buffers created with it. It allows to get stats of all live Buffers,
+// find Buffers that live too long and clean up all Buffers on shutdown. Manager is thread-safe.
+//
+// A Buffer is live from its creation till Reset(). If the Buffer is used after Reset(),
+// it is registered again on the first Write()
+type Manager struct {
+	mu      sync.Mutex
+	buffers map[*Buffer]struct{}
+}
+
+// BufferInfo describes a Buffer tracked by Manager
+type BufferInfo struct {
+	Buffer *Buffer
+	// CreatedAt is the time the Buffer was registered in Manager
+	CreatedAt time.Time
+	// Len is the number of bytes of the unread portion of the Buffer
+	Len int64
+	// MemorySize is the number of bytes stored in memory
+	MemorySize int
+	// DiskSize is the number of bytes stored in the temp file
+	DiskSize int64
+	// Filename is the path of the temp file. It is empty if the Buffer doesn't use a file
+	Filename string
+}
+
+// ManagerStats contains aggregate stats of all Buffers tracked by Manager
+type ManagerStats struct {
+	// Buffers is the number of live Buffers
+	Buffers int
+	// SpilledBuffers is the number of Buffers that store data on a disk
+	SpilledBuffers int
+	// MemorySize is the total number of bytes stored in memory
+	MemorySize int64
+	// DiskSize is the total number of bytes stored on a disk
+	DiskSize int64
+}
+
+// NewManager creates a new Manager
+func NewManager() *Manager {
+	return &Manager{
+		buffers: make(map[*Buffer]struct{}),
+	}
+}
+
+// NewBufferWithMaxMemorySize creates a new Buffer with passed maxInMemorySize and registers it
+func (m *Manager) NewBufferWithMaxMemorySize(maxInMemorySize int) *Buffer {
+	b := NewBufferWithMaxMemorySize(maxInMemorySize)
+	b.manager = m
+	m.add(b)
+
+	return b
+}
+
+// NewBufferWithInitialCapacity creates a new Buffer with passed maxInMemorySize and initialCapacity
+// and registers it
+func (m *Manager) NewBufferWithInitialCapacity(maxInMemorySize, initialCapacity int) *Buffer {
+	b := NewBufferWithInitialCapacity(maxInMemorySize, initialCapacity)
+	b.manager = m
+	m.add(b)
+
+	return b
+}
+
+// NewBuffer creates a new Buffer with DefaultMaxMemorySize, registers it and calls Write(buf).
+// If an error occurred, it panics
+func (m *Manager) NewBuffer(buf []byte) *Buffer {
+	b := m.NewBufferWithMaxMemorySize(DefaultMaxMemorySize)
+	if len(buf) == 0 {
+		return b
+	}
+
+	_, err := b.Write(buf)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// Buffers returns info about all live Buffers sorted by creation time
+func (m *Manager) Buffers() []BufferInfo {
+	buffers := m.list()
+
+	infos := make([]BufferInfo, 0, len(buffers))
+	for _, b := range buffers {
+		info, ok := b.info()
+		if !ok {
+			// The Buffer was reset
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+
+	return infos
+}
+
+// Stats returns aggregate stats of all live Buffers
+func (m *Manager) Stats() ManagerStats {
+	var stats ManagerStats
+	for _, info := range m.Buffers() {
+		stats.Buffers++
+		if info.Filename != "" {
+			stats.SpilledBuffers++
+		}
+		stats.MemorySize += int64(info.MemorySize)
+		stats.DiskSize += info.DiskSize
+	}
+
+	return stats
+}
+
+// Leaks returns info about Buffers that are live longer than maxAge. Usually it means
+// that somebody forgot to call Reset()
+func (m *Manager) Leaks(maxAge time.Duration) []BufferInfo {
+	var leaks []BufferInfo
+	for _, info := range m.Buffers() {
+		if time.Since(info.CreatedAt) > maxAge {
+			leaks = append(leaks, info)
+		}
+	}
+
+	return leaks
+}
+
+// Cleanup resets all live Buffers and returns their number. It should be called on shutdown
+func (m *Manager) Cleanup() int {
+	buffers := m.list()
+	for _, b := range buffers {
+		b.Reset()
+	}
+
+	return len(buffers)
+}
+
+func (m *Manager) list() []*Buffer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buffers := make([]*Buffer, 0, len(m.buffers))
+	for b := range m.buffers {
+		buffers = append(buffers, b)
+	}
+
+	return buffers
+}
+
+// add registers the Buffer. It must be called with locked b.mu
+func (m *Manager) add(b *Buffer) {
+	m.mu.Lock()
+	m.buffers[b] = struct{}{}
+	m.mu.Unlock()
+
+	b.tracked = true
+	b.createdAt = time.Now()
+}
+
+// remove unregisters the Buffer. It must be called with locked b.mu
+func (m *Manager) remove(b *Buffer) {
+	m.mu.Lock()
+	delete(m.buffers, b)
+	m.mu.Unlock()
+
+	b.tracked = false
+}
+
+// info returns info about the Buffer. It returns false if the Buffer isn't tracked
+func (b *Buffer) info() (BufferInfo, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.tracked {
+		return BufferInfo{}, false
+	}
+
+	return BufferInfo{
+		Buffer:     b,
+		CreatedAt:  b.createdAt,
+		Len:        b.unreadLen(),
+		MemorySize: b.buff.Len(),
+		DiskSize:   b.fileSize - b.fileDropped,
+		Filename:   b.filename,
+	}, true
+}
@@ -0,0 +1,87 @@
+package buffer
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	require := require.New(t)
+
+	m := NewManager()
+
+	b1 := m.NewBufferWithMaxMemorySize(10)
+	defer b1.Reset()
+	b2 := m.NewBuffer([]byte("hello"))
+	defer b2.Reset()
+
+	_, err := b1.Write([]byte(generateRandomString(30)))
+	require.Nil(err)
+
+	infos := m.Buffers()
+	require.Len(infos, 2)
+	require.True(infos[0].Buffer == b1)
+	require.Equal(int64(30), infos[0].Len)
+	require.Equal(10, infos[0].MemorySize)
+	require.Equal(int64(20), infos[0].DiskSize)
+	require.NotEmpty(infos[0].Filename)
+	require.True(infos[1].Buffer == b2)
+	require.Empty(infos[1].Filename)
+
+	require.Equal(ManagerStats{
+		Buffers:        2,
+		SpilledBuffers: 1,
+		MemorySize:     15,
+		DiskSize:       20,
+	}, m.Stats())
+
+	// Reset unregisters the Buffer
+	b2.Reset()
+	require.Equal(1, m.Stats().Buffers)
+
+	// The Buffer is registered again after Write
+	_, err = b2.Write([]byte("test"))
+	require.Nil(err)
+	require.Equal(2, m.Stats().Buffers)
+
+	// Leaks
+	require.Empty(m.Leaks(time.Hour))
+	require.Len(m.Leaks(0), 2)
+
+	// Cleanup
+	filename := infos[0].Filename
+	require.Equal(2, m.Cleanup())
+	require.Equal(ManagerStats{}, m.Stats())
+
+	_, err = os.Stat(filename)
+	require.True(os.IsNotExist(err), "temp file must be removed")
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	m := NewManager()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			b := m.NewBufferWithMaxMemorySize(16)
+			for j := 0; j < 20; j++ {
+				b.Write([]byte(generateRandomString(8)))
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		m.Stats()
+	}
+	wg.Wait()
+
+	m.Cleanup()
+	require.Equal(t, 0, m.Stats().Buffers)
+}
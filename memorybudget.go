@@ -0,0 +1,148 @@
+package buffer
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryBudget limits the total size of data that Buffers store in memory. One MemoryBudget is shared
+// by multiple Buffers (see Buffer.SetMemoryBudget). When the budget is exhausted, new writes go straight
+// to temp files regardless of maxInMemorySize of the Buffers. So, N concurrent Buffers can't use
+// N * maxInMemorySize bytes of memory. MemoryBudget is thread-safe
+type MemoryBudget struct {
+	mu sync.Mutex
+
+	limit int64
+	used  int64
+
+	// buffers contains Buffers that store data in memory and the ticks of their last writes
+	buffers map[*Buffer]int64
+	tick    int64
+	// spillLRU makes the budget flush the least recently used Buffers under pressure
+	spillLRU bool
+}
+
+// NewMemoryBudget creates a new MemoryBudget that allows to store up to limit bytes in memory
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{
+		limit:   limit,
+		buffers: make(map[*Buffer]int64),
+	}
+}
+
+// EnableLRUSpilling makes the budget free memory under pressure: when a write doesn't fit into the budget,
+// the memory of the least recently written Buffers is moved to temp files (see Buffer.FlushToDisk),
+// so Buffers that are written now keep the advantage of memory. Buffers used by other goroutines
+// at the moment are skipped. Errors of flushing are ignored: such Buffers keep their memory
+func (mb *MemoryBudget) EnableLRUSpilling() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.spillLRU = true
+}
+
+// Limit returns the max number of bytes that can be stored in memory
+func (mb *MemoryBudget) Limit() int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.limit
+}
+
+// Used returns the number of bytes that are currently stored in memory
+func (mb *MemoryBudget) Used() int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return mb.used
+}
+
+// tryAcquire reserves up to n bytes for b and returns the number of reserved bytes
+func (mb *MemoryBudget) tryAcquire(b *Buffer, n int64) int64 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.tick++
+	if mb.spillLRU && mb.limit-mb.used < n {
+		mb.flushLRU(b, n)
+	}
+
+	if free := mb.limit - mb.used; n > free {
+		n = free
+	}
+	if n < 0 {
+		n = 0
+	}
+	mb.used += n
+	if n > 0 {
+		mb.buffers[b] = mb.tick
+	}
+
+	return n
+}
+
+// flushLRU flushes the least recently written Buffers except requester till n bytes are free.
+// mb.mu must be locked. It is unlocked during flushing: Buffers return memory with release
+func (mb *MemoryBudget) flushLRU(requester *Buffer, n int64) {
+	candidates := make([]*Buffer, 0, len(mb.buffers))
+	for b := range mb.buffers {
+		if b != requester {
+			candidates = append(candidates, b)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return mb.buffers[candidates[i]] < mb.buffers[candidates[j]]
+	})
+
+	mb.mu.Unlock()
+	defer mb.mu.Lock()
+
+	for _, b := range candidates {
+		if mb.Limit()-mb.Used() >= n {
+			return
+		}
+		// Don't wait for Buffers in use: the requester is locked, so waiting can lead to a deadlock
+		if !b.mu.TryLock() {
+			continue
+		}
+		b.flushToDisk()
+		b.mu.Unlock()
+	}
+}
+
+// release frees n bytes acquired by b
+func (mb *MemoryBudget) release(b *Buffer, n int64) {
+	mb.mu.Lock()
+	mb.used -= n
+	delete(mb.buffers, b)
+	mb.mu.Unlock()
+}
+
+// SetMemoryBudget makes the Buffer share the memory budget mb with other Buffers. The memory is
+// returned to the budget on Reset. The internal buffer isn't preallocated (see SetInitialCapacity):
+// it grows with data. Memory used by EnableMemoryFallback isn't counted.
+// SetMemoryBudget must be called before the first Write
+func (b *Buffer) SetMemoryBudget(mb *MemoryBudget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.memoryBudget = mb
+}
+
+// acquireMemory returns the part of data that can be stored in memory according to the budget
+func (b *Buffer) acquireMemory(data []byte) []byte {
+	if b.memoryBudget == nil {
+		return data
+	}
+	n := b.memoryBudget.tryAcquire(b, int64(len(data)))
+	b.memoryBudgetUsed += n
+	return data[:n]
+}
+
+// releaseMemory returns the memory to the budget
+func (b *Buffer) releaseMemory() {
+	if b.memoryBudget != nil && b.memoryBudgetUsed != 0 {
+		b.memoryBudget.release(b, b.memoryBudgetUsed)
+	}
+	b.memoryBudgetUsed = 0
+}
@@ -0,0 +1,58 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SetMemoryBudget(t *testing.T) {
+	require := require.New(t)
+
+	budget := NewMemoryBudget(100)
+	newBuffer := func() *Buffer {
+		b := NewBufferWithMaxMemorySize(80)
+		b.SetMemoryBudget(budget)
+		return b
+	}
+
+	first := newBuffer()
+	defer first.Reset()
+	firstData := []byte(generateRandomString(80))
+	writeByChunks(require, first, firstData, 7)
+	require.Equal(80, first.buff.Len())
+	require.Empty(first.filename)
+
+	// The budget is exhausted: the rest of data must be spilled
+	second := newBuffer()
+	defer second.Reset()
+	secondData := []byte(generateRandomString(80))
+	writeByChunks(require, second, secondData, 7)
+	require.Equal(20, second.buff.Len())
+	require.NotEmpty(second.filename)
+	require.Equal(int64(100), budget.Used())
+
+	third := newBuffer()
+	defer third.Reset()
+	_, err := third.Write([]byte("abc"))
+	require.Nil(err)
+	require.Equal(0, third.buff.Len())
+	require.NotEmpty(third.filename)
+
+	require.Equal(firstData, readByChunks(require, first, 16))
+	require.Equal(secondData, readByChunks(require, second, 16))
+
+	first.Reset()
+	second.Reset()
+	third.Reset()
+	require.Equal(int64(0), budget.Used(), "memory must be returned on Reset")
+
+	fourth := newBuffer()
+	defer fourth.Reset()
+	_, err = fourth.Write([]byte("abc"))
+	require.Nil(err)
+	require.Equal(3, fourth.buff.Len())
+	require.True(fourth.buff.Cap() < 80, "buffer must not be preallocated")
+	require.Equal(int64(3), budget.Used())
+	require.Equal(int64(100), budget.Limit())
+}
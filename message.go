@@ -0,0 +1,201 @@
+package buffer
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMessageComplete is returned by Message.Write when the message is complete. Data after
+	// the end of the message isn't written
+	ErrMessageComplete = errors.New("message is complete")
+	// ErrMessageIncomplete is returned by Message.Buffer when the end of the message wasn't received yet
+	ErrMessageIncomplete = errors.New("message is incomplete")
+	// ErrMessageTooLarge is returned by Message.Write when the message exceeds the max size
+	ErrMessageTooLarge = errors.New("message is too large")
+)
+
+// MessageOptions define how to detect the end of a message. Only one of ExpectedSize and Terminator can be set
+type MessageOptions struct {
+	// ExpectedSize is the size of the message. It is ignored if it is 0
+	ExpectedSize int64
+	// Terminator marks the end of the message. It isn't stored. It is ignored if it is empty
+	Terminator []byte
+	// MaxSize is the max size of the message. The size isn't limited if it is 0
+	MaxSize int64
+}
+
+// Message reassembles a large message delivered in chunks (gRPC or websocket frames, for example).
+// Chunks are appended with Write till the message is complete: the expected size is reached or
+// the terminator is received. The message is stored in a Buffer, so large messages are stored on a disk.
+// If neither ExpectedSize nor Terminator is set, the message must be completed with Close
+type Message struct {
+	b    *Buffer
+	opts MessageOptions
+
+	size     int64
+	complete bool
+	// pending contains the last bytes of the received data that can be a beginning of the terminator
+	pending []byte
+}
+
+// NewMessage creates a new Message stored in a Buffer with passed maxInMemorySize
+func NewMessage(maxInMemorySize int, opts MessageOptions) (*Message, error) {
+	if opts.ExpectedSize < 0 || opts.MaxSize < 0 {
+		return nil, errors.New("sizes can't be negative")
+	}
+	if opts.ExpectedSize > 0 && len(opts.Terminator) > 0 {
+		return nil, errors.New("only one of expected size and terminator can be set")
+	}
+	if opts.MaxSize > 0 && opts.ExpectedSize > opts.MaxSize {
+		return nil, errors.Wrapf(ErrMessageTooLarge, "expected size %d exceeds max size %d", opts.ExpectedSize, opts.MaxSize)
+	}
+	opts.Terminator = append([]byte(nil), opts.Terminator...)
+
+	return &Message{
+		b:    NewBufferWithMaxMemorySize(maxInMemorySize),
+		opts: opts,
+	}, nil
+}
+
+// Write appends a chunk to the message. If p contains data after the end of the message, Write
+// returns the number of bytes that belong to the message and ErrMessageComplete: the rest of p
+// belongs to the next message
+func (m *Message) Write(p []byte) (n int, err error) {
+	if m.complete {
+		return 0, ErrMessageComplete
+	}
+
+	if m.opts.ExpectedSize > 0 {
+		if left := m.opts.ExpectedSize - m.size; int64(len(p)) >= left {
+			n, err = m.write(p[:left], len(p[:left]))
+			if err != nil {
+				return n, err
+			}
+			m.complete = true
+			if n < len(p) {
+				return n, ErrMessageComplete
+			}
+			return n, nil
+		}
+	}
+	if len(m.opts.Terminator) > 0 {
+		return m.writeUntilTerminator(p)
+	}
+	return m.write(p, len(p))
+}
+
+// write writes data into the Buffer. consumed is the number of bytes of the chunk the data belongs to
+func (m *Message) write(data []byte, consumed int) (int, error) {
+	if m.opts.MaxSize > 0 && m.size+int64(len(data)) > m.opts.MaxSize {
+		return 0, ErrMessageTooLarge
+	}
+
+	n, err := m.b.Write(data)
+	m.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return consumed, nil
+}
+
+// writeUntilTerminator writes p till the terminator. The last bytes that can be a beginning
+// of the terminator are kept in pending till the next chunk
+func (m *Message) writeUntilTerminator(p []byte) (n int, err error) {
+	term := m.opts.Terminator
+
+	// The terminator can start in pending
+	head := p
+	if len(head) > len(term)-1 {
+		head = head[:len(term)-1]
+	}
+	boundary := append(append([]byte(nil), m.pending...), head...)
+	if i := bytes.Index(boundary, term); i >= 0 {
+		return m.finish(m.pending[:i], i+len(term)-len(m.pending), len(p))
+	}
+
+	if i := bytes.Index(p, term); i >= 0 {
+		data := append(m.pending, p[:i]...)
+		return m.finish(data, i+len(term), len(p))
+	}
+
+	// Keep the last bytes
+	keep := len(term) - 1
+	if len(p) >= keep {
+		data := append(m.pending, p[:len(p)-keep]...)
+		_, err = m.write(data, 0)
+		if err != nil {
+			return 0, err
+		}
+		m.pending = append(m.pending[:0], p[len(p)-keep:]...)
+		return len(p), nil
+	}
+
+	pending := append(m.pending, p...)
+	if extra := len(pending) - keep; extra > 0 {
+		_, err = m.write(pending[:extra], 0)
+		if err != nil {
+			return 0, err
+		}
+		pending = append(pending[:0], pending[extra:]...)
+	}
+	m.pending = pending
+	return len(p), nil
+}
+
+// finish writes the last data of the message. consumed is the number of bytes of the chunk
+// that belong to the message, size is the size of the chunk
+func (m *Message) finish(data []byte, consumed, size int) (int, error) {
+	_, err := m.write(data, 0)
+	if err != nil {
+		return 0, err
+	}
+	m.pending = nil
+	m.complete = true
+
+	if consumed < size {
+		return consumed, ErrMessageComplete
+	}
+	return consumed, nil
+}
+
+// Close completes a message without ExpectedSize and Terminator. For other messages it returns
+// ErrMessageIncomplete if the end of the message wasn't received
+func (m *Message) Close() error {
+	if m.complete {
+		return nil
+	}
+	if m.opts.ExpectedSize > 0 || len(m.opts.Terminator) > 0 {
+		return ErrMessageIncomplete
+	}
+
+	m.complete = true
+	return nil
+}
+
+// Complete reports whether the whole message was received
+func (m *Message) Complete() bool {
+	return m.complete
+}
+
+// Size returns the number of bytes of the message stored in the Buffer
+func (m *Message) Size() int64 {
+	return m.size
+}
+
+// Buffer returns the Buffer with the complete message. The Buffer must be reset after use
+func (m *Message) Buffer() (*Buffer, error) {
+	if !m.complete {
+		return nil, ErrMessageIncomplete
+	}
+	return m.b, nil
+}
+
+// Reset resets the Buffer of the message
+func (m *Message) Reset() {
+	m.b.Reset()
+	m.size = 0
+	m.complete = false
+	m.pending = nil
+}
@@ -0,0 +1,118 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage(t *testing.T) {
+	slice := []byte(generateRandomString(1000))
+
+	t.Run("Expected size", func(t *testing.T) {
+		require := require.New(t)
+
+		m, err := NewMessage(10, MessageOptions{ExpectedSize: int64(len(slice))})
+		require.Nil(err)
+		defer m.Reset()
+
+		_, err = m.Buffer()
+		require.Equal(ErrMessageIncomplete, err)
+
+		data := append(append([]byte(nil), slice...), "next"...)
+		for len(data) > 0 {
+			chunk := data
+			if len(chunk) > 7 {
+				chunk = chunk[:7]
+			}
+			n, err := m.Write(chunk)
+			data = data[n:]
+			if err == ErrMessageComplete {
+				break
+			}
+			require.Nil(err)
+		}
+		require.True(m.Complete())
+		require.Equal("next", string(data))
+
+		_, err = m.Write(data)
+		require.Equal(ErrMessageComplete, err)
+
+		b, err := m.Buffer()
+		require.Nil(err)
+		require.Equal(slice, readByChunks(require, b, 16))
+	})
+
+	t.Run("Terminator", func(t *testing.T) {
+		term := []byte("\r\n\r\n")
+		data := append(append([]byte(nil), slice...), "\r\n\r\r\n\r\n\r\nnext"...)
+		expected := append(append([]byte(nil), slice...), "\r\n\r"...)
+
+		for _, chunkSize := range []int{1, 2, 3, 5, 16, len(data)} {
+			require := require.New(t)
+
+			m, err := NewMessage(10, MessageOptions{Terminator: term})
+			require.Nil(err)
+
+			rest := data
+			for {
+				chunk := rest
+				if len(chunk) > chunkSize {
+					chunk = chunk[:chunkSize]
+				}
+				n, err := m.Write(chunk)
+				rest = rest[n:]
+				if m.Complete() {
+					if n < len(chunk) {
+						require.Equal(ErrMessageComplete, err)
+					}
+					break
+				}
+				require.Nil(err)
+				require.Equal(len(chunk), n)
+			}
+			require.Equal("\r\nnext", string(rest), "chunk size: %d", chunkSize)
+
+			b, err := m.Buffer()
+			require.Nil(err)
+			require.Equal(int64(len(expected)), m.Size())
+			require.Equal(expected, readByChunks(require, b, 16), "chunk size: %d", chunkSize)
+			m.Reset()
+		}
+	})
+
+	t.Run("Max size", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := NewMessage(10, MessageOptions{ExpectedSize: 100, MaxSize: 50})
+		require.NotNil(err)
+
+		m, err := NewMessage(10, MessageOptions{Terminator: []byte("\n"), MaxSize: 50})
+		require.Nil(err)
+		defer m.Reset()
+
+		_, err = m.Write(slice[:40])
+		require.Nil(err)
+		_, err = m.Write(slice[40:60])
+		require.Equal(ErrMessageTooLarge, err)
+
+		require.Equal(ErrMessageIncomplete, m.Close())
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		require := require.New(t)
+
+		m, err := NewMessage(10, MessageOptions{})
+		require.Nil(err)
+		defer m.Reset()
+
+		require.Nil(writeByChunksBenchmark(m, slice, 7))
+		require.False(m.Complete())
+		require.Nil(m.Close())
+
+		b, err := m.Buffer()
+		require.Nil(err)
+		require.True(bytes.Equal(slice, readByChunks(require, b, 16)))
+	})
+}
@@ -0,0 +1,136 @@
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// MigrateTempDir changes directory for temp files (see ChangeTempDir) and moves the existing temp file
+// into dir. The file is renamed if possible. Otherwise, it is copied, and the copy replaces the original
+// file. The read and write positions are preserved. It is useful for draining a filling volume at runtime.
+//
+// The temp file can't be copied during writing if writes through memory mappings are enabled or if
+// the file was already moved by DiskFullSwitchDir policy
+func (b *Buffer) MigrateTempDir(dir string) error {
+	path, err := checkTempDir(dir)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tempFileDir = path
+
+	if b.filename == "" || b.keepFile || b.unlinkedFile != nil {
+		// There's no temp file or it doesn't belong to the Buffer. Unlinked files can't be moved
+		return nil
+	}
+
+	targetDir := path
+	var isolatedDir string
+	if b.isolateTempDir {
+		isolatedDir, err = ioutil.TempDir(path, "go-disk-buffer-*")
+		if err != nil {
+			return errors.Wrap(err, "can't create a temp directory")
+		}
+		targetDir = isolatedDir
+	}
+
+	newFilename := filepath.Join(targetDir, filepath.Base(b.filename))
+	err = os.Rename(b.filename, newFilename)
+	if err != nil {
+		// Files can't be renamed across file systems
+		newFilename, err = b.copyTempFile(targetDir)
+	}
+	if err != nil {
+		if isolatedDir != "" {
+			os.RemoveAll(isolatedDir)
+		}
+		return err
+	}
+
+	b.filename = newFilename
+	if b.checksums != nil {
+		b.checksums.filename = newFilename
+	}
+	if b.fileLock != nil {
+		err = b.lockTempFile()
+		if err != nil {
+			return err
+		}
+	}
+	if b.isolateTempDir {
+		b.removeIsolatedDir()
+		b.isolatedDir = isolatedDir
+	}
+
+	return nil
+}
+
+// copyTempFile copies the temp file into dir, replaces the original file with the copy
+// and returns the name of the copy
+func (b *Buffer) copyTempFile(dir string) (string, error) {
+	if b.writeFile != nil {
+		if b.writeTempFile == nil {
+			return "", errors.New("temp file can't be copied during writing")
+		}
+		if w, ok := b.writeFile.(*asyncWriter); ok {
+			err := w.Flush()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	newFile, err := b.tempFiles().Create(dir, "go-disk-buffer-*.tmp")
+	if err != nil {
+		return "", errors.Wrap(err, "can't create a temp file")
+	}
+
+	oldFilename := b.filename
+	err = copyFile(newFile, b.tempFiles(), oldFilename)
+	if err != nil {
+		newFile.Close()
+		b.tempFiles().Remove(newFile.Name())
+		return "", errors.Wrapf(err, "can't copy temp file '%s' into '%s'", oldFilename, dir)
+	}
+
+	if b.writeFile != nil {
+		// Continue writing into the copy
+		b.writeTempFile.File.Close()
+		b.writeTempFile.File = newFile
+	} else {
+		newFile.Close()
+	}
+
+	b.filename = newFile.Name()
+	b.tempFiles().Remove(oldFilename)
+	b.closeReadAtFile()
+
+	err = b.reopenReadFile()
+	if err != nil {
+		return "", err
+	}
+	return newFile.Name(), nil
+}
+
+func copyFile(dst io.Writer, tempFiles TempFileStrategy, filename string) error {
+	src, err := tempFiles.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// swappableFile is a temp file opened for writing. The file can be replaced by its copy
+type swappableFile struct {
+	*os.File
+}
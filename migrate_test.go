@@ -0,0 +1,127 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_MigrateTempDir(t *testing.T) {
+	migrations := []struct {
+		desc    string
+		migrate func(b *Buffer, dir string) error
+	}{
+		{desc: "Rename", migrate: (*Buffer).MigrateTempDir},
+		{
+			desc: "Copy",
+			migrate: func(b *Buffer, dir string) error {
+				b.mu.Lock()
+				defer b.mu.Unlock()
+
+				_, err := b.copyTempFile(dir)
+				return err
+			},
+		},
+	}
+	tests := []struct {
+		desc      string
+		encrypt   bool
+		async     bool
+		checksums bool
+	}{
+		{desc: "Plain"},
+		{desc: "With encryption", encrypt: true},
+		{desc: "With async writes", async: true},
+		{desc: "With checksums", checksums: true},
+	}
+
+	for _, m := range migrations {
+		for _, tt := range tests {
+			m := m
+			tt := tt
+
+			t.Run(m.desc+"/"+tt.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+				require.Nil(err)
+				defer os.RemoveAll(dir)
+
+				newBuffer := func() *Buffer {
+					b := NewBufferWithMaxMemorySize(100)
+					if tt.encrypt {
+						require.Nil(b.EnableEncryption())
+					}
+					if tt.async {
+						b.EnableAsyncWrites(1024, 2)
+					}
+					if tt.checksums {
+						b.EnableChecksums()
+					}
+					return b
+				}
+				slice := []byte(generateRandomString(1 << 17))
+
+				// During writing
+				b := newBuffer()
+				defer b.Reset()
+
+				writeByChunks(require, b, slice[:len(slice)/2], 1024)
+				require.Nil(m.migrate(b, dir))
+				require.Equal(dir, filepath.Dir(b.filename))
+				_, err = b.Write(slice[len(slice)/2:])
+				require.Nil(err)
+
+				res := readByChunks(require, b, 1024)
+				require.Equal(slice, res, "wrong content was read")
+
+				// During reading
+				b = newBuffer()
+				defer b.Reset()
+
+				writeByChunks(require, b, slice, 1024)
+				oldFilename := b.filename
+
+				half := make([]byte, len(slice)/2)
+				_, err = b.Read(half)
+				require.Nil(err)
+
+				require.Nil(m.migrate(b, dir))
+				require.Equal(dir, filepath.Dir(b.filename))
+				_, err = os.Stat(oldFilename)
+				require.True(os.IsNotExist(err), "old file must be removed")
+
+				res = readByChunks(require, b, 1024)
+				require.Equal(slice, append(half, res...), "wrong content was read")
+			})
+		}
+	}
+
+	t.Run("Isolated dir", func(t *testing.T) {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		b.EnableIsolatedTempDir()
+		_, err = b.Write([]byte("hello"))
+		require.Nil(err)
+		oldIsolatedDir := b.isolatedDir
+
+		require.Nil(b.MigrateTempDir(dir))
+		require.Equal(dir, filepath.Dir(b.isolatedDir))
+		require.Equal(b.isolatedDir, filepath.Dir(b.filename))
+		_, err = os.Stat(oldIsolatedDir)
+		require.True(os.IsNotExist(err), "old isolated dir must be removed")
+
+		res := readByChunks(require, b, 2)
+		require.Equal("hello", string(res))
+	})
+}
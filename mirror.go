@@ -0,0 +1,226 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// EnableMirror makes the Buffer mirror the temp file into dir. dir should be on another disk.
+// Every write into the temp file is written into the mirror as well. Reads prefer the temp file
+// and fall back to the mirror on IO errors. The mirror is removed with the temp file.
+//
+// Errors of the mirror are returned by Write. Reads through memory mappings (see EnableMmap)
+// don't fall back to the mirror. EnableMirror must be called before the first Write
+func (b *Buffer) EnableMirror(dir string) error {
+	path, err := checkTempDir(dir)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mirrorDir = path
+
+	return nil
+}
+
+// createMirrorFile creates a mirror of the temp file if mirroring is enabled
+func (b *Buffer) createMirrorFile() (*os.File, error) {
+	if b.mirrorDir == "" {
+		return nil, nil
+	}
+
+	file, err := b.tempFiles().Create(b.mirrorDir, "go-disk-buffer-*.tmp")
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't create a mirror file in '%s'", b.mirrorDir)
+	}
+	return file, nil
+}
+
+// removeMirrorFile removes the mirror of the temp file if it exists
+func (b *Buffer) removeMirrorFile() {
+	if b.mirrorFilename != "" {
+		b.tempFiles().Remove(b.mirrorFilename)
+	}
+	b.mirrorFilename = ""
+}
+
+// mirrorWriter writes data into the underlying writer and into the mirror
+type mirrorWriter struct {
+	w      io.WriteCloser
+	mirror *os.File
+}
+
+func newMirrorWriter(w io.WriteCloser, mirror *os.File) *mirrorWriter {
+	return &mirrorWriter{
+		w:      w,
+		mirror: mirror,
+	}
+}
+
+func (mw *mirrorWriter) Write(p []byte) (n int, err error) {
+	n, err = mw.w.Write(p)
+
+	// Mirror only data accepted by the underlying writer
+	_, mirrorErr := mw.mirror.Write(p[:n])
+	if err == nil && mirrorErr != nil {
+		err = errors.Wrapf(mirrorErr, "can't write into a mirror file '%s'", mw.mirror.Name())
+	}
+	return n, err
+}
+
+func (mw *mirrorWriter) Close() error {
+	err := mw.w.Close()
+	mirrorErr := mw.mirror.Close()
+	if err == nil && mirrorErr != nil {
+		err = errors.Wrapf(mirrorErr, "can't close a mirror file '%s'", mw.mirror.Name())
+	}
+	return err
+}
+
+// readableFile is a temp file opened for reading
+type readableFile interface {
+	io.ReadCloser
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
+
+// openTempFile opens the temp file for reading. If the Buffer has a mirror, the returned file
+// falls back to the mirror on IO errors
+func (b *Buffer) openTempFile() (readableFile, error) {
+	return b.openTempFileWith(b.tempFiles().Open)
+}
+
+// openSequentialTempFile opens the temp file for reading from the beginning to the end. See SequentialOpener
+func (b *Buffer) openSequentialTempFile() (readableFile, error) {
+	strategy := b.tempFiles()
+	return b.openTempFileWith(func(name string) (*os.File, error) {
+		return openSequential(strategy, name)
+	})
+}
+
+// openTempFileWith opens the temp file for reading with open
+func (b *Buffer) openTempFileWith(open func(name string) (*os.File, error)) (readableFile, error) {
+	if b.groupFile != nil {
+		return &groupReader{groupFile: b.groupFile}, nil
+	}
+	if b.unlinkedFile != nil {
+		return b.openUnlinkedFile()
+	}
+
+	file, err := open(b.filename)
+	if b.mirrorFilename == "" {
+		if os.IsNotExist(err) {
+			return nil, b.spillLost(b.spillLostHandler, b.filename, "was removed")
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't open a temp file '%s'", b.filename)
+		}
+		return file, nil
+	}
+
+	if err != nil {
+		// Use the mirror
+		file, err = open(b.mirrorFilename)
+		if os.IsNotExist(err) {
+			return nil, b.spillLost(b.spillLostHandler, b.filename, "and its mirror were removed")
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't open a temp file '%s' and its mirror", b.filename)
+		}
+		return file, nil
+	}
+	return newMirroredFile(file, b.tempFiles(), b.mirrorFilename), nil
+}
+
+// mirroredFile reads data from the temp file and switches to the mirror after an IO error.
+// The mirror is opened on the first error. ReadAt is safe for concurrent use
+type mirroredFile struct {
+	*os.File
+
+	tempFiles      TempFileStrategy
+	mirrorFilename string
+
+	// off is the offset of sequential reads
+	off int64
+
+	mu     sync.Mutex
+	mirror *os.File
+}
+
+func newMirroredFile(file *os.File, tempFiles TempFileStrategy, mirrorFilename string) *mirroredFile {
+	return &mirroredFile{
+		File:           file,
+		tempFiles:      tempFiles,
+		mirrorFilename: mirrorFilename,
+	}
+}
+
+// openMirror opens the mirror if it isn't opened yet
+func (mf *mirroredFile) openMirror() (*os.File, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.mirror == nil {
+		mirror, err := mf.tempFiles.Open(mf.mirrorFilename)
+		if err != nil {
+			return nil, err
+		}
+		mf.mirror = mirror
+	}
+	return mf.mirror, nil
+}
+
+func (mf *mirroredFile) Read(p []byte) (n int, err error) {
+	mf.mu.Lock()
+	mirror := mf.mirror
+	mf.mu.Unlock()
+
+	if mirror == nil {
+		n, err = mf.File.Read(p)
+		if err == nil || err == io.EOF {
+			mf.off += int64(n)
+			return n, err
+		}
+
+		// Discard data read with the error and read it from the mirror
+		mirror, err = mf.openMirror()
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't open a mirror file '%s'", mf.mirrorFilename)
+		}
+	}
+
+	n, err = mirror.ReadAt(p, mf.off)
+	mf.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (mf *mirroredFile) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = mf.File.ReadAt(p, off)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+
+	mirror, mirrorErr := mf.openMirror()
+	if mirrorErr != nil {
+		return n, err
+	}
+	return mirror.ReadAt(p, off)
+}
+
+func (mf *mirroredFile) Close() error {
+	mf.mu.Lock()
+	if mf.mirror != nil {
+		mf.mirror.Close()
+	}
+	mf.mu.Unlock()
+
+	return mf.File.Close()
+}
@@ -0,0 +1,95 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Mirror(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir)
+
+		slice := []byte(generateRandomString(1000))
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+		require.Nil(b.EnableMirror(dir))
+
+		writeByChunks(require, b, slice, 7)
+		require.Nil(b.Flush())
+
+		mirrorFilename := b.mirrorFilename
+		require.Equal(dir, filepath.Dir(mirrorFilename))
+
+		original, err := ioutil.ReadFile(b.filename)
+		require.Nil(err)
+		mirror, err := ioutil.ReadFile(mirrorFilename)
+		require.Nil(err)
+		require.Equal(original, mirror, "mirror must be equal to the temp file")
+
+		// Reads must fall back to the mirror
+		require.Nil(os.Remove(b.filename))
+
+		res := make([]byte, 100)
+		n, err := b.ReadAt(res, 500)
+		require.Nil(err)
+		require.Equal(slice[500:600], res[:n], "wrong content was read")
+
+		res = readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+
+		_, err = os.Stat(mirrorFilename)
+		require.True(os.IsNotExist(err), "mirror must be removed")
+	}
+}
+
+func TestMirroredFile(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	slice := []byte(generateRandomString(100))
+
+	var filenames []string
+	for i := 0; i < 2; i++ {
+		filename := filepath.Join(dir, string(rune('a'+i)))
+		require.Nil(ioutil.WriteFile(filename, slice, 0600))
+		filenames = append(filenames, filename)
+	}
+
+	file, err := os.Open(filenames[0])
+	require.Nil(err)
+
+	mf := newMirroredFile(file, DefaultTempFileStrategy, filenames[1])
+	defer mf.Close()
+
+	res := make([]byte, 30)
+	n, err := mf.Read(res)
+	require.Nil(err)
+	require.Equal(slice[:30], res[:n])
+
+	// Emulate an IO error of the temp file
+	require.Nil(file.Close())
+
+	n, err = mf.Read(res)
+	require.Nil(err)
+	require.Equal(slice[30:60], res[:n])
+
+	n, err = mf.ReadAt(res, 70)
+	require.Nil(err)
+	require.Equal(slice[70:], res[:n])
+}
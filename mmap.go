@@ -0,0 +1,249 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMmapUnsupported is returned by Buffer.EnableMmap on platforms without mmap support
+var ErrMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// EnableMmap makes the Buffer map the temp file into memory when reading begins. It eliminates
+// syscalls and extra copies for ReadAt-heavy workloads (range serving, random access).
+//
+// The temp file is mapped only if encryption and checksums are disabled. Otherwise, EnableMmap
+// has no effect. It returns ErrMmapUnsupported on platforms without mmap support
+func (b *Buffer) EnableMmap() error {
+	if !mmapSupported {
+		return ErrMmapUnsupported
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mmapEnabled = true
+
+	return nil
+}
+
+// useMmap reports whether the temp file should be mapped into memory for reading
+func (b *Buffer) useMmap() bool {
+	return (b.mmapEnabled || b.mmapWriteRegionSize > 0) && !b.encrypt && b.checksums == nil && b.unlinkedFile == nil && b.persistentIndex == nil && b.spillGroup == nil
+}
+
+// mmapReader reads data from a file mapped into memory. ReadAt is safe for concurrent use
+type mmapReader struct {
+	data []byte
+	// off is the offset for Read
+	off int64
+}
+
+func newMmapReader(tempFiles TempFileStrategy, filename string) (*mmapReader, error) {
+	file, err := tempFiles.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open a temp file '%s'", filename)
+	}
+	// The mapping stays valid after closing the file
+	defer file.Close()
+
+	data, err := mmapFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't map temp file '%s' into memory", filename)
+	}
+
+	return &mmapReader{data: data}, nil
+}
+
+func (r *mmapReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *mmapReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset: %d", off)
+	}
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+
+	// Reading pages of a mapping beyond the end of the file causes SIGBUS: the file can be truncated
+	// outside of the Buffer. Turn it into the end of the file, like read of a truncated file, so
+	// the Buffer reports ErrSpillLost
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = io.EOF
+		}
+	}()
+
+	// Copy page by page, so n is valid if a page can't be read
+	pageSize := int64(os.Getpagesize())
+	for n < len(p) && off < int64(len(r.data)) {
+		end := (off/pageSize + 1) * pageSize
+		if end > int64(len(r.data)) {
+			end = int64(len(r.data))
+		}
+
+		copied := copy(p[n:], r.data[off:end])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *mmapReader) Close() error {
+	if r.data == nil {
+		return nil
+	}
+
+	err := munmap(r.data)
+	r.data = nil
+	return err
+}
+
+// DefaultMmapRegionSize is the default size of a temp file region mapped into memory for writing
+const DefaultMmapRegionSize = 4 << 20 // 4 MB
+
+// EnableMmapWrites enables an experimental mode: the Buffer preallocates regions of the temp file,
+// maps them into memory and writes data into the mappings. It avoids write syscalls for
+// high-throughput producers. When reading begins, the temp file is truncated to the size of written
+// data and mapped again for reading (see EnableMmap). If regionSize <= 0, DefaultMmapRegionSize is used.
+// It must be called before the first Write.
+//
+// EnableMmapWrites returns ErrMmapUnsupported on platforms without mmap support
+func (b *Buffer) EnableMmapWrites(regionSize int) error {
+	if !mmapSupported {
+		return ErrMmapUnsupported
+	}
+	if regionSize <= 0 {
+		regionSize = DefaultMmapRegionSize
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mmapWriteRegionSize = regionSize
+
+	return nil
+}
+
+// mmapWriter writes data into a file through memory mappings. Only the current region of the file
+// is mapped. When the region is full, the file is extended and the next region is mapped
+type mmapWriter struct {
+	file       *os.File
+	regionSize int64
+
+	region []byte
+	// base is the offset of the current region in the file
+	base int64
+	// pos is the write position in the current region
+	pos int
+
+	closed bool
+}
+
+func newMmapWriter(file *os.File, regionSize int) *mmapWriter {
+	// Offsets of mappings must be aligned to the page size
+	pageSize := os.Getpagesize()
+	regionSize = (regionSize + pageSize - 1) / pageSize * pageSize
+
+	return &mmapWriter{
+		file:       file,
+		regionSize: int64(regionSize),
+	}
+}
+
+func (mw *mmapWriter) Write(p []byte) (n int, err error) {
+	if mw.closed {
+		return 0, errors.New("write to closed file")
+	}
+
+	// Writing into a mapping of a file on a full disk causes SIGBUS. Turn it into an error
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = errors.Errorf("can't write into memory mapping of file '%s': %v", mw.file.Name(), r)
+		}
+	}()
+
+	for len(p) > 0 {
+		if mw.pos == len(mw.region) {
+			err = mw.nextRegion()
+			if err != nil {
+				return n, err
+			}
+		}
+
+		copied := copy(mw.region[mw.pos:], p)
+		mw.pos += copied
+		n += copied
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// nextRegion unmaps the current region, extends the file and maps the next region
+func (mw *mmapWriter) nextRegion() error {
+	if mw.region != nil {
+		err := munmap(mw.region)
+		mw.region = nil
+		if err != nil {
+			return errors.Wrapf(err, "can't unmap file '%s'", mw.file.Name())
+		}
+		mw.base += mw.regionSize
+		mw.pos = 0
+	}
+
+	err := mw.file.Truncate(mw.base + mw.regionSize)
+	if err != nil {
+		return errors.Wrapf(err, "can't preallocate file '%s'", mw.file.Name())
+	}
+
+	region, err := mmapRegion(mw.file, mw.base, int(mw.regionSize))
+	if err != nil {
+		return errors.Wrapf(err, "can't map file '%s' into memory", mw.file.Name())
+	}
+	mw.region = region
+
+	return nil
+}
+
+// Close unmaps the current region, truncates the file to the size of written data and closes it
+func (mw *mmapWriter) Close() error {
+	if mw.closed {
+		return nil
+	}
+	mw.closed = true
+
+	var err error
+	if mw.region != nil {
+		err = munmap(mw.region)
+		mw.region = nil
+	}
+	if truncErr := mw.file.Truncate(mw.base + int64(mw.pos)); err == nil {
+		err = truncErr
+	}
+	if closeErr := mw.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package buffer
+
+import "os"
+
+const mmapSupported = false
+
+func mmapFile(*os.File) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func mmapRegion(*os.File, int64, int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func munmap([]byte) error {
+	return nil
+}
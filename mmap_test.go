@@ -0,0 +1,206 @@
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Mmap(t *testing.T) {
+	if !mmapSupported {
+		require.Equal(t, ErrMmapUnsupported, NewBuffer(nil).EnableMmap())
+		t.Skip("mmap isn't supported")
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			t.Run("", func(t *testing.T) {
+				t.Parallel()
+
+				require := require.New(t)
+
+				var (
+					sliceSize     = rand.Intn(1<<16) + 1
+					bufferSize    = rand.Intn(sliceSize)
+					readChunkSize = rand.Intn(1<<12) + 1
+				)
+
+				slice := []byte(generateRandomString(sliceSize))
+
+				b := NewBufferWithMaxMemorySize(bufferSize)
+				defer b.Reset()
+
+				require.Nil(b.EnableMmap())
+				// Read-ahead must be ignored
+				b.EnableReadAhead(1024, 2)
+
+				writeByChunks(require, b, slice, 1024)
+
+				res := readByChunks(require, b, readChunkSize)
+				require.Equal(slice, res, "wrong content was read")
+			})
+		}
+	})
+
+	t.Run("ReadAt", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1 << 16))
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		require.Nil(b.EnableMmap())
+		writeByChunks(require, b, slice, 1024)
+
+		for i := 0; i < 50; i++ {
+			off := rand.Intn(len(slice))
+			data := make([]byte, rand.Intn(1<<12)+1)
+
+			n, err := b.ReadAt(data, int64(off))
+			if off+len(data) > len(slice) {
+				require.Equal(io.EOF, err)
+			} else {
+				require.Nil(err)
+			}
+			require.Equal(slice[off:off+n], data[:n])
+		}
+
+		// Sequential reading after ReadAt
+		res := readByChunks(require, b, 4096)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("With encryption", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1 << 12))
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		require.Nil(b.EnableMmap())
+		require.Nil(b.EnableEncryption())
+		writeByChunks(require, b, slice, 1024)
+
+		res := readByChunks(require, b, 512)
+		require.Equal(slice, res, "wrong content was read")
+	})
+}
+
+func TestBuffer_Mmap_Truncated(t *testing.T) {
+	if !mmapSupported {
+		t.Skip("mmap isn't supported")
+	}
+
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(1 << 16))
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	var lost []string
+	b.SetSpillLostHandler(func(filename string) {
+		lost = append(lost, filename)
+	})
+	require.Nil(b.EnableMmap())
+	writeByChunks(require, b, slice, 1024)
+
+	// Map the file
+	res := make([]byte, 100)
+	_, err := io.ReadFull(b, res)
+	require.Nil(err)
+	_, err = b.ReadAt(res, 1000)
+	require.Nil(err)
+
+	// The file is truncated outside of the Buffer: the mapped pages can't be read
+	filename := b.filename
+	require.Nil(os.Truncate(filename, 100))
+
+	_, err = b.ReadAt(res, 1<<15)
+	require.True(errors.Is(err, ErrSpillLost), "ReadAt must return ErrSpillLost, got %v", err)
+
+	_, err = ioutil.ReadAll(b)
+	require.True(errors.Is(err, ErrSpillLost), "Read must return ErrSpillLost, got %v", err)
+
+	require.Equal([]string{filename}, lost, "handler must be called once")
+}
+
+func TestBuffer_MmapWrites(t *testing.T) {
+	if !mmapSupported {
+		require.Equal(t, ErrMmapUnsupported, NewBuffer(nil).EnableMmapWrites(0))
+		t.Skip("mmap isn't supported")
+	}
+
+	tests := []struct {
+		desc      string
+		encrypt   bool
+		checksums bool
+	}{
+		{desc: "Plain"},
+		{desc: "With encryption", encrypt: true},
+		{desc: "With checksums", checksums: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				t.Run("", func(t *testing.T) {
+					t.Parallel()
+
+					require := require.New(t)
+
+					var (
+						sliceSize     = rand.Intn(1<<18) + 1
+						bufferSize    = rand.Intn(sliceSize)
+						regionSize    = rand.Intn(1<<16) + 1
+						readChunkSize = rand.Intn(1<<12) + 1
+					)
+
+					slice := []byte(generateRandomString(sliceSize))
+
+					b := NewBufferWithMaxMemorySize(bufferSize)
+					defer b.Reset()
+
+					require.Nil(b.EnableMmapWrites(regionSize))
+					if tt.encrypt {
+						require.Nil(b.EnableEncryption())
+					}
+					if tt.checksums {
+						b.EnableChecksums()
+					}
+
+					writeByChunks(require, b, slice, 1024)
+
+					res := readByChunks(require, b, readChunkSize)
+					require.Equal(slice, res, "wrong content was read")
+				})
+			}
+		})
+	}
+
+	t.Run("Temp file is truncated", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		require.Nil(b.EnableMmapWrites(0))
+
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+		require.Nil(b.finishWriting())
+
+		stats, err := os.Stat(b.filename)
+		require.Nil(err)
+		require.Equal(int64(5), stats.Size())
+	})
+}
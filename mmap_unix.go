@@ -0,0 +1,41 @@
+//go:build unix
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+const mmapSupported = true
+
+// mmapFile maps the whole file into memory for reading
+func mmapFile(f *os.File) ([]byte, error) {
+	stats, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stats.Size()
+	if size == 0 {
+		return []byte{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, errors.Errorf("file is too large: %d bytes", size)
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
+
+// mmapRegion maps the region of the file into memory for reading and writing
+func mmapRegion(f *os.File, off int64, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), off, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
@@ -0,0 +1,102 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// MultiBuffer presents several Buffers as one contiguous stream without copying. It implements
+// io.Reader and io.ReaderAt. Buffers must not be written after they were passed to MultiBuffer.
+//
+// Read consumes the Buffers one by one just like Buffer.Read does. ReadAt doesn't depend on the read
+// position, but it must not be mixed with Read: the temp file of a Buffer is removed when the Buffer is drained
+type MultiBuffer struct {
+	buffers []*Buffer
+	// current is the index of the Buffer that is being read
+	current int
+}
+
+// NewMultiBuffer creates a new MultiBuffer that reads the passed Buffers sequentially
+func NewMultiBuffer(buffers ...*Buffer) *MultiBuffer {
+	return &MultiBuffer{
+		buffers: append([]*Buffer(nil), buffers...),
+	}
+}
+
+// Read reads data from the Buffers one by one. It fills p fully unless all Buffers are drained
+func (mb *MultiBuffer) Read(p []byte) (n int, err error) {
+	for n < len(p) && mb.current < len(mb.buffers) {
+		read, err := mb.buffers[mb.current].Read(p[n:])
+		n += read
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if read == 0 || err == io.EOF {
+			mb.current++
+		}
+	}
+
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// ReadAt reads len(p) bytes starting at offset off from the beginning of the data of the first Buffer.
+// It is safe for concurrent use
+func (mb *MultiBuffer) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset: %d", off)
+	}
+
+	for _, b := range mb.buffers {
+		if n == len(p) {
+			return n, nil
+		}
+
+		size := b.totalSize()
+		if off >= size {
+			off -= size
+			continue
+		}
+
+		read, err := b.ReadAt(p[n:], off)
+		n += read
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		// The next Buffer is read from the beginning
+		off = 0
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Len returns the total number of bytes of the unread portions of the Buffers
+func (mb *MultiBuffer) Len() int {
+	var n int
+	for _, b := range mb.buffers[mb.current:] {
+		n += b.Len()
+	}
+	return n
+}
+
+// Reset resets all Buffers
+func (mb *MultiBuffer) Reset() {
+	for _, b := range mb.buffers {
+		b.Reset()
+	}
+	mb.current = len(mb.buffers)
+}
+
+// totalSize returns the size of all data written into the Buffer
+func (b *Buffer) totalSize() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.size
+}
@@ -0,0 +1,77 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMultiBuffer(require *require.Assertions, parts [][]byte) *MultiBuffer {
+	var buffers []*Buffer
+	for _, part := range parts {
+		b := NewBufferWithMaxMemorySize(10)
+		_, err := b.Write(part)
+		require.Nil(err)
+
+		buffers = append(buffers, b)
+	}
+	return NewMultiBuffer(buffers...)
+}
+
+func TestMultiBuffer(t *testing.T) {
+	var (
+		parts = [][]byte{
+			[]byte(generateRandomString(5)),
+			[]byte(generateRandomString(100)),
+			nil,
+			[]byte(generateRandomString(37)),
+		}
+		slice []byte
+	)
+	for _, part := range parts {
+		slice = append(slice, part...)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		require := require.New(t)
+
+		mb := newTestMultiBuffer(require, parts)
+		defer mb.Reset()
+
+		require.Equal(len(slice), mb.Len())
+
+		res, err := readByChunksBenchmark(mb, 16)
+		require.Nil(err)
+		require.Equal(slice, res, "wrong content was read")
+		require.Equal(0, mb.Len())
+	})
+
+	t.Run("ReadAt", func(t *testing.T) {
+		require := require.New(t)
+
+		mb := newTestMultiBuffer(require, parts)
+		defer mb.Reset()
+
+		for _, off := range []int{0, 3, 5, 50, 105, 120, 141} {
+			for _, size := range []int{1, 10, 100} {
+				res := make([]byte, size)
+				n, err := mb.ReadAt(res, int64(off))
+
+				expected := slice[off:]
+				if len(expected) > size {
+					expected = expected[:size]
+				}
+				require.Equal(expected, res[:n], "wrong content was read")
+				if n < size {
+					require.Equal(io.EOF, err)
+				} else {
+					require.Nil(err)
+				}
+			}
+		}
+
+		_, err := mb.ReadAt(make([]byte, 1), int64(len(slice)))
+		require.Equal(io.EOF, err)
+	})
+}
@@ -0,0 +1,114 @@
+package buffer
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// defaultParallelChunkSize is the size of chunks used by ProcessChunks if the passed size is 0
+const defaultParallelChunkSize = 1 << 20 // 1 MB
+
+// ChunkFunc processes a chunk of the Buffer. off is the offset of the chunk in the unread portion.
+// p is valid only during the call
+type ChunkFunc func(off int64, p []byte) error
+
+// ProcessChunks splits the unread portion of the Buffer into chunks of chunkSize bytes and passes
+// them to fn. Chunks are read with ReadAt by workers goroutines in parallel, so fn must be safe for
+// concurrent use and chunks can be processed in any order. After the first error, no new chunks are
+// processed, and the error is returned. The returned number is the total size of successfully processed chunks.
+//
+// If chunkSize is 0, 1 MB chunks are used. If workers is 0, GOMAXPROCS workers are used.
+// Unlike WriteTo, ProcessChunks doesn't change the read position: Reset the Buffer after use.
+// ProcessChunks finishes writing
+func (b *Buffer) ProcessChunks(chunkSize, workers int, fn ChunkFunc) (int64, error) {
+	if chunkSize < 0 || workers < 0 {
+		return 0, errors.New("chunk size and number of workers can't be negative")
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultParallelChunkSize
+	}
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	b.mu.Lock()
+	err := b.finishWriting()
+	start, size := b.offset, b.size
+	b.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		processed int64
+		wg        sync.WaitGroup
+		offsets   = make(chan int64)
+		done      = make(chan struct{})
+
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			chunk := make([]byte, chunkSize)
+			for off := range offsets {
+				p := chunk
+				if left := size - start - off; left < int64(len(p)) {
+					p = p[:left]
+				}
+
+				n, err := b.ReadAt(p, start+off)
+				if err != nil && !(err == io.EOF && n == len(p)) {
+					fail(errors.Wrapf(err, "can't read chunk at offset %d", off))
+					return
+				}
+				err = fn(off, p)
+				if err != nil {
+					fail(err)
+					return
+				}
+				atomic.AddInt64(&processed, int64(len(p)))
+			}
+		}()
+	}
+
+loop:
+	for off := int64(0); off < size-start; off += int64(chunkSize) {
+		select {
+		case offsets <- off:
+		case <-done:
+			break loop
+		}
+	}
+	close(offsets)
+	wg.Wait()
+
+	return processed, firstErr
+}
+
+// WriteToAt writes the unread portion of the Buffer into w in parallel (see ProcessChunks).
+// Data is written at the same offsets it has in the unread portion. It is useful for multipart
+// uploads into object stores, for example
+func (b *Buffer) WriteToAt(w io.WriterAt, chunkSize, workers int) (int64, error) {
+	return b.ProcessChunks(chunkSize, workers, func(off int64, p []byte) error {
+		_, err := w.WriteAt(p, off)
+		if err != nil {
+			return errors.Wrap(err, "can't write data into io.WriterAt")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_WriteToAt(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(200<<10 + 17))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 4096)
+
+	// Only the unread portion is written
+	_, err := b.Read(make([]byte, 100))
+	require.Nil(err)
+
+	file, err := ioutil.TempFile("", "go-disk-buffer-test-")
+	require.Nil(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	n, err := b.WriteToAt(file, 4096, 4)
+	require.Nil(err)
+	require.Equal(int64(len(slice)-100), n)
+
+	res, err := ioutil.ReadFile(file.Name())
+	require.Nil(err)
+	require.Equal(slice[100:], res)
+
+	// The read position isn't changed
+	require.Equal(len(slice)-100, b.Len())
+}
+
+func TestBuffer_ProcessChunks(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(200 << 10))
+
+	b := NewBufferWithMaxMemorySize(1000)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 4096)
+
+	var calls int64
+	testErr := errors.New("test error")
+	n, err := b.ProcessChunks(1000, 4, func(off int64, p []byte) error {
+		atomic.AddInt64(&calls, 1)
+		if off == 50000 {
+			return testErr
+		}
+		return nil
+	})
+	require.Equal(testErr, err)
+	require.True(n < int64(len(slice)))
+	require.True(atomic.LoadInt64(&calls) < int64(len(slice)/1000), "processing must stop after an error")
+
+	_, err = b.ProcessChunks(-1, 0, nil)
+	require.NotNil(err)
+}
@@ -0,0 +1,195 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSaved is returned by OpenSaved when there's no complete saved Buffer at the path. It is returned
+// for Buffers whose saving was interrupted by a crash as well
+var ErrNotSaved = errors.New("buffer wasn't saved")
+
+// SavedMetaSuffix is the suffix of the metadata file of a saved Buffer
+const SavedMetaSuffix = ".meta"
+
+var savedMetaMagic = [4]byte{'G', 'D', 'B', 'M'}
+
+const (
+	savedMetaVersion = 1
+	// savedMetaSize is the size of the metadata: magic | version (1 byte) | data size (8 bytes) | CRC-32C of data
+	savedMetaSize = len(savedMetaMagic) + 1 + 8 + 4
+)
+
+// Save finishes writing and saves the unread data into a file at path. The read position isn't changed.
+// Data is stored in plain text, use ExportEncrypted for sensitive data.
+//
+// Save is crash-safe: the data and a small metadata file (path + SavedMetaSuffix) are written into temp files,
+// synced and renamed. The metadata file is renamed last, so after a crash the saved Buffer is either complete
+// or absent (see OpenSaved). A previously saved Buffer at path is replaced
+func (b *Buffer) Save(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.finishWriting()
+	if err != nil {
+		return err
+	}
+
+	src, err := b.newUnreadReader()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dir := filepath.Dir(path)
+	dataFile, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "can't create a file for data")
+	}
+	defer os.Remove(dataFile.Name())
+
+	crc := crc32.New(crc32Table)
+	size, err := io.Copy(io.MultiWriter(dataFile, crc), src)
+	if err == nil {
+		err = dataFile.Sync()
+	}
+	if closeErr := dataFile.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "can't write data into '%s'", dataFile.Name())
+	}
+
+	meta := marshalSavedMeta(size, crc.Sum32())
+	metaFile, err := writeSyncedTempFile(dir, filepath.Base(path)+SavedMetaSuffix+".tmp-*", meta)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(metaFile)
+
+	// Remove the old metadata first: the old metadata must not describe the new data
+	err = os.Remove(path + SavedMetaSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove old metadata '%s'", path+SavedMetaSuffix)
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(dataFile.Name(), path); err != nil {
+		return errors.Wrapf(err, "can't rename data file into '%s'", path)
+	}
+	if err := os.Rename(metaFile, path+SavedMetaSuffix); err != nil {
+		return errors.Wrapf(err, "can't rename metadata file into '%s'", path+SavedMetaSuffix)
+	}
+	return syncDir(dir)
+}
+
+// OpenSaved opens a Buffer saved by Save. The data is verified against the metadata. ErrNotSaved is
+// returned if the Buffer wasn't saved completely. The returned Buffer is read-only, and the files aren't
+// removed after reading or on Reset() (see RemoveSaved)
+func OpenSaved(path string) (*Buffer, error) {
+	meta, err := ioutil.ReadFile(path + SavedMetaSuffix)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrNotSaved, "metadata '%s' doesn't exist", path+SavedMetaSuffix)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read metadata '%s'", path+SavedMetaSuffix)
+	}
+	size, sum, err := unmarshalSavedMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrNotSaved, "data file '%s' doesn't exist", path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open data file '%s'", path)
+	}
+	defer file.Close()
+
+	crc := crc32.New(crc32Table)
+	n, err := io.Copy(crc, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read data file '%s'", path)
+	}
+	if n != size || crc.Sum32() != sum {
+		return nil, errors.Wrapf(ErrNotSaved, "data file '%s' doesn't match its metadata", path)
+	}
+
+	b := NewBufferWithMaxMemorySize(0)
+	b.filename = path
+	b.keepFile = true
+	b.useFile = true
+	b.writingFinished = true
+	b.size = size
+	b.fileSize = size
+
+	return b, nil
+}
+
+// RemoveSaved removes a Buffer saved by Save. The metadata file is removed first, so the Buffer
+// is considered absent even if the removal is interrupted
+func RemoveSaved(path string) error {
+	err := os.Remove(path + SavedMetaSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove metadata '%s'", path+SavedMetaSuffix)
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't remove data file '%s'", path)
+	}
+	return nil
+}
+
+func marshalSavedMeta(size int64, sum uint32) []byte {
+	meta := make([]byte, 0, savedMetaSize)
+	meta = append(meta, savedMetaMagic[:]...)
+	meta = append(meta, savedMetaVersion)
+	meta = binary.LittleEndian.AppendUint64(meta, uint64(size))
+	meta = binary.LittleEndian.AppendUint32(meta, sum)
+	return meta
+}
+
+func unmarshalSavedMeta(meta []byte) (size int64, sum uint32, err error) {
+	switch {
+	case len(meta) != savedMetaSize, !bytes.HasPrefix(meta, savedMetaMagic[:]):
+		return 0, 0, errors.Wrap(ErrNotSaved, "invalid metadata")
+	case meta[len(savedMetaMagic)] != savedMetaVersion:
+		return 0, 0, errors.Errorf("unsupported metadata version: %d", meta[len(savedMetaMagic)])
+	}
+
+	meta = meta[len(savedMetaMagic)+1:]
+	size = int64(binary.LittleEndian.Uint64(meta))
+	sum = binary.LittleEndian.Uint32(meta[8:])
+	return size, sum, nil
+}
+
+// writeSyncedTempFile writes data into a new temp file in dir and syncs it. It returns the name of the file
+func writeSyncedTempFile(dir, pattern string, data []byte) (string, error) {
+	file, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return "", errors.Wrap(err, "can't create a temp file")
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", errors.Wrapf(err, "can't write file '%s'", file.Name())
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package buffer
+
+// syncDir does nothing: directories can't be synced on this platform
+func syncDir(string) error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Save(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "saved")
+
+	_, err = OpenSaved(path)
+	require.True(errors.Is(err, ErrNotSaved), "got %v", err)
+
+	for _, size := range []int{50, 10000} {
+		slice := []byte(generateRandomString(size))
+
+		b := NewBufferWithMaxMemorySize(100)
+		writeByChunks(require, b, slice, 7)
+
+		res := make([]byte, 10)
+		_, err = b.Read(res)
+		require.Nil(err)
+
+		// The previous file is replaced
+		require.Nil(b.Save(path))
+		res = append(res, readByChunks(require, b, 16)...)
+		require.Equal(slice, res, "read position must not be changed")
+		b.Reset()
+
+		saved, err := OpenSaved(path)
+		require.Nil(err)
+		require.Equal(slice[10:], readByChunks(require, saved, 16))
+		saved.Reset()
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Len(files, 2, "temp files must be removed")
+
+	// Interrupted saving: the data was replaced, but the metadata wasn't
+	require.Nil(ioutil.WriteFile(path, []byte("torn"), 0600))
+	_, err = OpenSaved(path)
+	require.True(errors.Is(err, ErrNotSaved), "got %v", err)
+
+	require.Nil(RemoveSaved(path))
+	_, err = OpenSaved(path)
+	require.True(errors.Is(err, ErrNotSaved), "got %v", err)
+	files, err = ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Empty(files)
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package buffer
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// syncDir syncs dir, so renames of files in it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "can't open directory '%s'", dir)
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "can't sync directory '%s'", dir)
+	}
+	return nil
+}
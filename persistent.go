@@ -0,0 +1,289 @@
+package buffer
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// persistentRecordSize is the max size of data in a record of a persistent Buffer
+	persistentRecordSize = 64 << 10 // 64 KB
+	// persistentHeaderSize is the size of a record header: data size (4 bytes) | CRC-32C of data (4 bytes)
+	persistentHeaderSize = 8
+)
+
+// OpenPersistent opens a persistent Buffer stored in a file at path. The file is created if it doesn't exist.
+// Data is written straight into the file as records with checksums, so the Buffer survives a crash of the process.
+//
+// If the Buffer was being written when the process died, OpenPersistent validates the records, truncates
+// the torn tail after the last complete record and continues appending. Data is buffered in records
+// of 64 KB: only data written before the last call of Sync is guaranteed to survive a crash.
+//
+// The file is locked with flock till the Buffer is reset or read, so records of two Buffers can't be mixed.
+// OpenPersistent returns ErrFileLocked if the file is used by another Buffer or process.
+//
+// The file isn't removed after reading or on Reset(). Options that change the format of the temp file
+// (encryption, checksums, mirrors, etc.) don't apply to persistent Buffers
+func OpenPersistent(path string) (*Buffer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open file '%s'", path)
+	}
+
+	var lock *os.File
+	if fileLockingSupported {
+		// The file is locked before the records are validated: the torn tail of a file that is
+		// being written must not be truncated
+		lock, err = lockFile(path)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrapf(err, "persistent file '%s' is in use", path)
+		}
+	}
+
+	index, err := scanPersistentRecords(file)
+	if err != nil {
+		file.Close()
+		if lock != nil {
+			lock.Close()
+		}
+		return nil, errors.Wrapf(err, "can't read file '%s'", path)
+	}
+	// Drop the torn tail
+	err = file.Truncate(index.fileSize)
+	if err == nil {
+		_, err = file.Seek(index.fileSize, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		if lock != nil {
+			lock.Close()
+		}
+		return nil, errors.Wrapf(err, "can't truncate file '%s'", path)
+	}
+
+	b := NewBufferWithMaxMemorySize(0)
+	b.filename = path
+	b.keepFile = true
+	b.fileLock = lock
+	b.useFile = true
+	b.size = index.size
+	b.fileSize = index.size
+	b.persistentIndex = index
+	b.writeFile = &persistentWriter{file: file, index: index}
+
+	return b, nil
+}
+
+// Sync writes buffered data of a persistent Buffer into its file and commits the file to stable storage.
+// It does nothing for other Buffers
+func (b *Buffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.writeFile.(*persistentWriter)
+	if !ok {
+		return nil
+	}
+	return w.Sync()
+}
+
+// persistentIndex contains offsets of records of a persistent Buffer
+type persistentIndex struct {
+	// records contains the offsets of records in data
+	records []int64
+	// fileOffsets contains the offsets of records in the file
+	fileOffsets []int64
+	// size is the size of data
+	size int64
+	// fileSize is the size of the file
+	fileSize int64
+}
+
+func (idx *persistentIndex) add(size int64) {
+	idx.records = append(idx.records, idx.size)
+	idx.fileOffsets = append(idx.fileOffsets, idx.fileSize)
+	idx.size += size
+	idx.fileSize += persistentHeaderSize + size
+}
+
+// scanPersistentRecords reads and verifies records of a persistent Buffer till the end of the file
+// or the first incomplete or corrupted record
+func scanPersistentRecords(file io.ReaderAt) (*persistentIndex, error) {
+	var (
+		index  = &persistentIndex{}
+		header = make([]byte, persistentHeaderSize)
+		data   = make([]byte, persistentRecordSize)
+	)
+	for {
+		_, err := file.ReadAt(header, index.fileSize)
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		size := binary.LittleEndian.Uint32(header)
+		if size == 0 || size > persistentRecordSize {
+			// The header is torn
+			return index, nil
+		}
+		_, err = file.ReadAt(data[:size], index.fileSize+persistentHeaderSize)
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if crc32.Checksum(data[:size], crc32Table) != binary.LittleEndian.Uint32(header[4:]) {
+			return index, nil
+		}
+
+		index.add(int64(size))
+	}
+}
+
+// persistentWriter writes data into a file of a persistent Buffer as records
+type persistentWriter struct {
+	file  *os.File
+	index *persistentIndex
+	buf   []byte
+}
+
+func (w *persistentWriter) Write(p []byte) (n int, err error) {
+	if w.buf == nil {
+		w.buf = make([]byte, 0, persistentRecordSize)
+	}
+
+	for len(p) > 0 {
+		copied := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+copied]
+		n += copied
+		p = p[copied:]
+
+		if len(w.buf) == cap(w.buf) {
+			err = w.flush()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush writes the buffered data as a record
+func (w *persistentWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var header [persistentHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(w.buf)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(w.buf, crc32Table))
+
+	_, err := w.file.Write(header[:])
+	if err == nil {
+		_, err = w.file.Write(w.buf)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "can't write a record into file '%s'", w.file.Name())
+	}
+
+	w.index.add(int64(len(w.buf)))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Sync writes the buffered data and syncs the file
+func (w *persistentWriter) Sync() error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+	err = w.file.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "can't sync file '%s'", w.file.Name())
+	}
+	return nil
+}
+
+// Close syncs and closes the file
+func (w *persistentWriter) Close() error {
+	err := w.Sync()
+	if closeErr := w.file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	return err
+}
+
+// persistentReader reads data of a persistent Buffer. ReadAt is safe for concurrent use
+type persistentReader struct {
+	file  readableFile
+	index *persistentIndex
+	// off is the offset of sequential reads
+	off int64
+}
+
+func newPersistentReader(file readableFile, index *persistentIndex) *persistentReader {
+	return &persistentReader{
+		file:  file,
+		index: index,
+	}
+}
+
+func (r *persistentReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *persistentReader) ReadAt(p []byte, off int64) (n int, err error) {
+	idx := r.index
+	// Find the record that contains off
+	i := sort.Search(len(idx.records), func(i int) bool { return idx.records[i] > off }) - 1
+
+	for len(p) > 0 && off < idx.size {
+		if i < 0 {
+			return n, errors.Errorf("invalid offset: %d", off)
+		}
+
+		end := idx.size
+		if i+1 < len(idx.records) {
+			end = idx.records[i+1]
+		}
+		chunk := p
+		if int64(len(chunk)) > end-off {
+			chunk = chunk[:end-off]
+		}
+
+		read, err := r.file.ReadAt(chunk, idx.fileOffsets[i]+persistentHeaderSize+off-idx.records[i])
+		n += read
+		off += int64(read)
+		p = p[read:]
+		if err == io.EOF && read == len(chunk) {
+			err = nil
+		}
+		if err != nil {
+			return n, err
+		}
+		i++
+	}
+
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *persistentReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenPersistent(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "persistent")
+
+	first := []byte(generateRandomString(200 << 10))
+	b, err := OpenPersistent(path)
+	require.Nil(err)
+	writeByChunks(require, b, first, 1000)
+	require.Nil(b.Sync())
+
+	// The file is used by the Buffer
+	if fileLockingSupported {
+		_, err = OpenPersistent(path)
+		require.True(errors.Is(err, ErrFileLocked), "file must be locked, got %v", err)
+	}
+
+	// The process dies: data after the last Sync is lost, the last record is torn. The lock is released
+	_, err = b.Write([]byte(generateRandomString(1000)))
+	require.Nil(err)
+	require.Nil(b.writeFile.(*persistentWriter).file.Close())
+	b.unlockTempFile()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.Nil(err)
+	_, err = file.Write([]byte{0x10, 0, 0, 0, 1, 2, 3, 4, 'a', 'b'})
+	require.Nil(err)
+	require.Nil(file.Close())
+
+	// Resume
+	b, err = OpenPersistent(path)
+	require.Nil(err)
+	require.Equal(len(first), b.Len(), "torn tail must be dropped")
+
+	second := []byte(generateRandomString(100 << 10))
+	_, err = b.Write(second)
+	require.Nil(err)
+	// Reset releases the lock
+	b.Reset()
+
+	b, err = OpenPersistent(path)
+	require.Nil(err)
+	defer b.Reset()
+
+	expected := append(first, second...)
+	require.Equal(len(expected), b.Len())
+
+	at := make([]byte, 70<<10)
+	_, err = b.ReadAt(at, 60<<10)
+	require.Nil(err)
+	require.Equal(expected[60<<10:130<<10], at)
+
+	require.Equal(expected, readByChunks(require, b, 1000))
+
+	_, err = os.Stat(path)
+	require.Nil(err, "file must be kept")
+}
@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// TempFileStrategy creates, opens and removes temp files. Implementations hide platform-specific
+// behavior: DefaultTempFileStrategy uses the best options of the current platform (for example,
+// FILE_ATTRIBUTE_TEMPORARY on Windows). TempFileStrategy must be safe for concurrent use
+type TempFileStrategy interface {
+	// Create creates a new temp file in dir for reading and writing. pattern has the same meaning
+	// as in ioutil.TempFile
+	Create(dir, pattern string) (*os.File, error)
+	// Open opens the temp file for reading
+	Open(name string) (*os.File, error)
+	// Remove removes the temp file
+	Remove(name string) error
+}
+
+// SequentialOpener is an optional interface of TempFileStrategy. OpenSequential opens the temp file
+// that is going to be read once from the beginning to the end, so the platform can optimize caching
+// (FILE_FLAG_SEQUENTIAL_SCAN on Windows, for example). Open is used for random access (see Buffer.ReadAt)
+type SequentialOpener interface {
+	OpenSequential(name string) (*os.File, error)
+}
+
+var (
+	// DefaultTempFileStrategy is the platform-specific TempFileStrategy used by default
+	DefaultTempFileStrategy TempFileStrategy = platformTempFiles{}
+	// PortableTempFileStrategy uses only functions of package os. It behaves the same way on all platforms
+	PortableTempFileStrategy TempFileStrategy = portableTempFiles{}
+)
+
+// SetTempFileStrategy overrides the strategy for temp files. DefaultTempFileStrategy is used
+// if strategy is nil. It must be called before the first Write
+func (b *Buffer) SetTempFileStrategy(strategy TempFileStrategy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tempFileStrategy = strategy
+}
+
+// tempFiles returns the strategy for temp files
+func (b *Buffer) tempFiles() TempFileStrategy {
+	if b.tempFileStrategy == nil {
+		return DefaultTempFileStrategy
+	}
+	return b.tempFileStrategy
+}
+
+// openSequential opens the temp file for sequential reading with the strategy
+func openSequential(strategy TempFileStrategy, name string) (*os.File, error) {
+	if opener, ok := strategy.(SequentialOpener); ok {
+		return opener.OpenSequential(name)
+	}
+	return strategy.Open(name)
+}
+
+type portableTempFiles struct{}
+
+func (portableTempFiles) Create(dir, pattern string) (*os.File, error) {
+	return ioutil.TempFile(dir, pattern)
+}
+
+func (portableTempFiles) Open(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
+func (portableTempFiles) Remove(name string) error {
+	return os.Remove(name)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package buffer
+
+// platformTempFiles is the TempFileStrategy for the current platform. Package os is good enough
+// for all platforms except Windows
+type platformTempFiles struct {
+	portableTempFiles
+}
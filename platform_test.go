@@ -0,0 +1,91 @@
+package buffer
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTempFiles records calls of the underlying TempFileStrategy
+type recordingTempFiles struct {
+	TempFileStrategy
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recordingTempFiles) record(call string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+func (r *recordingTempFiles) Create(dir, pattern string) (*os.File, error) {
+	r.record("create")
+	return r.TempFileStrategy.Create(dir, pattern)
+}
+
+func (r *recordingTempFiles) Open(name string) (*os.File, error) {
+	r.record("open")
+	return r.TempFileStrategy.Open(name)
+}
+
+func (r *recordingTempFiles) Remove(name string) error {
+	r.record("remove")
+	return r.TempFileStrategy.Remove(name)
+}
+
+func TestBuffer_TempFileStrategy(t *testing.T) {
+	for _, strategy := range []TempFileStrategy{DefaultTempFileStrategy, PortableTempFileStrategy} {
+		require := require.New(t)
+
+		tempFiles := &recordingTempFiles{TempFileStrategy: strategy}
+
+		b := NewBufferWithMaxMemorySize(10)
+		b.SetTempFileStrategy(tempFiles)
+
+		slice := []byte(generateRandomString(1 << 16))
+		writeByChunks(require, b, slice, 1024)
+		filename := b.filename
+
+		res := readByChunks(require, b, 1024)
+		require.Equal(slice, res, "wrong content was read")
+
+		require.Equal([]string{"create", "open", "remove"}, tempFiles.calls)
+		_, err := os.Stat(filename)
+		require.True(os.IsNotExist(err), "temp file must be removed")
+	}
+}
+
+// sequentialTempFiles is a recordingTempFiles that implements SequentialOpener
+type sequentialTempFiles struct {
+	*recordingTempFiles
+}
+
+func (s sequentialTempFiles) OpenSequential(name string) (*os.File, error) {
+	s.record("open sequential")
+	return s.TempFileStrategy.Open(name)
+}
+
+func TestBuffer_SequentialOpener(t *testing.T) {
+	require := require.New(t)
+
+	tempFiles := sequentialTempFiles{&recordingTempFiles{TempFileStrategy: DefaultTempFileStrategy}}
+
+	b := NewBufferWithMaxMemorySize(10)
+	b.SetTempFileStrategy(tempFiles)
+
+	slice := []byte(generateRandomString(1 << 16))
+	writeByChunks(require, b, slice, 1024)
+
+	// ReadAt uses random access
+	_, err := b.ReadAt(make([]byte, 100), 1000)
+	require.Nil(err)
+
+	res := readByChunks(require, b, 1024)
+	require.Equal(slice, res, "wrong content was read")
+
+	require.Equal([]string{"create", "open", "open sequential", "remove"}, tempFiles.calls)
+}
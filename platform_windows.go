@@ -0,0 +1,89 @@
+package buffer
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	fileAttributeTemporary = 0x100
+	fileFlagSequentialScan = 0x08000000
+)
+
+// platformTempFiles is the TempFileStrategy for Windows. Temp files are created with FILE_ATTRIBUTE_TEMPORARY,
+// so the system tries to keep them in cache. Files are opened for sequential reading with FILE_FLAG_SEQUENTIAL_SCAN,
+// so the cache manager reads ahead aggressively and drops read pages early. All files are opened with
+// FILE_SHARE_DELETE, so they can be removed while they are open
+type platformTempFiles struct{}
+
+func (platformTempFiles) Create(dir, pattern string) (*os.File, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i != -1 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+
+		f, err := createFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.CREATE_NEW, fileAttributeTemporary)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, errors.Errorf("can't create a temp file in '%s': too many attempts", dir)
+}
+
+func (platformTempFiles) Open(name string) (*os.File, error) {
+	return createFile(name, syscall.GENERIC_READ, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL)
+}
+
+func (platformTempFiles) OpenSequential(name string) (*os.File, error) {
+	return createFile(name, syscall.GENERIC_READ, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL|fileFlagSequentialScan)
+}
+
+// Remove removes the file. If the file is still opened by another process (an antivirus scanner,
+// for example), the removal is deferred and retried in background, see SetDeletionFailureHook
+func (platformTempFiles) Remove(name string) error {
+	err := os.Remove(name)
+	if err != nil && isFileInUse(err) {
+		deferRemoval(name, os.Remove)
+		return nil
+	}
+	return err
+}
+
+const errorSharingViolation syscall.Errno = 32
+
+// isFileInUse reports whether err is caused by a handle of the file opened without FILE_SHARE_DELETE
+func isFileInUse(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorSharingViolation || errno == syscall.ERROR_ACCESS_DENIED
+}
+
+func createFile(name string, access, mode, attrs uint32) (*os.File, error) {
+	path, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	share := uint32(syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE)
+	h, err := syscall.CreateFile(path, access, share, nil, mode, attrs, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
@@ -0,0 +1,25 @@
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE: the size of the file isn't changed
+const fallocKeepSize = 0x01
+
+// preallocate allocates n bytes for the file without changing its size
+func preallocate(filename string, n int64) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		err = syscall.Fallocate(int(file.Fd()), fallocKeepSize, 0, n)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package buffer
+
+// preallocate is a no-op on platforms without fallocate
+func preallocate(string, int64) error {
+	return nil
+}
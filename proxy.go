@@ -0,0 +1,139 @@
+package buffer
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// BufferingTransport is an http.RoundTripper for reverse proxies (see httputil.ReverseProxy.Transport).
+// It reads a whole response body into a Buffer before the response is returned, so the body is stored
+// on a disk when it exceeds MaxInMemorySize. If an upstream fails in the middle of the body, the request
+// is retried before anything is sent to the client.
+//
+// Trailers are available when the response is returned. Event streams (text/event-stream) aren't
+// buffered: they must be flushed to the client immediately
+type BufferingTransport struct {
+	// Transport is used to send requests. http.DefaultTransport is used if it is nil
+	Transport http.RoundTripper
+	// MaxInMemorySize is the max size of a body stored in memory
+	MaxInMemorySize int
+	// MaxRetries is the max number of retries after errors. Only idempotent requests are retried
+	// (GET, HEAD, OPTIONS, TRACE or requests with an Idempotency-Key header). Requests with a body are
+	// retried only if it can be replayed (see http.Request.GetBody)
+	MaxRetries int
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *BufferingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			err = BufferResponse(resp, t.MaxInMemorySize)
+			if err == nil {
+				return resp, nil
+			}
+		}
+
+		if attempt >= t.MaxRetries || !isIdempotent(req) || req.Context().Err() != nil {
+			return nil, err
+		}
+		next, replayErr := replayRequest(req)
+		if replayErr != nil {
+			// Report the error of the upstream
+			return nil, err
+		}
+		req = next
+	}
+}
+
+// isIdempotent reports whether req can be sent again. The upstream could already process a failed
+// request, so other requests would run their side effects twice. It matches the rules of net/http
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	// An Idempotency-Key header with any value (even empty) marks the request as idempotent
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+// replayRequest returns a copy of req with a new body
+func replayRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body can't be replayed")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't replay request body")
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+// BufferResponse reads the body of resp into a Buffer and replaces the body with the Buffer.
+// The upstream connection is released before the function returns. The Buffer is reset when
+// the new body is closed. If the body can't be read, it is closed and an error is returned.
+//
+// Event streams (text/event-stream) and responses without a body are left as is
+func BufferResponse(resp *http.Response, maxInMemorySize int) error {
+	if resp.Body == nil || resp.Body == http.NoBody || isEventStream(resp) {
+		return nil
+	}
+
+	b := NewBufferWithMaxMemorySize(maxInMemorySize)
+	_, err := b.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		b.Reset()
+		return errors.Wrap(err, "can't read response body")
+	}
+
+	// Trailers are filled when the body is read. Content-Length isn't set: it would prevent
+	// sending them with chunked encoding
+	resp.Body = &responseBody{b: b}
+	return nil
+}
+
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// responseBody is a body of http.Response stored in a Buffer
+type responseBody struct {
+	b *Buffer
+}
+
+func (rb *responseBody) Read(p []byte) (int, error) {
+	return rb.b.Read(p)
+}
+
+// WriteTo allows io.Copy to write data without an intermediate buffer
+func (rb *responseBody) WriteTo(w io.Writer) (int64, error) {
+	return rb.b.WriteTo(w)
+}
+
+func (rb *responseBody) Close() error {
+	rb.b.Reset()
+	return nil
+}
@@ -0,0 +1,123 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferingTransport(t *testing.T) {
+	require := require.New(t)
+
+	body := []byte(generateRandomString(1000))
+
+	var requests int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// The first response is broken in the middle of the body
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body[:100])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		w.Write(body)
+		w.Header().Set("X-Checksum", "123")
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.Nil(err)
+
+	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	proxy.Transport = &BufferingTransport{
+		MaxInMemorySize: 10,
+		MaxRetries:      1,
+	}
+	proxy.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.Nil(err)
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+	res, err := ioutil.ReadAll(resp.Body)
+	require.Nil(err)
+	require.Equal(body, res, "wrong content was read")
+	require.Equal("123", resp.Trailer.Get("X-Checksum"))
+	require.Equal(int32(2), atomic.LoadInt32(&requests))
+
+	// No retries
+	atomic.StoreInt32(&requests, 0)
+	proxy.Transport = &BufferingTransport{MaxInMemorySize: 10}
+
+	resp, err = http.Get(server.URL)
+	require.Nil(err)
+	resp.Body.Close()
+	require.Equal(int32(1), atomic.LoadInt32(&requests))
+	require.Equal(http.StatusBadGateway, resp.StatusCode)
+
+	// Non-idempotent requests aren't retried even if their bodies can be replayed
+	client := &http.Client{Transport: &BufferingTransport{MaxInMemorySize: 10, MaxRetries: 1}}
+	for _, tt := range []struct {
+		header   string
+		requests int32
+	}{
+		{requests: 1},
+		{header: "Idempotency-Key", requests: 2},
+	} {
+		atomic.StoreInt32(&requests, 0)
+
+		req, err := http.NewRequest(http.MethodPost, upstream.URL, strings.NewReader("data"))
+		require.Nil(err)
+		if tt.header != "" {
+			req.Header.Set(tt.header, "123")
+		}
+		resp, err = client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		require.Equal(tt.requests == 1, err != nil)
+		require.Equal(tt.requests, atomic.LoadInt32(&requests))
+	}
+}
+
+func TestBufferResponse(t *testing.T) {
+	require := require.New(t)
+
+	for _, tt := range []struct {
+		contentType string
+		buffered    bool
+	}{
+		{contentType: "text/plain", buffered: true},
+		{contentType: "text/event-stream; charset=utf-8", buffered: false},
+	} {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", tt.contentType)
+		rec.WriteString("hello world")
+		resp := rec.Result()
+
+		require.Nil(BufferResponse(resp, 5))
+
+		_, buffered := resp.Body.(*responseBody)
+		require.Equal(tt.buffered, buffered)
+
+		res, err := ioutil.ReadAll(resp.Body)
+		require.Nil(err)
+		require.Equal("hello world", string(res))
+		require.Nil(resp.Body.Close())
+	}
+}
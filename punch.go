@@ -0,0 +1,50 @@
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrHolePunchingUnsupported is returned by PunchHoles when the platform, the file system or
+// the format of the temp file doesn't support hole punching
+var ErrHolePunchingUnsupported = errors.New("hole punching isn't supported")
+
+// holePunchAlignment is the size of blocks deallocated by PunchHoles. Partial blocks are kept
+const holePunchAlignment = 4 << 10 // 4 KB
+
+// PunchHoles deallocates the disk blocks of the read part of the temp file (FALLOC_FL_PUNCH_HOLE on Linux).
+// Unlike Compact, the file isn't rewritten, so it is cheap for very large files. The size of the file
+// isn't changed, and ReadAt returns ErrCompacted for the deallocated data.
+//
+// Encrypted files and files with checksums can't be punched: they are read from the beginning when reopened
+func (b *Buffer) PunchHoles() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.finishWriting()
+	if err != nil {
+		return err
+	}
+	if !b.useFile || b.keepFile || b.readingFinished || b.unlinkedFile != nil {
+		return nil
+	}
+	if b.encrypt || b.checksums != nil {
+		return errors.Wrap(ErrHolePunchingUnsupported, "temp file is encrypted or has checksums")
+	}
+
+	end := (b.fileConsumed() - b.fileDropped) / holePunchAlignment * holePunchAlignment
+	if end <= b.filePunched {
+		return nil
+	}
+
+	err = punchHole(b.filename, b.filePunched, end-b.filePunched)
+	if err != nil {
+		return errors.Wrapf(err, "can't punch a hole in temp file '%s'", b.filename)
+	}
+	if b.mirrorFilename != "" {
+		// The mirror is used only for recovery, so its errors are ignored
+		punchHole(b.mirrorFilename, b.filePunched, end-b.filePunched)
+	}
+	b.filePunched = end
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+// FALLOC_FL_PUNCH_HOLE must be used with FALLOC_FL_KEEP_SIZE
+const fallocPunchHole = 0x02
+
+func punchHole(filename string, off, size int64) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		err = syscall.Fallocate(int(file.Fd()), fallocPunchHole|fallocKeepSize, off, size)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EOPNOTSUPP:
+			return ErrHolePunchingUnsupported
+		}
+		return err
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package buffer
+
+func punchHole(string, int64, int64) error {
+	return ErrHolePunchingUnsupported
+}
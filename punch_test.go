@@ -0,0 +1,52 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_PunchHoles(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(100 << 10))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 1000)
+
+	res := make([]byte, 50<<10)
+	_, err := b.Read(res)
+	require.Nil(err)
+
+	err = b.PunchHoles()
+	if errors.Is(err, ErrHolePunchingUnsupported) {
+		t.Skip("hole punching isn't supported")
+	}
+	require.Nil(err)
+	require.True(b.filePunched > 0 && b.filePunched <= 50<<10-100, "wrong punched size: %d", b.filePunched)
+
+	_, err = b.ReadAt(make([]byte, 10), 1000)
+	require.True(errors.Is(err, ErrCompacted), "got %v", err)
+
+	// Reopened file must skip the holes
+	b.closeReadFile()
+	res = append(res, readByChunks(require, b, 1000)...)
+	require.Equal(slice, res)
+}
+
+func TestBuffer_PunchHolesEncrypted(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	require.Nil(b.EnableEncryption())
+	writeByChunks(require, b, []byte(generateRandomString(10<<10)), 1000)
+
+	_, err := b.Read(make([]byte, 5<<10))
+	require.Nil(err)
+
+	err = b.PunchHoles()
+	require.True(errors.Is(err, ErrHolePunchingUnsupported), "got %v", err)
+}
@@ -0,0 +1,226 @@
+package buffer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSpace is returned when there's not enough space to store data on a disk
+var ErrNoSpace = errors.New("not enough space")
+
+// Quota limits the total size of data that Buffers store on a disk. One Quota can be shared
+// by multiple Buffers (see Buffer.SetQuota). Quota is thread-safe
+type Quota struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit int64
+	used  int64
+	// buffers contains the space used by every Buffer
+	buffers map[*Buffer]int64
+
+	// block makes Writes wait for free space instead of returning ErrNoSpace
+	block bool
+
+	alerts []*quotaAlert
+}
+
+// QuotaUsage is the space of a Quota used by a Buffer
+type QuotaUsage struct {
+	Buffer *Buffer
+	Used   int64
+}
+
+// QuotaAlert is passed to alert handlers when the usage of a Quota reaches a threshold
+type QuotaAlert struct {
+	// Threshold is the threshold of the alert
+	Threshold float64
+	Used      int64
+	Limit     int64
+	// Buffers contains the usage of the Buffers that store data on a disk. They are sorted by usage in descending order
+	Buffers []QuotaUsage
+}
+
+type quotaAlert struct {
+	threshold float64
+	handler   func(QuotaAlert)
+	// fired is true while the usage is above the threshold
+	fired bool
+}
+
+// NewQuota creates a new Quota that allows to store up to limit bytes on a disk
+func NewQuota(limit int64) *Quota {
+	q := &Quota{
+		limit:   limit,
+		buffers: make(map[*Buffer]int64),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+// SetBlocking changes the behavior of Writes that would exceed the quota. If block is true,
+// they wait until other Buffers free enough space. Otherwise, they fail with ErrNoSpace (default).
+//
+// Writes that are larger than the whole quota always fail with ErrNoSpace. So do Writes that can be satisfied
+// only by the space used by the same Buffer. Buffer.FlushToDisk doesn't wait either
+func (q *Quota) SetBlocking(block bool) {
+	q.mu.Lock()
+	q.block = block
+	q.mu.Unlock()
+
+	// Waiting Writes must fail if blocking was disabled
+	q.cond.Broadcast()
+}
+
+// Limit returns the max number of bytes that can be stored on a disk
+func (q *Quota) Limit() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.limit
+}
+
+// Used returns the number of bytes that are currently stored on a disk
+func (q *Quota) Used() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.used
+}
+
+// AddAlert makes the Quota call handler when the usage reaches threshold (a fraction of the limit,
+// 0.8 means 80%, for example). So, services can shed load before Writes fail with ErrNoSpace.
+// The alert fires once: it is rearmed when the usage drops below the threshold.
+//
+// handler is called in a separate goroutine, because the usage changes during Writes
+func (q *Quota) AddAlert(threshold float64, handler func(QuotaAlert)) error {
+	if threshold <= 0 || threshold > 1 {
+		return errors.Errorf("invalid threshold: %v, must be in (0, 1]", threshold)
+	}
+	if handler == nil {
+		return errors.New("handler can't be nil")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.alerts = append(q.alerts, &quotaAlert{
+		threshold: threshold,
+		handler:   handler,
+	})
+	q.checkAlerts()
+	return nil
+}
+
+// checkAlerts fires alerts whose thresholds were reached and rearms alerts whose thresholds
+// aren't reached anymore. q.mu must be locked
+func (q *Quota) checkAlerts() {
+	for _, alert := range q.alerts {
+		reached := q.limit > 0 && float64(q.used) >= alert.threshold*float64(q.limit)
+		if reached == alert.fired {
+			continue
+		}
+		alert.fired = reached
+		if reached {
+			go alert.handler(q.newAlert(alert.threshold))
+		}
+	}
+}
+
+// newAlert returns QuotaAlert with the current usage. q.mu must be locked
+func (q *Quota) newAlert(threshold float64) QuotaAlert {
+	buffers := make([]QuotaUsage, 0, len(q.buffers))
+	for b, used := range q.buffers {
+		buffers = append(buffers, QuotaUsage{Buffer: b, Used: used})
+	}
+	sort.Slice(buffers, func(i, j int) bool {
+		return buffers[i].Used > buffers[j].Used
+	})
+
+	return QuotaAlert{
+		Threshold: threshold,
+		Used:      q.used,
+		Limit:     q.limit,
+		Buffers:   buffers,
+	}
+}
+
+// quotaWaitError is returned by acquire when a blocking quota doesn't have enough space. Buffer.Write waits
+// for free space with unlocked b.mu and retries. Callers that can't wait get ErrNoSpace
+type quotaWaitError struct {
+	quota *Quota
+	n     int64
+}
+
+func (e *quotaWaitError) Error() string {
+	return fmt.Sprintf("%s: can't store %d bytes: waiting for free space of the quota", ErrNoSpace, e.n)
+}
+
+func (e *quotaWaitError) Cause() error {
+	return ErrNoSpace
+}
+
+func (e *quotaWaitError) Unwrap() error {
+	return ErrNoSpace
+}
+
+// acquire reserves n bytes for b. It returns ErrNoSpace if there's not enough space and the quota isn't blocking.
+// It doesn't wait with locked b.mu: it returns *quotaWaitError instead
+func (q *Quota) acquire(b *Buffer, n int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if n > q.limit {
+		return errors.Wrapf(ErrNoSpace, "can't store %d bytes: quota limit is %d bytes", n, q.limit)
+	}
+
+	if q.used+n > q.limit {
+		if !q.block {
+			return errors.Wrapf(ErrNoSpace, "can't store %d bytes: quota has only %d free bytes", n, q.limit-q.used)
+		}
+		if q.buffers[b]+n > q.limit {
+			// Other Buffers can't free enough space: the Buffer would wait for itself
+			return errors.Wrapf(ErrNoSpace, "can't store %d bytes: the Buffer already uses %d bytes of the quota limit %d bytes",
+				n, q.buffers[b], q.limit)
+		}
+		return &quotaWaitError{quota: q, n: n}
+	}
+	q.used += n
+	q.buffers[b] += n
+	q.checkAlerts()
+
+	return nil
+}
+
+// wait waits until n bytes are free or the quota stops blocking. b.mu must not be locked, so
+// the Buffer can be read or reset meanwhile
+func (q *Quota) wait(n int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.block && q.used+n > q.limit {
+		q.cond.Wait()
+	}
+}
+
+// release frees n bytes used by b
+func (q *Quota) release(b *Buffer, n int64) {
+	if n == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	q.used -= n
+	q.buffers[b] -= n
+	if q.buffers[b] == 0 {
+		delete(q.buffers, b)
+	}
+	q.checkAlerts()
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+}
@@ -0,0 +1,230 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	t.Run("Non-blocking", func(t *testing.T) {
+		require := require.New(t)
+
+		quota := NewQuota(100)
+
+		b1 := NewBufferWithMaxMemorySize(10)
+		b1.SetQuota(quota)
+		defer b1.Reset()
+
+		b2 := NewBufferWithMaxMemorySize(10)
+		b2.SetQuota(quota)
+		defer b2.Reset()
+
+		// Only 60 bytes are stored on a disk
+		n, err := b1.Write([]byte(generateRandomString(70)))
+		require.Nil(err)
+		require.Equal(70, n)
+		require.Equal(int64(60), quota.Used())
+
+		// Data in memory doesn't need the quota
+		n, err = b2.Write([]byte(generateRandomString(10)))
+		require.Nil(err)
+		require.Equal(10, n)
+
+		_, err = b2.Write([]byte(generateRandomString(50)))
+		require.True(errors.Is(err, ErrNoSpace))
+		require.Equal(int64(60), quota.Used())
+
+		// Free the space
+		b1.Reset()
+		require.Equal(int64(0), quota.Used())
+
+		n, err = b2.Write([]byte(generateRandomString(50)))
+		require.Nil(err)
+		require.Equal(50, n)
+		require.Equal(int64(50), quota.Used())
+
+		// Reading must free the space too
+		readByChunks(require, b2, 16)
+		require.Equal(int64(0), quota.Used())
+	})
+
+	t.Run("Blocking", func(t *testing.T) {
+		require := require.New(t)
+
+		quota := NewQuota(100)
+		quota.SetBlocking(true)
+
+		b1 := NewBufferWithMaxMemorySize(0)
+		b1.SetQuota(quota)
+		defer b1.Reset()
+
+		b2 := NewBufferWithMaxMemorySize(0)
+		b2.SetQuota(quota)
+		defer b2.Reset()
+
+		_, err := b1.Write([]byte(generateRandomString(80)))
+		require.Nil(err)
+
+		done := make(chan error)
+		go func() {
+			_, err := b2.Write([]byte(generateRandomString(50)))
+			done <- err
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("Write must wait for free space")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		b1.Reset()
+
+		select {
+		case err := <-done:
+			require.Nil(err)
+		case <-time.After(time.Second):
+			t.Fatal("Write must be unblocked after Reset()")
+		}
+
+		// Writes larger than the quota can't be satisfied
+		_, err = b1.Write([]byte(generateRandomString(101)))
+		require.True(errors.Is(err, ErrNoSpace))
+	})
+
+	t.Run("Blocking, own usage", func(t *testing.T) {
+		require := require.New(t)
+
+		quota := NewQuota(100)
+		quota.SetBlocking(true)
+
+		b := NewBufferWithMaxMemorySize(0)
+		b.SetQuota(quota)
+		defer b.Reset()
+
+		_, err := b.Write([]byte(generateRandomString(60)))
+		require.Nil(err)
+
+		// Only the Buffer itself can free the space, so the Write must not wait for it
+		done := make(chan error)
+		go func() {
+			_, err := b.Write([]byte(generateRandomString(60)))
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			require.True(errors.Is(err, ErrNoSpace))
+		case <-time.After(time.Second):
+			t.Fatal("Write must fail instead of waiting for the space of its own Buffer")
+		}
+		require.Equal(60, b.Len())
+		require.Equal(int64(60), quota.Used())
+	})
+
+	t.Run("Blocking, unlocked Buffer", func(t *testing.T) {
+		require := require.New(t)
+
+		quota := NewQuota(100)
+		quota.SetBlocking(true)
+
+		manager := NewManager()
+
+		b1 := manager.NewBufferWithMaxMemorySize(0)
+		b1.SetQuota(quota)
+		defer b1.Reset()
+
+		b2 := manager.NewBufferWithMaxMemorySize(10)
+		b2.SetQuota(quota)
+		defer b2.Reset()
+
+		_, err := b1.Write([]byte(generateRandomString(80)))
+		require.Nil(err)
+
+		done := make(chan error)
+		go func() {
+			_, err := b2.Write([]byte(generateRandomString(60)))
+			done <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		// The waiting Write must not hold the lock of the Buffer
+		locked := make(chan struct{})
+		go func() {
+			b2.Len()
+			manager.Stats()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Fatal("Buffer must not be locked while Write waits for the quota")
+		}
+
+		b1.Reset()
+		select {
+		case err := <-done:
+			require.Nil(err)
+		case <-time.After(time.Second):
+			t.Fatal("Write must be unblocked after Reset()")
+		}
+		require.Equal(60, b2.Len())
+		require.Equal(int64(50), quota.Used())
+	})
+}
+
+func TestQuota_AddAlert(t *testing.T) {
+	require := require.New(t)
+
+	quota := NewQuota(100)
+	alerts := make(chan QuotaAlert, 10)
+	require.Nil(quota.AddAlert(0.8, func(alert QuotaAlert) { alerts <- alert }))
+	require.NotNil(quota.AddAlert(1.5, func(QuotaAlert) {}))
+
+	b1 := NewBufferWithMaxMemorySize(0)
+	b1.SetQuota(quota)
+	defer b1.Reset()
+
+	b2 := NewBufferWithMaxMemorySize(0)
+	b2.SetQuota(quota)
+	defer b2.Reset()
+
+	_, err := b1.Write([]byte(generateRandomString(30)))
+	require.Nil(err)
+	_, err = b2.Write([]byte(generateRandomString(40)))
+	require.Nil(err)
+	select {
+	case <-alerts:
+		require.FailNow("threshold isn't reached")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	_, err = b2.Write([]byte(generateRandomString(10)))
+	require.Nil(err)
+
+	alert := <-alerts
+	require.Equal(0.8, alert.Threshold)
+	require.Equal(int64(80), alert.Used)
+	require.Equal(int64(100), alert.Limit)
+	require.Equal([]QuotaUsage{{Buffer: b2, Used: 50}, {Buffer: b1, Used: 30}}, alert.Buffers)
+
+	// The alert fires once
+	_, err = b1.Write([]byte(generateRandomString(10)))
+	require.Nil(err)
+
+	// and is rearmed when the usage drops
+	b2.Reset()
+	_, err = b1.Write([]byte(generateRandomString(40)))
+	require.Nil(err)
+
+	alert = <-alerts
+	require.Equal(int64(80), alert.Used)
+	require.Equal([]QuotaUsage{{Buffer: b1, Used: 80}}, alert.Buffers)
+	select {
+	case <-alerts:
+		require.FailNow("alert must fire once")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,130 @@
+package buffer
+
+import (
+	"io"
+)
+
+// DefaultReadAheadChunkSize is used when Buffer.EnableReadAhead is called with non-positive chunk size
+const DefaultReadAheadChunkSize = 64 << 10 // 64 KB
+
+// EnableReadAhead makes the Buffer read the temp file in a background goroutine. The goroutine
+// reads up to chunks chunks of chunkSize bytes in advance, so Read doesn't wait for disk IO
+// and decryption. It must be called before the first Read
+func (b *Buffer) EnableReadAhead(chunkSize, chunks int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultReadAheadChunkSize
+	}
+	if chunks < 0 {
+		chunks = 0
+	}
+
+	b.readAheadChunkSize = chunkSize
+	b.readAheadChunks = chunks
+}
+
+type readAheadChunk struct {
+	data []byte
+	err  error
+}
+
+// readAheadReader reads chunks from the underlying reader in a background goroutine
+type readAheadReader struct {
+	r io.ReadCloser
+
+	chunks chan readAheadChunk
+	// free contains consumed chunks that can be reused
+	free   chan []byte
+	done   chan struct{}
+	exited chan struct{}
+
+	current []byte
+	// buf is a slice current points to. It is returned to free when current is consumed
+	buf []byte
+	err error
+}
+
+func newReadAheadReader(r io.ReadCloser, chunkSize, chunks int) *readAheadReader {
+	rr := &readAheadReader{
+		r:      r,
+		chunks: make(chan readAheadChunk, chunks),
+		free:   make(chan []byte, chunks+1),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go rr.loop(chunkSize)
+
+	return rr
+}
+
+func (rr *readAheadReader) loop(chunkSize int) {
+	defer close(rr.exited)
+
+	for {
+		var buf []byte
+		select {
+		case buf = <-rr.free:
+		default:
+			buf = make([]byte, chunkSize)
+		}
+
+		n, err := io.ReadFull(rr.r, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+
+		select {
+		case rr.chunks <- readAheadChunk{data: buf[:n], err: err}:
+		case <-rr.done:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read fills p with prefetched data. It returns less than len(p) bytes only if the underlying
+// reader returned an error
+func (rr *readAheadReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(rr.current) == 0 {
+			if rr.buf != nil {
+				select {
+				case rr.free <- rr.buf[:cap(rr.buf)]:
+				default:
+				}
+				rr.buf = nil
+			}
+			if rr.err != nil {
+				break
+			}
+
+			chunk := <-rr.chunks
+			rr.current = chunk.data
+			rr.buf = chunk.data
+			rr.err = chunk.err
+			continue
+		}
+
+		copied := copy(p[n:], rr.current)
+		rr.current = rr.current[copied:]
+		n += copied
+	}
+
+	if n == 0 {
+		return 0, rr.err
+	}
+	return n, nil
+}
+
+// Close stops the background goroutine and closes the underlying reader
+func (rr *readAheadReader) Close() error {
+	close(rr.done)
+	<-rr.exited
+
+	return rr.r.Close()
+}
@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ReadAhead(t *testing.T) {
+	tests := []struct {
+		desc    string
+		encrypt bool
+	}{
+		{desc: "Without encryption", encrypt: false},
+		{desc: "With encryption", encrypt: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				t.Run("", func(t *testing.T) {
+					t.Parallel()
+
+					require := require.New(t)
+
+					var (
+						sliceSize     = rand.Intn(1<<16) + 1
+						bufferSize    = rand.Intn(sliceSize)
+						chunkSize     = rand.Intn(1<<12) + 1
+						chunks        = rand.Intn(4) + 1
+						readChunkSize = rand.Intn(1<<12) + 1
+					)
+
+					defer func() {
+						if t.Failed() {
+							t.Logf("sliceSize: %d; bufferSize: %d; chunkSize: %d; chunks: %d; readChunkSize: %d\n",
+								sliceSize, bufferSize, chunkSize, chunks, readChunkSize)
+						}
+					}()
+
+					slice := []byte(generateRandomString(sliceSize))
+
+					b := NewBufferWithMaxMemorySize(bufferSize)
+					defer b.Reset()
+
+					if tt.encrypt {
+						require.Nil(b.EnableEncryption())
+					}
+					b.EnableReadAhead(chunkSize, chunks)
+
+					writeByChunks(require, b, slice, 1024)
+
+					res := readByChunks(require, b, readChunkSize)
+					require.Equal(slice, res, "wrong content was read")
+				})
+			}
+		})
+	}
+
+	t.Run("Reset during reading", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		b.EnableReadAhead(16, 2)
+
+		_, err := b.Write([]byte(generateRandomString(1024)))
+		require.Nil(err)
+
+		data := make([]byte, 10)
+		_, err = b.Read(data)
+		require.Nil(err)
+
+		// Must stop the background goroutine without deadlocks
+		b.Reset()
+	})
+}
@@ -0,0 +1,116 @@
+package buffer
+
+import (
+	"io"
+	"os"
+)
+
+// maxReadvBuffers is the max number of slices passed to a single readv call (IOV_MAX)
+const maxReadvBuffers = 1024
+
+// ReadV reads data into buffers in order: a slice is filled fully before the next one is used.
+// So, a frame can be split into header and payload slices without an intermediate copy.
+// If the temp file is read without transformations (encryption, checksums, read-ahead, etc.),
+// its data is read with readv where it is supported.
+//
+// ReadV returns the total number of bytes read. It is less than the total size of buffers only if
+// the Buffer is drained. io.EOF is returned if no data was read because the Buffer is drained
+func (b *Buffer) ReadV(buffers [][]byte) (n int64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Don't modify the slice of the caller
+	buffers = append([][]byte(nil), buffers...)
+
+	for len(buffers) > 0 {
+		p := buffers[0]
+		if len(p) == 0 {
+			buffers = buffers[1:]
+			continue
+		}
+		if b.buff.Len() == 0 && b.canReadv() {
+			read, err := b.readv(buffers)
+			return n + read, err
+		}
+
+		read, err := b.read(p)
+		n += int64(read)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if read < len(p) {
+			// Reading is finished
+			return n, nil
+		}
+		buffers = buffers[1:]
+	}
+
+	if n == 0 && len(buffers) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// canReadv reports whether the rest of the data can be read from the temp file with readv.
+// It opens the file for reading
+func (b *Buffer) canReadv() bool {
+	if !readvSupported || b.readingFinished || !b.useFile {
+		return false
+	}
+	// Errors are returned by read
+	if b.finishWriting() != nil || b.prepareReadFile() != nil {
+		return false
+	}
+	_, ok := b.readFile.(*os.File)
+	return ok && b.readBuf == nil
+}
+
+// readv reads data from the temp file into buffers with readv. The memory must be drained
+func (b *Buffer) readv(buffers [][]byte) (n int64, err error) {
+	file := b.readFile.(*os.File)
+
+	for len(buffers) > 0 {
+		batch := buffers
+		if len(batch) > maxReadvBuffers {
+			batch = batch[:maxReadvBuffers]
+		}
+
+		read, err := readvFile(file, batch)
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		n += int64(read)
+
+		// Account the read data just like read does
+		short := false
+		for _, p := range batch {
+			k := len(p)
+			if k > read {
+				k = read
+				short = true
+			}
+			b.offset += int64(k)
+			b.hashRead(p[:k])
+			read -= k
+		}
+		if err == io.EOF || b.offset >= b.size {
+			return n, b.finishReading()
+		}
+		if short {
+			// The file layer can return less than requested. The rest is read by the next call
+			return n, nil
+		}
+		buffers = buffers[len(batch):]
+	}
+
+	if b.offset >= b.size {
+		return n, b.finishReading()
+	}
+	if b.autoCompactionSize > 0 && n > 0 {
+		b.autoCompact()
+	}
+	return n, nil
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package buffer
+
+import (
+	"os"
+)
+
+const readvSupported = false
+
+func readvFile(file *os.File, buffers [][]byte) (int, error) {
+	panic("readv isn't supported")
+}
@@ -0,0 +1,70 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ReadV(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(10000))
+
+		b := NewBufferWithMaxMemorySize(100)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+		digest := sha256.Sum256(slice)
+		b.EnableDigestVerification(sha256.New(), digest[:])
+		writeByChunks(require, b, slice, 1000)
+
+		var (
+			header  = make([]byte, 10)
+			payload = make([]byte, 5000)
+			empty   []byte
+			rest    = make([]byte, 6000)
+		)
+		buffers := [][]byte{header, empty, payload, rest}
+		n, err := b.ReadV(buffers)
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+		require.Equal(slice[:10], header)
+		require.Equal(slice[10:5010], payload)
+		require.Equal(slice[5010:], rest[:len(slice)-5010])
+		require.Len(buffers[3], 6000, "slices of the caller must not be modified")
+
+		n, err = b.ReadV([][]byte{header})
+		require.Equal(io.EOF, err)
+		require.Equal(int64(0), n)
+
+		b.Reset()
+	}
+}
+
+func TestBuffer_ReadV_Partial(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(10000))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 1000)
+
+	buffers := make([][]byte, 2000)
+	for i := range buffers {
+		buffers[i] = make([]byte, 2)
+	}
+	n, err := b.ReadV(buffers)
+	require.Nil(err)
+	require.Equal(int64(4000), n)
+	for i, p := range buffers {
+		require.Equal(slice[2*i:2*i+2], p)
+	}
+
+	res := readByChunks(require, b, 1000)
+	require.Equal(slice[4000:], res)
+}
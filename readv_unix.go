@@ -0,0 +1,56 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package buffer
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const readvSupported = true
+
+// readvFile reads data from file into buffers with a single readv call. Empty buffers are skipped
+func readvFile(file *os.File, buffers [][]byte) (int, error) {
+	iovecs := make([]syscall.Iovec, 0, len(buffers))
+	for _, p := range buffers {
+		if len(p) == 0 {
+			continue
+		}
+		iov := syscall.Iovec{Base: &p[0]}
+		iov.SetLen(len(p))
+		iovecs = append(iovecs, iov)
+	}
+	if len(iovecs) == 0 {
+		return 0, nil
+	}
+
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		n     uintptr
+		errno syscall.Errno
+	)
+	err = conn.Read(func(fd uintptr) bool {
+		for {
+			n, _, errno = syscall.Syscall(syscall.SYS_READV, fd, uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)))
+			if errno != syscall.EINTR {
+				return true
+			}
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, &os.PathError{Op: "readv", Path: file.Name(), Err: errno}
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return int(n), nil
+}
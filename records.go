@@ -0,0 +1,38 @@
+package buffer
+
+import (
+	"bytes"
+)
+
+// EnableRecordCounting makes the Buffer count occurrences of delim in written data. It allows
+// to know how many records (lines of CSV or NDJSON, for example) were written without a second
+// pass over the data. The count is reset on Reset(). It must be called before the first Write
+func (b *Buffer) EnableRecordCounting(delim byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.countRecords = true
+	b.recordDelim = delim
+}
+
+// RecordCount returns the number of delimiters written into the Buffer. It returns 0 if counting
+// is disabled. A last record without a trailing delimiter isn't counted
+func (b *Buffer) RecordCount() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.records
+}
+
+// LineCount returns the number of lines written into the Buffer. It is the same as RecordCount
+// for Buffers that count '\n'
+func (b *Buffer) LineCount() int64 {
+	return b.RecordCount()
+}
+
+// countRecordsIn counts delimiters in written data
+func (b *Buffer) countRecordsIn(data []byte) {
+	if b.countRecords {
+		b.records += int64(bytes.Count(data, []byte{b.recordDelim}))
+	}
+}
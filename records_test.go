@@ -0,0 +1,41 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableRecordCounting(t *testing.T) {
+	require := require.New(t)
+
+	record := []byte(`{"key": "` + generateRandomString(20) + `"}` + "\n")
+	slice := bytes.Repeat(record, 50)
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	b.EnableRecordCounting('\n')
+
+	writeByChunks(require, b, slice, 7)
+	require.Equal(int64(50), b.RecordCount())
+	require.Equal(int64(50), b.LineCount())
+
+	// Data moved from another Buffer is counted
+	src := NewBufferWithMaxMemorySize(100)
+	defer src.Reset()
+	writeByChunks(require, src, slice, 7)
+
+	b.Reset()
+	require.Equal(int64(0), b.RecordCount())
+
+	_, err := b.ReadFrom(src)
+	require.Nil(err)
+	require.Equal(int64(50), b.RecordCount())
+
+	// Counting is disabled
+	b = NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 7)
+	require.Equal(int64(0), b.RecordCount())
+}
@@ -0,0 +1,20 @@
+//go:build linux && (amd64 || arm64 || riscv64 || loong64 || 386 || arm || s390x)
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+const ficlone = 0x40049409 // FICLONE
+
+// reflinkFile makes dst share the data blocks of src. It works only on filesystems with reflink
+// support (XFS, btrfs, etc.)
+func reflinkFile(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64 || riscv64 || loong64 || 386 || arm || s390x)
+
+package buffer
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func reflinkFile(dst, src *os.File) error {
+	return errors.New("reflink isn't supported")
+}
@@ -0,0 +1,114 @@
+package buffer
+
+import (
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRetryInitialBackoff is used when RetryPolicy.InitialBackoff is 0
+	DefaultRetryInitialBackoff = 10 * time.Millisecond
+	// DefaultRetryMaxBackoff is used when RetryPolicy.MaxBackoff is 0
+	DefaultRetryMaxBackoff = time.Second
+)
+
+// RetryPolicy defines how the Buffer retries creation of a temp file and writes into it
+// after transient errors. Delays between retries grow exponentially from InitialBackoff up to MaxBackoff
+type RetryPolicy struct {
+	// MaxRetries is the max number of retries of a single operation. Retries are disabled if it is 0
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff is the max delay between retries
+	MaxBackoff time.Duration
+	// IsTransient reports whether an operation that failed with err can be retried.
+	// IsTransientError is used if it is nil
+	IsTransient func(err error) bool
+}
+
+// SetRetryPolicy sets the retry policy for creation of a temp file and writes into it
+func (b *Buffer) SetRetryPolicy(policy RetryPolicy) {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if policy.IsTransient == nil {
+		policy.IsTransient = IsTransientError
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.retryPolicy = policy
+}
+
+// IsTransientError reports whether err is caused by a transient system error: EINTR, EAGAIN,
+// EBUSY, ETIMEDOUT or ESTALE (common for network file systems)
+func IsTransientError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	switch errno {
+	case syscall.EINTR, syscall.EAGAIN, syscall.EBUSY, syscall.ETIMEDOUT, syscall.ESTALE:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the retry with index i (starting from 0)
+func (p RetryPolicy) backoff(i int) time.Duration {
+	d := p.InitialBackoff
+	for ; i > 0 && d < p.MaxBackoff; i-- {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// retry calls f until it succeeds, fails with a permanent error or the retries are exhausted
+func (p RetryPolicy) retry(f func() error) error {
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= p.MaxRetries || !p.IsTransient(err) {
+			return err
+		}
+		time.Sleep(p.backoff(i))
+	}
+}
+
+// retryWriter retries writes into the underlying writer according to the retry policy.
+// Data written before an error isn't written again
+type retryWriter struct {
+	w      io.WriteCloser
+	policy RetryPolicy
+}
+
+func newRetryWriter(w io.WriteCloser, policy RetryPolicy) *retryWriter {
+	return &retryWriter{
+		w:      w,
+		policy: policy,
+	}
+}
+
+func (rw *retryWriter) Write(p []byte) (n int, err error) {
+	err = rw.policy.retry(func() error {
+		written, err := rw.w.Write(p[n:])
+		n += written
+		return err
+	})
+	return n, err
+}
+
+func (rw *retryWriter) Close() error {
+	return rw.w.Close()
+}
@@ -0,0 +1,111 @@
+package buffer
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyWriter writes at most half of passed data and fails with errs one by one
+type flakyWriter struct {
+	bytes.Buffer
+	errs []error
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if len(w.errs) == 0 {
+		return w.Buffer.Write(p)
+	}
+
+	err := w.errs[0]
+	w.errs = w.errs[1:]
+
+	n, _ := w.Buffer.Write(p[:len(p)/2])
+	return n, err
+}
+
+func (w *flakyWriter) Close() error {
+	return nil
+}
+
+func TestRetryWriter(t *testing.T) {
+	policy := RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		IsTransient:    IsTransientError,
+	}
+
+	t.Run("Transient errors", func(t *testing.T) {
+		require := require.New(t)
+
+		w := &flakyWriter{
+			errs: []error{
+				syscall.EAGAIN,
+				&os.PathError{Op: "write", Path: "file", Err: syscall.EINTR},
+				errors.Wrap(syscall.ETIMEDOUT, "wrapped"),
+			},
+		}
+		data := []byte(generateRandomString(1000))
+
+		n, err := newRetryWriter(w, policy).Write(data)
+		require.Nil(err)
+		require.Equal(len(data), n)
+		require.Equal(data, w.Bytes())
+	})
+
+	t.Run("Too many errors", func(t *testing.T) {
+		require := require.New(t)
+
+		w := &flakyWriter{
+			errs: []error{syscall.EAGAIN, syscall.EAGAIN, syscall.EAGAIN, syscall.EAGAIN},
+		}
+		_, err := newRetryWriter(w, policy).Write([]byte("hello"))
+		require.Equal(syscall.EAGAIN, err)
+	})
+
+	t.Run("Permanent error", func(t *testing.T) {
+		require := require.New(t)
+
+		w := &flakyWriter{
+			errs: []error{syscall.ENOSPC, syscall.EAGAIN},
+		}
+		_, err := newRetryWriter(w, policy).Write([]byte("hello"))
+		require.Equal(syscall.ENOSPC, err)
+		require.Len(w.errs, 1)
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	require := require.New(t)
+
+	policy := RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+	require.Equal(10*time.Millisecond, policy.backoff(0))
+	require.Equal(20*time.Millisecond, policy.backoff(1))
+	require.Equal(40*time.Millisecond, policy.backoff(2))
+	require.Equal(50*time.Millisecond, policy.backoff(3))
+	require.Equal(50*time.Millisecond, policy.backoff(100))
+}
+
+func TestBuffer_RetryPolicy(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	b.SetRetryPolicy(RetryPolicy{MaxRetries: 3})
+
+	slice := []byte(generateRandomString(1 << 16))
+	writeByChunks(require, b, slice, 1024)
+
+	res := readByChunks(require, b, 1024)
+	require.Equal(slice, res, "wrong content was read")
+}
@@ -0,0 +1,83 @@
+package buffer
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+)
+
+// EnableSensitiveMode is a single switch for regulated data. The memory of the Buffer is allocated
+// outside of the Go heap and excluded from core dumps (MADV_DONTDUMP, Linux only), fmt prints
+// the Buffer without its contents, and Reset wipes the memory with zeros. Encryption of the temp file
+// must be enabled separately.
+//
+// Data stored in memory after a failure of the temp file (see EnableMemoryFallback) isn't covered.
+// EnableSensitiveMode must be called before the first Write
+func (b *Buffer) EnableSensitiveMode() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sensitive = true
+}
+
+// sensitiveMemory is a memory region allocated with allocSensitiveMemory. The region is released
+// by a finalizer if the Buffer isn't reset
+type sensitiveMemory struct {
+	data []byte
+}
+
+func newSensitiveMemory(size int) *sensitiveMemory {
+	m := &sensitiveMemory{
+		data: allocSensitiveMemory(size),
+	}
+	runtime.SetFinalizer(m, (*sensitiveMemory).release)
+	return m
+}
+
+// release wipes and releases the region
+func (m *sensitiveMemory) release() {
+	runtime.SetFinalizer(m, nil)
+
+	wipe(m.data)
+	freeSensitiveMemory(m.data)
+	m.data = nil
+}
+
+// growSensitiveMemory allocates memory for all data that can be stored in memory at once:
+// bytes.Buffer must not reallocate it on the heap
+func (b *Buffer) growSensitiveMemory() {
+	if b.maxInMemorySize <= 0 {
+		return
+	}
+
+	b.sensitiveMemory = newSensitiveMemory(b.maxInMemorySize)
+	b.buff = *bytes.NewBuffer(b.sensitiveMemory.data[:0])
+}
+
+// wipeMemory wipes the memory of a sensitive Buffer. It must be called after the memory
+// isn't used anymore
+func (b *Buffer) wipeMemory() {
+	if b.sensitiveMemory == nil {
+		return
+	}
+
+	b.buff = bytes.Buffer{}
+	b.sensitiveMemory.release()
+	b.sensitiveMemory = nil
+}
+
+// Format implements fmt.Formatter. Contents of sensitive Buffers aren't printed
+func (b *Buffer) Format(f fmt.State, verb rune) {
+	b.mu.Lock()
+	sensitive := b.sensitive
+	b.mu.Unlock()
+
+	if sensitive {
+		fmt.Fprint(f, "buffer.Buffer{<sensitive>}")
+		return
+	}
+
+	// Use the default format
+	type plainBuffer Buffer
+	fmt.Fprintf(f, fmt.FormatString(f, verb), (*plainBuffer)(b))
+}
@@ -0,0 +1,24 @@
+package buffer
+
+import (
+	"syscall"
+)
+
+// madvDontDump is MADV_DONTDUMP. It isn't defined in package syscall for all architectures
+const madvDontDump = 0x10
+
+// allocSensitiveMemory maps an anonymous memory region excluded from core dumps. It falls back
+// to the heap if the region can't be mapped
+func allocSensitiveMemory(size int) []byte {
+	data, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size)
+	}
+	// Core dumps are excluded on a best-effort basis
+	syscall.Madvise(data, madvDontDump)
+	return data
+}
+
+func freeSensitiveMemory(data []byte) {
+	syscall.Munmap(data)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package buffer
+
+func allocSensitiveMemory(size int) []byte {
+	return make([]byte, size)
+}
+
+func freeSensitiveMemory([]byte) {}
@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableSensitiveMode(t *testing.T) {
+	require := require.New(t)
+
+	const secret = "secret-data-1234567890"
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	b.EnableSensitiveMode()
+
+	for i := 0; i < 2; i++ {
+		writeByChunks(require, b, []byte(secret), 3)
+
+		// Memory must not be reallocated on the heap
+		require.NotNil(b.sensitiveMemory)
+		require.Equal(&b.sensitiveMemory.data[0], &b.buff.Bytes()[0])
+
+		for _, format := range []string{"%v", "%+v", "%#v", "%s", "%x"} {
+			res := fmt.Sprintf(format, b)
+			require.NotContains(res, secret)
+			require.NotContains(res, fmt.Sprintf("%x", secret))
+		}
+
+		require.Equal([]byte(secret), readByChunks(require, b, 5))
+
+		// Reset wipes and releases the memory. The Buffer can be reused
+		b.Reset()
+		require.Nil(b.sensitiveMemory)
+	}
+
+	// Other Buffers are printed as usual
+	b = NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	_, err := b.WriteString(secret)
+	require.Nil(err)
+	require.True(strings.HasPrefix(fmt.Sprintf("%v", b), "&{"))
+	require.Contains(fmt.Sprintf("%s", b), secret)
+}
@@ -0,0 +1,118 @@
+package buffer
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// sparseBlockSize is a size of blocks of zero bytes that aren't written into a sparse temp file
+const sparseBlockSize = 4 << 10 // 4 KB
+
+var zeroBlock [sparseBlockSize]byte
+
+// EnableSparseFiles makes the Buffer detect runs of zero bytes (common for preallocated images
+// and archives) and skip them instead of writing. It produces sparse temp files and saves disk space
+// and IO. Only aligned blocks of 4 KB are skipped.
+//
+// It has no effect if encryption or writing through memory mappings is enabled.
+// It must be called before the first Write
+func (b *Buffer) EnableSparseFiles() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sparseFiles = true
+}
+
+// sparseWriter writes data into a file skipping aligned blocks of zero bytes. Zero bytes at the
+// beginning of a block are deferred till the block is complete or a non-zero byte is written
+type sparseWriter struct {
+	file *swappableFile
+	// size is the size of written data including skipped blocks
+	size int64
+	// fileSize is the offset of the end of the last written data
+	fileSize int64
+	// zeros is the number of deferred zero bytes at the beginning of the current block
+	zeros int
+}
+
+func newSparseWriter(file *swappableFile) *sparseWriter {
+	return &sparseWriter{
+		file: file,
+	}
+}
+
+func (sw *sparseWriter) Write(p []byte) (n int, err error) {
+	var (
+		startSize = sw.size
+		// runStart is the start of data that must be written
+		runStart = 0
+	)
+	for n < len(p) {
+		blockOff := int(sw.size % sparseBlockSize)
+		seg := p[n:]
+		if len(seg) > sparseBlockSize-blockOff {
+			seg = seg[:sparseBlockSize-blockOff]
+		}
+
+		if sw.zeros == blockOff && bytes.Equal(seg, zeroBlock[:len(seg)]) {
+			// The block contains only zeros so far
+			err = sw.write(p[runStart:n], startSize+int64(runStart))
+			if err != nil {
+				return runStart, err
+			}
+			runStart = n + len(seg)
+
+			sw.zeros += len(seg)
+			if sw.zeros == sparseBlockSize {
+				// Skip the block
+				sw.zeros = 0
+			}
+		} else if sw.zeros > 0 {
+			// Write the deferred zeros
+			err = sw.write(zeroBlock[:sw.zeros], sw.size-int64(sw.zeros))
+			if err != nil {
+				return n, err
+			}
+			sw.zeros = 0
+		}
+
+		sw.size += int64(len(seg))
+		n += len(seg)
+	}
+
+	err = sw.write(p[runStart:], startSize+int64(runStart))
+	if err != nil {
+		return runStart, err
+	}
+
+	return n, nil
+}
+
+func (sw *sparseWriter) write(p []byte, off int64) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	n, err := sw.file.WriteAt(p, off)
+	if end := off + int64(n); end > sw.fileSize {
+		sw.fileSize = end
+	}
+	return err
+}
+
+// Close extends the file if it ends with skipped blocks and closes it
+func (sw *sparseWriter) Close() error {
+	var err error
+	if sw.fileSize < sw.size {
+		err = sw.file.Truncate(sw.size)
+		if err != nil {
+			err = errors.Wrapf(err, "can't extend file '%s'", sw.file.Name())
+		}
+	}
+
+	if closeErr := sw.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package buffer
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SparseFiles_DiskUsage(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(0)
+	defer b.Reset()
+
+	b.EnableSparseFiles()
+
+	slice := make([]byte, 16<<20)
+	copy(slice, "hello")
+	copy(slice[len(slice)-5:], "world")
+	writeByChunks(require, b, slice, 1000)
+	require.Nil(b.finishWriting())
+
+	stats, err := os.Stat(b.filename)
+	require.Nil(err)
+	require.Equal(int64(len(slice)), stats.Size())
+
+	// Blocks are 512 bytes
+	usage := stats.Sys().(*syscall.Stat_t).Blocks * 512
+	if usage >= int64(len(slice)) {
+		t.Skip("file system doesn't support sparse files")
+	}
+	require.True(usage < 1<<20, "temp file must be sparse, disk usage: %d", usage)
+
+	res := readByChunks(require, b, 4096)
+	require.Equal(slice, res, "wrong content was read")
+}
@@ -0,0 +1,98 @@
+package buffer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SparseFiles(t *testing.T) {
+	// generateSparseData generates data with runs of zero bytes
+	generateSparseData := func(size int) []byte {
+		data := make([]byte, 0, size)
+		for len(data) < size {
+			run := rand.Intn(1<<14) + 1
+			if run > size-len(data) {
+				run = size - len(data)
+			}
+			if rand.Intn(2) == 0 {
+				data = append(data, make([]byte, run)...)
+			} else {
+				data = append(data, generateRandomString(run)...)
+			}
+		}
+		return data
+	}
+
+	tests := []struct {
+		desc      string
+		encrypt   bool
+		checksums bool
+	}{
+		{desc: "Plain"},
+		{desc: "With encryption", encrypt: true},
+		{desc: "With checksums", checksums: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				t.Run("", func(t *testing.T) {
+					t.Parallel()
+
+					require := require.New(t)
+
+					var (
+						sliceSize      = rand.Intn(1<<18) + 1
+						bufferSize     = rand.Intn(sliceSize)
+						writeChunkSize = rand.Intn(1<<14) + 1
+						readChunkSize  = rand.Intn(1<<12) + 1
+					)
+
+					defer func() {
+						if t.Failed() {
+							t.Logf("sliceSize: %d; bufferSize: %d; writeChunkSize: %d; readChunkSize: %d\n",
+								sliceSize, bufferSize, writeChunkSize, readChunkSize)
+						}
+					}()
+
+					slice := generateSparseData(sliceSize)
+
+					b := NewBufferWithMaxMemorySize(bufferSize)
+					defer b.Reset()
+
+					b.EnableSparseFiles()
+					if tt.encrypt {
+						require.Nil(b.EnableEncryption())
+					}
+					if tt.checksums {
+						b.EnableChecksums()
+					}
+
+					writeByChunks(require, b, slice, writeChunkSize)
+
+					res := readByChunks(require, b, readChunkSize)
+					require.Equal(slice, res, "wrong content was read")
+				})
+			}
+		})
+	}
+
+	t.Run("Trailing zeros", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		b.EnableSparseFiles()
+
+		slice := append([]byte("hello"), make([]byte, 1<<16)...)
+		writeByChunks(require, b, slice, 1000)
+
+		res := readByChunks(require, b, 1024)
+		require.Equal(slice, res, "wrong content was read")
+	})
+}
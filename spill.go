@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"bytes"
+)
+
+// FlushToDisk moves the data stored in memory into the temp file and frees the memory. Further writes
+// go to the temp file. It reports whether the data was moved.
+//
+// Only Buffers that don't use a temp file yet can be flushed: data in memory precedes data in the file.
+// FlushToDisk does nothing after the first read or if the Buffer fell back to memory
+func (b *Buffer) FlushToDisk() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flushToDisk()
+}
+
+// flushToDisk is a non-locking version of FlushToDisk
+func (b *Buffer) flushToDisk() (bool, error) {
+	if b.useFile || b.writingFinished || b.diskFailed || b.buff.Len() == 0 {
+		return false, nil
+	}
+
+	_, err := b.writeToFile(b.buff.Bytes())
+	if err != nil {
+		// Remove the partially written file: the data is still stored in memory
+		if b.writeFile != nil {
+			b.writeFile.Close()
+			b.writeFile = nil
+			b.writeTempFile = nil
+		}
+		b.removeTempFile()
+		b.useFile = false
+		return false, err
+	}
+
+	// Wait for running ReadAt calls: they can read the memory
+	b.closeReadAtFile()
+	b.wipeMemory()
+	b.releaseArenaMemory()
+	b.buff = bytes.Buffer{}
+	b.inlineUsed = false
+	b.releaseMemory()
+
+	return true, nil
+}
@@ -0,0 +1,87 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_FlushToDisk(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+
+		first := []byte(generateRandomString(50))
+		writeByChunks(require, b, first, 7)
+
+		flushed, err := b.FlushToDisk()
+		require.Nil(err)
+		require.True(flushed)
+		require.Equal(0, b.buff.Cap(), "memory must be freed")
+		require.NotEmpty(b.filename)
+
+		second := []byte(generateRandomString(500))
+		_, err = b.Write(second)
+		require.Nil(err)
+
+		flushed, err = b.FlushToDisk()
+		require.Nil(err)
+		require.False(flushed, "Buffer uses the temp file already")
+
+		require.Equal(550, b.Len())
+		require.Equal(append(first, second...), readByChunks(require, b, 16))
+
+		flushed, err = b.FlushToDisk()
+		require.Nil(err)
+		require.False(flushed, "Buffer is read already")
+
+		b.Reset()
+	}
+}
+
+func TestMemoryBudget_EnableLRUSpilling(t *testing.T) {
+	require := require.New(t)
+
+	budget := NewMemoryBudget(100)
+	budget.EnableLRUSpilling()
+
+	var (
+		buffers []*Buffer
+		data    [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+		b.SetMemoryBudget(budget)
+
+		slice := []byte(generateRandomString(40))
+		writeByChunks(require, b, slice, 7)
+
+		buffers = append(buffers, b)
+		data = append(data, slice)
+	}
+
+	// The first Buffer is the least recently used one: it must be flushed for the third one
+	require.NotEmpty(buffers[0].filename)
+	require.Equal(0, buffers[0].buff.Len())
+	require.Empty(buffers[1].filename)
+	require.Empty(buffers[2].filename)
+	require.Equal(int64(80), budget.Used())
+
+	// Write into the second Buffer: the third one becomes the least recently used
+	more := []byte(generateRandomString(40))
+	_, err := buffers[1].Write(more)
+	require.Nil(err)
+	data[1] = append(data[1], more...)
+	require.Empty(buffers[1].filename)
+	require.NotEmpty(buffers[2].filename)
+	require.Equal(int64(80), budget.Used())
+
+	for i, b := range buffers {
+		require.Equal(data[i], readByChunks(require, b, 16))
+	}
+}
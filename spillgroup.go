@@ -0,0 +1,284 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSpillGroupExtentSize is used when NewSpillGroup is called with non-positive extent size
+const DefaultSpillGroupExtentSize = 64 << 10 // 64 KB
+
+// SpillGroup allows many Buffers to store their data in a single shared file instead of creating
+// a temp file per Buffer. The file is split into extents of a fixed size: every Buffer of the group
+// (see Buffer.SetSpillGroup) writes into its own extents, and extents of reset Buffers are reused.
+// So, workloads that create thousands of small Buffers don't create and remove files all the time.
+// The file is truncated when all extents are free. SpillGroup is thread-safe.
+//
+// Buffers of a group store data in the shared file as is: temp file features that work with the whole
+// file (mmap, mirrors, locks, unlinking, compaction, hole punching, migration) aren't used. Checksums,
+// encryption and async writes are supported
+type SpillGroup struct {
+	mu sync.Mutex
+
+	file       *os.File
+	extentSize int64
+	// size is the size of the file: all extents are below it
+	size int64
+	// free contains offsets of free extents
+	free []int64
+	// closed is true after Close
+	closed bool
+}
+
+// NewSpillGroup creates a shared file in dir. If dir is empty, the default directory for temp files is used
+func NewSpillGroup(dir string, extentSize int64) (*SpillGroup, error) {
+	if extentSize <= 0 {
+		extentSize = DefaultSpillGroupExtentSize
+	}
+
+	file, err := DefaultTempFileStrategy.Create(dir, "go-disk-buffer-group-*.tmp")
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create a shared file")
+	}
+
+	return &SpillGroup{
+		file:       file,
+		extentSize: extentSize,
+	}, nil
+}
+
+// Filename returns the path of the shared file
+func (g *SpillGroup) Filename() string {
+	return g.file.Name()
+}
+
+// Size returns the size of the shared file
+func (g *SpillGroup) Size() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.size
+}
+
+// Close closes and removes the shared file. All Buffers of the group must be reset before Close
+func (g *SpillGroup) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+
+	g.file.Close()
+	return DefaultTempFileStrategy.Remove(g.file.Name())
+}
+
+// allocate returns the offset of a free extent
+func (g *SpillGroup) allocate() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return 0, errors.New("spill group is closed")
+	}
+
+	if n := len(g.free); n > 0 {
+		off := g.free[n-1]
+		g.free = g.free[:n-1]
+		return off, nil
+	}
+
+	off := g.size
+	g.size += g.extentSize
+	return off, nil
+}
+
+// release returns extents to the group
+func (g *SpillGroup) release(extents []int64) {
+	if len(extents) == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return
+	}
+
+	g.free = append(g.free, extents...)
+	if int64(len(g.free))*g.extentSize == g.size {
+		// All extents are free
+		if g.file.Truncate(0) == nil {
+			g.free = g.free[:0]
+			g.size = 0
+		}
+		return
+	}
+	// Reuse extents at the beginning of the file first, so the tail of the file stays free
+	sort.Slice(g.free, func(i, j int) bool {
+		return g.free[i] > g.free[j]
+	})
+}
+
+// groupFile is data of a Buffer stored in extents of a SpillGroup
+type groupFile struct {
+	g       *SpillGroup
+	extents []int64
+	// size is the size of the data
+	size int64
+}
+
+// Write appends data to the extents. Extents are allocated on demand
+func (gf *groupFile) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		off := gf.size % gf.g.extentSize
+		if off == 0 && gf.size/gf.g.extentSize == int64(len(gf.extents)) {
+			extent, err := gf.g.allocate()
+			if err != nil {
+				return n, err
+			}
+			gf.extents = append(gf.extents, extent)
+		}
+
+		chunk := p
+		if left := gf.g.extentSize - off; int64(len(chunk)) > left {
+			chunk = chunk[:left]
+		}
+		written, err := gf.g.file.WriteAt(chunk, gf.extents[len(gf.extents)-1]+off)
+		n += written
+		gf.size += int64(written)
+		if err != nil {
+			return n, errors.Wrapf(err, "can't write into a shared file '%s'", gf.g.file.Name())
+		}
+		p = p[written:]
+	}
+	return n, nil
+}
+
+func (gf *groupFile) Close() error {
+	return nil
+}
+
+// ReadAt reads data from the extents. It is safe for concurrent use
+func (gf *groupFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for len(p) > 0 {
+		if off >= gf.size {
+			return n, io.EOF
+		}
+
+		i, extentOff := off/gf.g.extentSize, off%gf.g.extentSize
+		chunk := p
+		if left := gf.g.extentSize - extentOff; int64(len(chunk)) > left {
+			chunk = chunk[:left]
+		}
+		if left := gf.size - off; int64(len(chunk)) > left {
+			chunk = chunk[:left]
+		}
+
+		read, err := gf.g.file.ReadAt(chunk, gf.extents[i]+extentOff)
+		n += read
+		off += int64(read)
+		p = p[read:]
+		if err != nil && !(err == io.EOF && read == len(chunk)) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// release returns the extents to the group
+func (gf *groupFile) release() {
+	gf.g.release(gf.extents)
+	gf.extents = nil
+	gf.size = 0
+}
+
+// groupReader reads a groupFile. It implements readableFile
+type groupReader struct {
+	*groupFile
+	// off is the offset of sequential reads
+	off int64
+}
+
+func (r *groupReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *groupReader) Stat() (os.FileInfo, error) {
+	return groupFileInfo{name: r.g.file.Name(), size: r.size}, nil
+}
+
+func (r *groupReader) Close() error {
+	return nil
+}
+
+// groupFileInfo describes data of a Buffer in a shared file
+type groupFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi groupFileInfo) Name() string       { return fi.name }
+func (fi groupFileInfo) Size() int64        { return fi.size }
+func (fi groupFileInfo) Mode() os.FileMode  { return 0600 }
+func (fi groupFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi groupFileInfo) IsDir() bool        { return false }
+func (fi groupFileInfo) Sys() interface{}   { return nil }
+
+// SetSpillGroup makes the Buffer store data in the shared file of g instead of its own temp file.
+// It must be called before the first Write
+func (b *Buffer) SetSpillGroup(g *SpillGroup) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.spillGroup = g
+}
+
+// createGroupFile prepares extents of the spill group for writing
+func (b *Buffer) createGroupFile() (err error) {
+	gf := &groupFile{g: b.spillGroup}
+
+	var writeFile io.WriteCloser = gf
+	b.writeTempFile = nil
+	if b.checksumsEnabled {
+		b.checksums = &checksums{filename: gf.g.file.Name()}
+		writeFile = newChecksumWriter(writeFile, b.checksums)
+	}
+	b.sealedHeader = b.encrypt
+	if b.encrypt {
+		writeFile, err = b.newSealedWriter(writeFile)
+		if err != nil {
+			return errors.Wrap(err, "can't create an encryption stream")
+		}
+	}
+	if b.asyncWriteChunks > 0 {
+		writeFile = newAsyncWriter(writeFile, b.asyncWriteChunkSize, b.asyncWriteChunks)
+	}
+
+	b.writeFile = writeFile
+	b.groupFile = gf
+	b.filename = gf.g.file.Name()
+	// The shared file doesn't belong to the Buffer
+	b.keepFile = true
+	return nil
+}
+
+// releaseGroupFile returns the extents of the Buffer to the spill group
+func (b *Buffer) releaseGroupFile() {
+	if b.groupFile != nil {
+		b.groupFile.release()
+		b.groupFile = nil
+	}
+}
@@ -0,0 +1,84 @@
+package buffer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillGroup(t *testing.T) {
+	require := require.New(t)
+
+	const extentSize = 1000
+
+	g, err := NewSpillGroup("", extentSize)
+	require.Nil(err)
+	defer g.Close()
+
+	newBuffer := func(encrypt bool) *Buffer {
+		b := NewBufferWithMaxMemorySize(100)
+		b.SetSpillGroup(g)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+			b.EnableChecksums()
+		}
+		return b
+	}
+
+	// Interleaved writes of several Buffers
+	var (
+		buffers = []*Buffer{newBuffer(false), newBuffer(true), newBuffer(false)}
+		slices  = make([][]byte, len(buffers))
+	)
+	for i := 0; i < 10; i++ {
+		for j, b := range buffers {
+			data := []byte(generateRandomString(300 + j*100))
+			slices[j] = append(slices[j], data...)
+			_, err := b.Write(data)
+			require.Nil(err)
+		}
+	}
+	for _, b := range buffers {
+		require.Equal(g.Filename(), b.filename)
+	}
+	size := g.Size()
+	require.True(size > 0)
+	require.Equal(int64(0), size%extentSize)
+
+	// ReadAt
+	data := make([]byte, 1000)
+	_, err = buffers[0].ReadAt(data, 1500)
+	require.Nil(err)
+	require.Equal(slices[0][1500:2500], data)
+
+	// The extents of a drained Buffer are reused
+	res := readByChunks(require, buffers[0], 512)
+	require.Equal(slices[0], res)
+	_, err = os.Stat(g.Filename())
+	require.Nil(err, "the shared file must not be removed")
+
+	b := newBuffer(false)
+	slice := []byte(generateRandomString(2500))
+	_, err = b.Write(slice)
+	require.Nil(err)
+	require.Equal(size, g.Size(), "free extents must be reused")
+	buffers[0] = b
+	slices[0] = slice
+
+	for i, b := range buffers {
+		res := readByChunks(require, b, 700)
+		require.Equal(slices[i], res, "Buffer %d", i)
+		b.Reset()
+	}
+
+	// The file is truncated when all extents are free
+	require.Equal(int64(0), g.Size())
+	stats, err := os.Stat(g.Filename())
+	require.Nil(err)
+	require.Equal(int64(0), stats.Size())
+
+	require.Nil(g.Close())
+	_, err = os.Stat(g.Filename())
+	require.True(os.IsNotExist(err))
+}
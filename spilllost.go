@@ -0,0 +1,28 @@
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrSpillLost is returned when the temp file was removed or truncated outside of the Buffer
+// (by tmpwatch or systemd-tmpfiles, for example). The data stored in the file can't be read
+var ErrSpillLost = errors.New("temp file was lost")
+
+// SetSpillLostHandler sets a function that is called when the Buffer detects that its temp file
+// was lost. It is called once with the name of the file, for example, to re-fetch the data.
+// The handler must not call methods of the Buffer
+func (b *Buffer) SetSpillLostHandler(handler func(filename string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.spillLostHandler = handler
+}
+
+// spillLost calls the handler and returns ErrSpillLost. It is safe to call with readAtMu held only:
+// handler and filename must be captured under mu
+func (b *Buffer) spillLost(handler func(string), filename string, reason string) error {
+	if handler != nil && b.spillLostReported.CompareAndSwap(false, true) {
+		handler(filename)
+	}
+	return errors.Wrapf(ErrSpillLost, "temp file '%s' %s", filename, reason)
+}
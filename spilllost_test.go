@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SpillLost(t *testing.T) {
+	tests := []struct {
+		desc string
+		lose func(filename string) error
+	}{
+		{
+			desc: "removed",
+			lose: os.Remove,
+		},
+		{
+			desc: "truncated",
+			lose: func(filename string) error { return os.Truncate(filename, 100) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			slice := []byte(generateRandomString(1000))
+
+			b := NewBufferWithMaxMemorySize(10)
+			defer b.Reset()
+
+			var lost []string
+			b.SetSpillLostHandler(func(filename string) {
+				lost = append(lost, filename)
+			})
+
+			writeByChunks(require, b, slice, 7)
+			require.Nil(b.Flush())
+
+			filename := b.filename
+			require.Nil(tt.lose(filename))
+
+			res := make([]byte, 100)
+			_, err := b.ReadAt(res, 500)
+			require.True(errors.Is(err, ErrSpillLost), "ReadAt must return ErrSpillLost, got %v", err)
+
+			_, err = ioutil.ReadAll(b)
+			require.True(errors.Is(err, ErrSpillLost), "Read must return ErrSpillLost, got %v", err)
+
+			require.Equal([]string{filename}, lost, "handler must be called once")
+		})
+	}
+}
+
+func TestBuffer_SpillLostMemory(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	called := false
+	b.SetSpillLostHandler(func(string) { called = true })
+
+	slice := []byte(generateRandomString(50))
+	_, err := b.Write(slice)
+	require.Nil(err)
+
+	res, err := ioutil.ReadAll(b)
+	require.Nil(err)
+	require.Equal(slice, res)
+	require.False(called, "handler must not be called")
+}
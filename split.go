@@ -0,0 +1,40 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Split finishes writing and returns independent readers for byte ranges of the written data.
+// The ranges are separated by offsets: [0, offsets[0]), [offsets[0], offsets[1]), ..., [offsets[n-1], Len()).
+// Offsets must be in ascending order and must not exceed the size of the data.
+//
+// The readers are backed by ReadAt. So, they can be used from multiple goroutines in parallel (one reader per goroutine).
+// The Buffer must not be read with Read till the readers are used: the temp file is removed when the Buffer is drained
+func (b *Buffer) Split(offsets ...int64) ([]*io.SectionReader, error) {
+	b.mu.Lock()
+	err := b.finishWriting()
+	size := b.size
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make([]*io.SectionReader, 0, len(offsets)+1)
+
+	var start int64
+	for i := 0; i <= len(offsets); i++ {
+		off := size
+		if i < len(offsets) {
+			off = offsets[i]
+		}
+		if off < start || off > size {
+			return nil, errors.Errorf("invalid offset %d: offsets must be in ascending order in range [0, %d]", off, size)
+		}
+		readers = append(readers, io.NewSectionReader(b, start, off-start))
+		start = off
+	}
+
+	return readers, nil
+}
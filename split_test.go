@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Split(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(1000))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	writeByChunks(require, b, slice, 7)
+
+	offsets := []int64{0, 50, 100, 100, 600}
+	readers, err := b.Split(offsets...)
+	require.Nil(err)
+	require.Len(readers, len(offsets)+1)
+
+	bounds := append(append([]int64{0}, offsets...), int64(len(slice)))
+
+	var wg sync.WaitGroup
+	for i, r := range readers {
+		i, r := i, r
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res, err := ioutil.ReadAll(r)
+			assert.Nil(t, err)
+			assert.Equal(t, slice[bounds[i]:bounds[i+1]], res, "wrong content was read")
+		}()
+	}
+	wg.Wait()
+
+	// Invalid offsets
+	_, err = b.Split(100, 50)
+	require.NotNil(err)
+	_, err = b.Split(int64(len(slice) + 1))
+	require.NotNil(err)
+}
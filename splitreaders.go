@@ -0,0 +1,150 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// RecordReaders yields a reader for every record of a Buffer. Records are separated by a delimiter.
+// Records are streamed from memory or the temp file: they aren't loaded into memory as a whole
+type RecordReaders struct {
+	b     *Buffer
+	delim byte
+
+	current *recordReader
+}
+
+// SplitReaders returns RecordReaders that read records separated by delim (lines of NDJSON, for
+// example). It finishes writing on the first read. The Buffer must not be read in other ways till
+// the records are read
+func (b *Buffer) SplitReaders(delim byte) *RecordReaders {
+	return &RecordReaders{
+		b:     b,
+		delim: delim,
+	}
+}
+
+// Next returns a reader of the next record. The delimiter isn't included into the record. The rest
+// of the previous record is skipped. Next returns io.EOF when there are no records left
+func (rs *RecordReaders) Next() (io.Reader, error) {
+	if rs.current != nil && !rs.current.done {
+		// Skip the rest of the record
+		_, err := io.Copy(ioutil.Discard, rs.current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rs.b.mu.Lock()
+	err := rs.b.finishWriting()
+	left := rs.b.unreadLen()
+	rs.b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if left == 0 {
+		return nil, io.EOF
+	}
+
+	rs.current = &recordReader{rs: rs}
+	return rs.current, nil
+}
+
+// recordReader reads a single record
+type recordReader struct {
+	rs   *RecordReaders
+	done bool
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, found, err := r.rs.b.readRecord(p, r.rs.delim)
+	if found || err == io.EOF {
+		r.done = true
+	}
+	if err == nil && r.done && n == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// readRecord reads data into p till delim. The delimiter is consumed, but it isn't copied into p.
+// It reports whether the delimiter was found
+func (b *Buffer) readRecord(p []byte, delim byte) (n int, found bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.readingFinished {
+		return 0, false, io.EOF
+	}
+	err = b.finishWriting()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if b.buff.Len() != 0 {
+		var consumed int
+		n, consumed, found = copyRecord(p, b.buff.Bytes(), delim)
+		b.hashRead(b.buff.Next(consumed))
+		b.offset += int64(consumed)
+		return n, found, b.finishReadingIfDrained()
+	}
+
+	if !b.useFile {
+		err := b.finishReading()
+		if err != nil {
+			return 0, false, err
+		}
+		return 0, false, io.EOF
+	}
+
+	if b.readBuf == nil {
+		err := b.prepareReadFile()
+		if err != nil {
+			return 0, false, err
+		}
+		b.readBuf = bufio.NewReader(b.readFile)
+	}
+
+	// Fill the buffer of readBuf if it is empty
+	_, err = b.readBuf.Peek(1)
+	if err == io.EOF {
+		err := b.finishReading()
+		if err != nil {
+			return 0, false, err
+		}
+		return 0, false, io.EOF
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	buffered, _ := b.readBuf.Peek(b.readBuf.Buffered())
+	n, consumed, found := copyRecord(p, buffered, delim)
+	b.hashRead(buffered[:consumed])
+	b.offset += int64(consumed)
+	b.readBuf.Discard(consumed)
+
+	return n, found, b.finishReadingIfDrained()
+}
+
+// copyRecord copies data till delim into p. It returns the number of copied bytes, the number
+// of consumed bytes (including the delimiter) and reports whether the delimiter was found
+func copyRecord(p, data []byte, delim byte) (n, consumed int, found bool) {
+	if len(data) > len(p) {
+		data = data[:len(p)]
+	}
+	if i := bytes.IndexByte(data, delim); i >= 0 {
+		return copy(p, data[:i]), i + 1, true
+	}
+	n = copy(p, data)
+	return n, n, false
+}
@@ -0,0 +1,111 @@
+package buffer
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_SplitReaders(t *testing.T) {
+	records := []string{
+		generateRandomString(10),
+		"",
+		generateRandomString(20000),
+		generateRandomString(150),
+		generateRandomString(1),
+	}
+
+	tests := []struct {
+		desc            string
+		maxInMemorySize int
+		trailing        bool
+	}{
+		{desc: "memory", maxInMemorySize: 1 << 20},
+		{desc: "file", maxInMemorySize: 100},
+		{desc: "file with trailing delimiter", maxInMemorySize: 100, trailing: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			data := strings.Join(records, "\n")
+			if tt.trailing {
+				data += "\n"
+			}
+
+			b := NewBufferWithMaxMemorySize(tt.maxInMemorySize)
+			defer b.Reset()
+			writeByChunks(require, b, []byte(data), 77)
+
+			rs := b.SplitReaders('\n')
+			for _, record := range records {
+				r, err := rs.Next()
+				require.Nil(err)
+
+				res, err := readByChunksBenchmark(r, 999)
+				require.Nil(err)
+				require.Equal(record, string(res))
+			}
+			_, err := rs.Next()
+			require.Equal(io.EOF, err)
+			require.Equal(0, b.Len())
+			require.Empty(b.filename)
+		})
+	}
+
+	t.Run("skip records", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+		writeByChunks(require, b, []byte(strings.Join(records, "\n")), 77)
+
+		rs := b.SplitReaders('\n')
+		for i, record := range records {
+			r, err := rs.Next()
+			require.Nil(err)
+
+			if i%2 == 0 {
+				// The rest of the record is skipped by the next call of Next
+				res := make([]byte, 1)
+				n, _ := r.Read(res)
+				require.Equal(record[:n], string(res[:n]))
+				continue
+			}
+			res, err := ioutil.ReadAll(r)
+			require.Nil(err)
+			require.Equal(record, string(res))
+		}
+		_, err := rs.Next()
+		require.Equal(io.EOF, err)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+		_, err := b.WriteString(`{"id":1}` + "\n" + `{"id":2}` + "\n")
+		require.Nil(err)
+
+		var ids []int
+		rs := b.SplitReaders('\n')
+		for {
+			r, err := rs.Next()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(err)
+
+			var v struct{ ID int }
+			require.Nil(json.NewDecoder(r).Decode(&v))
+			ids = append(ids, v.ID)
+		}
+		require.Equal([]int{1, 2}, ids)
+	})
+}
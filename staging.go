@@ -0,0 +1,51 @@
+package buffer
+
+// DefaultWriteStagingSize is used when Buffer.EnableWriteStaging is called with non-positive size
+const DefaultWriteStagingSize = 4 << 10 // 4 KB
+
+// EnableWriteStaging makes the Buffer coalesce small writes (a few bytes each, from encoders, for example)
+// in a staging array of size bytes. The staged data is written into memory or into the temp file at once
+// when the array is full, so the per-call overhead of tiny writes is amortized. Writes that don't fit into
+// the array aren't staged.
+//
+// The staged data is written when writing is finished or by Flush. Errors of the staged writes are reported
+// by the write that flushes them. Hashes (see EnableHashing) and tees (see SetTee) receive the staged data
+// when it is flushed. It must be called before the first Write
+func (b *Buffer) EnableWriteStaging(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if size <= 0 {
+		size = DefaultWriteStagingSize
+	}
+	b.staging = make([]byte, 0, size)
+}
+
+// writeStaged writes data into the staging array. Large data is written as is after the staged data
+func (b *Buffer) writeStaged(data []byte) (int, error) {
+	if len(b.staging)+len(data) <= cap(b.staging) {
+		b.staging = append(b.staging, data...)
+		return len(data), nil
+	}
+
+	err := b.flushStaging()
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < cap(b.staging) {
+		b.staging = append(b.staging, data...)
+		return len(data), nil
+	}
+	return b.writeData(data)
+}
+
+// flushStaging writes the staged data. Data that wasn't written stays in the staging array
+func (b *Buffer) flushStaging() error {
+	if len(b.staging) == 0 {
+		return nil
+	}
+
+	n, err := b.writeData(b.staging)
+	b.staging = b.staging[:copy(b.staging, b.staging[n:])]
+	return err
+}
@@ -0,0 +1,64 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableWriteStaging(t *testing.T) {
+	for _, maxMemorySize := range []int{0, 100, 1 << 20} {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(maxMemorySize)
+		b.EnableWriteStaging(64)
+
+		var expected bytes.Buffer
+		for i := 0; i < 10000; i++ {
+			data := []byte(generateRandomString(i%5 + 1))
+			if i%1000 == 0 {
+				// Large writes aren't staged
+				data = []byte(generateRandomString(100))
+			}
+			expected.Write(data)
+
+			n, err := b.Write(data)
+			require.Nil(err)
+			require.Equal(len(data), n)
+		}
+		require.True(len(b.staging) > 0, "small writes must be staged")
+		require.Equal(expected.Len(), b.Len())
+
+		res := readByChunks(require, b, 1000)
+		require.Equal(expected.Bytes(), res)
+
+		b.Reset()
+	}
+}
+
+func TestBuffer_EnableWriteStaging_Flush(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	b.EnableWriteStaging(0)
+	b.EnableHashing(nil)
+
+	_, err := b.Write([]byte("data"))
+	require.Nil(err)
+	require.Equal(0, b.buff.Len())
+
+	require.Nil(b.Flush())
+	require.Equal(4, b.buff.Len())
+	require.Len(b.staging, 0)
+	require.NotNil(b.Sum())
+
+	// ReadAt sees staged data
+	_, err = b.Write([]byte("more"))
+	require.Nil(err)
+	data := make([]byte, 8)
+	_, err = b.ReadAt(data, 0)
+	require.Nil(err)
+	require.Equal("datamore", string(data))
+}
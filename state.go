@@ -0,0 +1,155 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Phase is a phase of the Buffer lifecycle
+type Phase string
+
+const (
+	// PhaseWriting means that data can be written into the Buffer
+	PhaseWriting Phase = "writing"
+	// PhaseReading means that writing is finished and the Buffer is being read
+	PhaseReading Phase = "reading"
+	// PhaseDrained means that all data was read
+	PhaseDrained Phase = "drained"
+)
+
+// State describes the internal state of a Buffer. It is intended for diagnostics:
+// error reports, logs and support tickets
+type State struct {
+	Phase Phase
+
+	// Size is the total number of written bytes
+	Size int64
+	// Offset is the number of read bytes
+	Offset int64
+	// Len is the number of bytes of the unread portion of the Buffer
+	Len int64
+
+	// MaxMemorySize is the max number of bytes stored in memory
+	MaxMemorySize int
+	// MemorySize is the number of unread bytes stored in memory
+	MemorySize int
+	// MemoryCapacity is the capacity of the internal buffer
+	MemoryCapacity int
+
+	// UseFile is true when the Buffer stores data in a temp file
+	UseFile bool
+	// Filename is the path of the temp file
+	Filename string
+	// MirrorFilename is the path of the mirror of the temp file
+	MirrorFilename string
+	// FileSize is the number of bytes written into the temp file
+	FileSize int64
+	// KeepFile is true when the temp file doesn't belong to the Buffer
+	KeepFile bool
+
+	// WriteFileOpen, ReadFileOpen and ReadAtFileOpen report whether the temp file is opened
+	// for writing, sequential reading and ReadAt
+	WriteFileOpen  bool
+	ReadFileOpen   bool
+	ReadAtFileOpen bool
+
+	Encrypted        bool
+	CustomAEAD       bool
+	ChecksumsEnabled bool
+	HashingEnabled   bool
+	MmapEnabled      bool
+	// DiskFailed is true when the Buffer fell back to memory
+	DiskFailed bool
+
+	// WriteErr is an error that occurred during finishing writing
+	WriteErr string
+}
+
+// DumpState returns the internal state of the Buffer
+func (b *Buffer) DumpState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	phase := PhaseWriting
+	switch {
+	case b.readingFinished:
+		phase = PhaseDrained
+	case b.writingFinished:
+		phase = PhaseReading
+	}
+
+	var writeErr string
+	if b.writeErr != nil {
+		writeErr = b.writeErr.Error()
+	}
+
+	return State{
+		Phase:            phase,
+		Size:             b.size,
+		Offset:           b.offset,
+		Len:              b.size - b.offset,
+		MaxMemorySize:    b.maxInMemorySize,
+		MemorySize:       b.buff.Len(),
+		MemoryCapacity:   b.buff.Cap(),
+		UseFile:          b.useFile,
+		Filename:         b.filename,
+		MirrorFilename:   b.mirrorFilename,
+		FileSize:         b.fileSize,
+		KeepFile:         b.keepFile,
+		WriteFileOpen:    b.writeFile != nil,
+		ReadFileOpen:     b.readFile != nil,
+		ReadAtFileOpen:   b.readAtFile != nil,
+		Encrypted:        b.encrypt,
+		CustomAEAD:       b.aead != nil,
+		ChecksumsEnabled: b.checksumsEnabled,
+		HashingEnabled:   b.hash != nil,
+		MmapEnabled:      b.useMmap(),
+		DiskFailed:       b.diskFailed,
+		WriteErr:         writeErr,
+	}
+}
+
+// DebugString returns the internal state of the Buffer as a single line
+func (b *Buffer) DebugString() string {
+	return b.DumpState().String()
+}
+
+// String returns the state as a single line. Empty fields are omitted
+func (s State) String() string {
+	var fields []string
+	add := func(name string, value interface{}) {
+		fields = append(fields, fmt.Sprintf("%s=%v", name, value))
+	}
+	addFlag := func(name string, value bool) {
+		if value {
+			fields = append(fields, name)
+		}
+	}
+
+	add("phase", s.Phase)
+	add("size", s.Size)
+	add("offset", s.Offset)
+	add("len", s.Len)
+	add("memory", fmt.Sprintf("%d/%d (cap %d)", s.MemorySize, s.MaxMemorySize, s.MemoryCapacity))
+	if s.UseFile {
+		add("file", fmt.Sprintf("%q (%d bytes)", s.Filename, s.FileSize))
+	}
+	if s.MirrorFilename != "" {
+		add("mirror", fmt.Sprintf("%q", s.MirrorFilename))
+	}
+	addFlag("keep-file", s.KeepFile)
+	addFlag("write-fd", s.WriteFileOpen)
+	addFlag("read-fd", s.ReadFileOpen)
+	addFlag("read-at-fd", s.ReadAtFileOpen)
+	addFlag("encrypted", s.Encrypted)
+	addFlag("custom-aead", s.CustomAEAD)
+	addFlag("checksums", s.ChecksumsEnabled)
+	addFlag("hashing", s.HashingEnabled)
+	addFlag("mmap", s.MmapEnabled)
+	addFlag("disk-failed", s.DiskFailed)
+	if s.WriteErr != "" {
+		add("write-err", fmt.Sprintf("%q", s.WriteErr))
+	}
+
+	return strings.Join(fields, " ")
+}
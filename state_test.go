@@ -0,0 +1,46 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_DumpState(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	require.Nil(b.EnableEncryption())
+
+	_, err := b.Write([]byte(generateRandomString(25)))
+	require.Nil(err)
+
+	state := b.DumpState()
+	require.Equal(PhaseWriting, state.Phase)
+	require.Equal(int64(25), state.Size)
+	require.Equal(10, state.MemorySize)
+	require.True(state.UseFile)
+	require.Equal(b.filename, state.Filename)
+	require.Equal(int64(15), state.FileSize)
+	require.True(state.WriteFileOpen)
+	require.True(state.Encrypted)
+
+	_, err = b.Read(make([]byte, 12))
+	require.Nil(err)
+
+	state = b.DumpState()
+	require.Equal(PhaseReading, state.Phase)
+	require.Equal(int64(12), state.Offset)
+	require.Equal(int64(13), state.Len)
+	require.False(state.WriteFileOpen)
+	require.True(state.ReadFileOpen)
+
+	require.Contains(b.DebugString(), "phase=reading")
+	require.Contains(b.DebugString(), "encrypted")
+
+	n, _ := b.Read(make([]byte, 100))
+	require.Equal(13, n)
+	require.Equal(PhaseDrained, b.DumpState().Phase)
+}
@@ -0,0 +1,52 @@
+package buffer
+
+import (
+	"sync"
+)
+
+var (
+	physicalMemoryOnce sync.Once
+	physicalMemorySize int64
+	physicalMemoryErr  error
+)
+
+// PhysicalMemory returns the amount of memory available to the process: the size of physical
+// memory or the memory limit of the container (cgroup) if it is less. The value is detected once
+func PhysicalMemory() (int64, error) {
+	physicalMemoryOnce.Do(func() {
+		physicalMemorySize, physicalMemoryErr = detectPhysicalMemory()
+	})
+	return physicalMemorySize, physicalMemoryErr
+}
+
+// MemoryFraction returns fraction of PhysicalMemory clamped to [floor, ceiling]. ceiling is ignored
+// if it is 0. floor is returned if the memory can't be detected. For example, MemoryFraction(0.01, 1<<20, 64<<20)
+// gives 5 MB on a 512 MB container and 64 MB on a 256 GB host
+func MemoryFraction(fraction float64, floor, ceiling int) int {
+	total, err := PhysicalMemory()
+	if err != nil || fraction <= 0 {
+		return floor
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	size := float64(total) * fraction
+	switch {
+	case size < float64(floor):
+		return floor
+	case ceiling > 0 && size > float64(ceiling):
+		return ceiling
+	case size > float64(maxInt):
+		return maxInt
+	}
+	return int(size)
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// NewBufferWithMemoryFraction creates a new Buffer with maxInMemorySize equal to fraction of physical
+// memory clamped to [floor, ceiling]. See MemoryFraction
+func NewBufferWithMemoryFraction(fraction float64, floor, ceiling int) *Buffer {
+	return NewBufferWithMaxMemorySize(MemoryFraction(fraction, floor, ceiling))
+}
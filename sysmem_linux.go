@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// cgroupMemoryLimitFiles contain the memory limit of the container for cgroup v2 and v1
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+func detectPhysicalMemory() (int64, error) {
+	var info syscall.Sysinfo_t
+	err := syscall.Sysinfo(&info)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't get system info")
+	}
+	total := int64(info.Totalram) * int64(info.Unit)
+
+	for _, path := range cgroupMemoryLimitFiles {
+		limit, ok := readCgroupMemoryLimit(path)
+		if ok && limit < total {
+			total = limit
+		}
+	}
+	return total, nil
+}
+
+// readCgroupMemoryLimit reads a memory limit. It returns false if the file doesn't exist or there's no limit
+func readCgroupMemoryLimit(path string) (int64, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	// cgroup v2 uses "max" for no limit
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+	return limit, true
+}
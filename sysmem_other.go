@@ -0,0 +1,11 @@
+//go:build !linux
+
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+func detectPhysicalMemory() (int64, error) {
+	return 0, errors.New("detection of physical memory isn't supported on this platform")
+}
@@ -0,0 +1,39 @@
+package buffer
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryFraction(t *testing.T) {
+	require := require.New(t)
+
+	total, err := PhysicalMemory()
+	if runtime.GOOS != "linux" {
+		require.NotNil(err)
+		require.Equal(1<<20, MemoryFraction(0.5, 1<<20, 0), "floor must be used")
+		return
+	}
+	require.Nil(err)
+	require.True(total > 0)
+
+	// Sizes are clamped to maxInt on 32-bit platforms
+	clamp := func(size int64) int {
+		if size > int64(maxInt) {
+			return maxInt
+		}
+		return int(size)
+	}
+
+	require.Equal(clamp(total/2), MemoryFraction(0.5, 0, 0))
+	require.Equal(clamp(total), MemoryFraction(2, 0, 0), "fraction must be clamped")
+	require.Equal(2<<20, MemoryFraction(0.5, 1<<20, 2<<20), "ceiling must be used")
+	require.Equal(clamp(total), MemoryFraction(0.000001, clamp(total), 0), "floor must be used")
+	require.Equal(100, MemoryFraction(0, 100, 200))
+
+	b := NewBufferWithMemoryFraction(0.5, 0, 1<<20)
+	defer b.Reset()
+	require.Equal(1<<20, b.maxInMemorySize)
+}
@@ -0,0 +1,16 @@
+package buffer
+
+import (
+	"io"
+)
+
+// SetTee makes the Buffer write all data written into it into w as well (a hasher or a network
+// connection, for example). Data is written into w after it is stored in the Buffer, so w receives
+// exactly the data accepted by the Buffer. If w returns an error, Write returns it, but the data
+// is already stored in the Buffer. Pass nil to disable the tee
+func (b *Buffer) SetTee(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tee = w
+}
@@ -0,0 +1,53 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("test error")
+}
+
+func TestBuffer_Tee(t *testing.T) {
+	t.Run("Copy", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1000))
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		var tee bytes.Buffer
+		b.SetTee(&tee)
+
+		writeByChunks(require, b, slice[:500], 7)
+		_, err := b.ReadFrom(bytes.NewReader(slice[500:]))
+		require.Nil(err)
+
+		require.Equal(slice, tee.Bytes(), "tee got wrong data")
+
+		res := readByChunks(require, b, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+
+		b.SetTee(failingWriter{})
+
+		n, err := b.Write([]byte("hello"))
+		require.NotNil(err)
+		// Data is stored in the Buffer anyway
+		require.Equal(5, n)
+		require.Equal(5, b.Len())
+	})
+}
@@ -0,0 +1,89 @@
+package buffer
+
+import (
+	"time"
+)
+
+// ThrottledReader reads data from a Buffer at a limited rate. It is created by Buffer.ThrottledReader
+type ThrottledReader struct {
+	b *Buffer
+
+	// rate is the number of bytes per second, burst is the max number of bytes read at once
+	rate  float64
+	burst int
+
+	// tokens is the number of bytes that can be read now. It is updated at last
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// ThrottledReader returns a reader that drains the Buffer at bytesPerSec bytes per second on average.
+// Up to burst bytes can be read at once. If burst is not positive, it is equal to bytesPerSec.
+// If bytesPerSec is not positive, the rate isn't limited. The reader has Len, so it can be used instead
+// of the Buffer, for example, as a body of an HTTP request. It isn't safe for concurrent use
+func (b *Buffer) ThrottledReader(bytesPerSec, burst int) *ThrottledReader {
+	if burst <= 0 {
+		burst = bytesPerSec
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &ThrottledReader{
+		b:      b,
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// Read reads up to burst bytes. It waits till the data can be read without exceeding the rate
+func (tr *ThrottledReader) Read(p []byte) (int, error) {
+	if tr.rate <= 0 {
+		return tr.b.Read(p)
+	}
+
+	// Don't wait for data that can't be read
+	left := tr.b.Len64()
+	if left == 0 {
+		return tr.b.Read(p)
+	}
+	if len(p) > tr.burst {
+		p = p[:tr.burst]
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+
+	tr.refill()
+	if missing := float64(len(p)) - tr.tokens; missing > 0 {
+		tr.sleep(time.Duration(missing / tr.rate * float64(time.Second)))
+		tr.refill()
+	}
+
+	n, err := tr.b.Read(p)
+	tr.tokens -= float64(n)
+	return n, err
+}
+
+// refill adds tokens for the time passed since the last update
+func (tr *ThrottledReader) refill() {
+	now := tr.now()
+	if !tr.last.IsZero() {
+		tr.tokens += now.Sub(tr.last).Seconds() * tr.rate
+		if tr.tokens > float64(tr.burst) {
+			tr.tokens = float64(tr.burst)
+		}
+	}
+	tr.last = now
+}
+
+// Len returns the number of bytes of the unread portion of the Buffer
+func (tr *ThrottledReader) Len() int {
+	return tr.b.Len()
+}
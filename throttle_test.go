@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_ThrottledReader(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(10000))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 1000)
+
+	tr := b.ThrottledReader(1000, 500)
+
+	// Fake clock
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	tr.now = func() time.Time { return now }
+	tr.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	require.Equal(len(slice), tr.Len())
+
+	res, err := ioutil.ReadAll(tr)
+	require.Nil(err)
+	require.Equal(slice, res)
+	require.Equal(0, tr.Len())
+
+	// The first 500 bytes are the burst, the rest is read at 1000 bytes/sec
+	require.InDelta((9500 * time.Millisecond).Seconds(), slept.Seconds(), 0.01)
+
+	// Unlimited
+	b.Reset()
+	writeByChunks(require, b, slice, 1000)
+	res, err = ioutil.ReadAll(b.ThrottledReader(0, 0))
+	require.Nil(err)
+	require.Equal(slice, res)
+}
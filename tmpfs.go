@@ -0,0 +1,82 @@
+package buffer
+
+import (
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// TmpfsPolicy defines how the Buffer handles a temp dir that is stored in memory (tmpfs, ramfs).
+// Spilling "to disk" on tmpfs silently defeats the purpose of bounding memory
+type TmpfsPolicy int
+
+const (
+	// TmpfsAllow disables tmpfs detection. It is the default policy
+	TmpfsAllow TmpfsPolicy = iota
+	// TmpfsWarn makes the Buffer log a warning when a temp file is created on tmpfs
+	TmpfsWarn
+	// TmpfsRefuse makes the Buffer return ErrTmpfs instead of creating a temp file on tmpfs
+	TmpfsRefuse
+	// TmpfsPrefer makes the Buffer create temp files on tmpfs (for example, /dev/shm) if the temp dir
+	// isn't stored in memory. It is useful when the speed is more important than memory usage
+	TmpfsPrefer
+)
+
+// ErrTmpfs is returned when the temp dir is on tmpfs and TmpfsRefuse policy is used
+var ErrTmpfs = errors.New("temp dir is stored in memory (tmpfs)")
+
+// SetTmpfsPolicy sets the policy for temp dirs stored in memory. The policy is applied
+// every time a temp file is created. Tmpfs detection is supported only on Linux
+func (b *Buffer) SetTmpfsPolicy(policy TmpfsPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tmpfsPolicy = policy
+}
+
+// IsTmpfs reports whether dir is stored in memory (tmpfs, ramfs). It always returns false
+// on platforms other than Linux
+func IsTmpfs(dir string) (bool, error) {
+	ok, err := isTmpfs(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't get file system stats of '%s'", dir)
+	}
+	return ok, nil
+}
+
+// tempDir returns a directory for temp files according to the tmpfs policy
+func (b *Buffer) tempDir() (string, error) {
+	dir := b.tempFileDir
+	if b.tmpfsPolicy == TmpfsAllow {
+		return dir, nil
+	}
+
+	checkDir := dir
+	if checkDir == "" {
+		checkDir = os.TempDir()
+	}
+	tmpfs, err := IsTmpfs(checkDir)
+	if err != nil {
+		return "", err
+	}
+
+	switch b.tmpfsPolicy {
+	case TmpfsWarn:
+		if tmpfs {
+			log.Printf("go-disk-buffer: temp dir '%s' is stored in memory (tmpfs)", checkDir)
+		}
+	case TmpfsRefuse:
+		if tmpfs {
+			return "", errors.Wrapf(ErrTmpfs, "can't use temp dir '%s'", checkDir)
+		}
+	case TmpfsPrefer:
+		if !tmpfs && tmpfsDir != "" {
+			if ok, _ := isTmpfs(tmpfsDir); ok {
+				dir = tmpfsDir
+			}
+		}
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,23 @@
+package buffer
+
+import "syscall"
+
+// Magic numbers of file systems stored in memory. See statfs(2)
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+// tmpfsDir is a directory on tmpfs used by TmpfsPrefer policy
+const tmpfsDir = "/dev/shm"
+
+func isTmpfs(dir string) (bool, error) {
+	var stats syscall.Statfs_t
+	err := syscall.Statfs(dir, &stats)
+	if err != nil {
+		return false, err
+	}
+
+	magic := int64(stats.Type)
+	return magic == tmpfsMagic || magic == ramfsMagic, nil
+}
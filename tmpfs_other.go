@@ -0,0 +1,11 @@
+//go:build !linux
+
+package buffer
+
+// tmpfsDir is a directory on tmpfs used by TmpfsPrefer policy. It is empty on platforms
+// without tmpfs detection
+const tmpfsDir = ""
+
+func isTmpfs(string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_TmpfsPolicy(t *testing.T) {
+	if ok, _ := IsTmpfs(tmpfsDir); tmpfsDir == "" || !ok {
+		t.Skip("tmpfs isn't available")
+	}
+
+	diskDir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(diskDir)
+
+	if ok, _ := IsTmpfs(diskDir); ok {
+		t.Skip("temp dir is stored in memory")
+	}
+
+	t.Run("Refuse", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		require.Nil(b.ChangeTempDir(tmpfsDir))
+		b.SetTmpfsPolicy(TmpfsRefuse)
+
+		_, err := b.Write([]byte("hello"))
+		require.Equal(ErrTmpfs, errors.Cause(err))
+
+		// Regular directory must be allowed
+		require.Nil(b.ChangeTempDir(diskDir))
+		_, err = b.Write([]byte("hello"))
+		require.Nil(err)
+	})
+
+	t.Run("Prefer", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		require.Nil(b.ChangeTempDir(diskDir))
+		b.SetTmpfsPolicy(TmpfsPrefer)
+
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+		require.Equal(tmpfsDir, filepath.Dir(b.filename))
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(0)
+		defer b.Reset()
+
+		require.Nil(b.ChangeTempDir(tmpfsDir))
+		b.SetTmpfsPolicy(TmpfsWarn)
+
+		_, err := b.Write([]byte("hello"))
+		require.Nil(err)
+		require.Equal(tmpfsDir, filepath.Dir(b.filename))
+	})
+}
@@ -0,0 +1,20 @@
+package buffer
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrTokenTooLong is returned by ReadBytes and ReadString when the delimiter isn't found
+// within the max token size (see SetMaxTokenSize)
+var ErrTokenTooLong = errors.New("token is too long")
+
+// SetMaxTokenSize limits the size of data (including the delimiter) returned by ReadBytes and ReadString.
+// If the delimiter isn't found within the limit, they return the first size bytes and ErrTokenTooLong.
+// The rest of the token stays in the Buffer. So, input without delimiters can't make the Buffer
+// read the whole temp file into memory. The size isn't limited if it is 0
+func (b *Buffer) SetMaxTokenSize(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maxTokenSize = size
+}
@@ -0,0 +1,89 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_MaxTokenSize(t *testing.T) {
+	long := generateRandomString(10000)
+	data := "short\n" + long + "\n" + "last"
+
+	tests := []struct {
+		desc            string
+		maxInMemorySize int
+	}{
+		{desc: "memory", maxInMemorySize: 1 << 20},
+		{desc: "file", maxInMemorySize: 100},
+		{desc: "memory and file", maxInMemorySize: 5000},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			b := NewBufferWithMaxMemorySize(tt.maxInMemorySize)
+			defer b.Reset()
+			b.SetMaxTokenSize(1000)
+			writeByChunks(require, b, []byte(data), 77)
+
+			line, err := b.ReadBytes('\n')
+			require.Nil(err)
+			require.Equal("short\n", string(line))
+
+			// The rest of the token stays in the Buffer
+			var res []byte
+			for {
+				line, err := b.ReadBytes('\n')
+				res = append(res, line...)
+				if err == nil {
+					break
+				}
+				require.Equal(ErrTokenTooLong, err)
+				require.Len(line, 1000)
+			}
+			require.Equal(long+"\n", string(res))
+
+			line, err = b.ReadBytes('\n')
+			require.Equal(io.EOF, err)
+			require.Equal("last", string(line))
+		})
+	}
+
+	t.Run("delimiter at the limit", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+		b.SetMaxTokenSize(5)
+		_, err := b.WriteString("abcd\nabcde\n")
+		require.Nil(err)
+
+		line, err := b.ReadString('\n')
+		require.Nil(err)
+		require.Equal("abcd\n", line)
+
+		line, err = b.ReadString('\n')
+		require.Equal(ErrTokenTooLong, err)
+		require.Equal("abcde", line)
+
+		line, err = b.ReadString('\n')
+		require.Nil(err)
+		require.Equal("\n", line)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		defer b.Reset()
+		_, err := b.WriteString(long)
+		require.Nil(err)
+
+		line, err := b.ReadString('\n')
+		require.Equal(io.EOF, err)
+		require.Equal(long, line)
+	})
+}
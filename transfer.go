@@ -0,0 +1,159 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// lockBuffers locks both Buffers in the order of their addresses, so concurrent transfers
+// in opposite directions (a.ReadFrom(b) and b.ReadFrom(a)) can't deadlock
+func lockBuffers(a, b *Buffer) (unlock func()) {
+	if uintptr(unsafe.Pointer(a)) > uintptr(unsafe.Pointer(b)) {
+		a, b = b, a
+	}
+	a.mu.Lock()
+	b.mu.Lock()
+
+	return func() {
+		b.mu.Unlock()
+		a.mu.Unlock()
+	}
+}
+
+// transferBuffer moves the unread data of src into dst. It is used by ReadFrom and WriteTo
+// when both sides are Buffers. Data stored in memory is copied at once. The temp file of src
+// is moved into dst if possible, otherwise it is copied by chunks
+func transferBuffer(dst, src *Buffer) (n int64, err error) {
+	if dst == src {
+		return 0, errors.New("can't transfer Buffer into itself")
+	}
+
+	unlock := lockBuffers(dst, src)
+	defer unlock()
+
+	if src.readingFinished {
+		return 0, nil
+	}
+	err = src.finishWriting()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't read data from Buffer")
+	}
+
+	if src.buff.Len() != 0 {
+		written, err := dst.write(src.buff.Bytes())
+		src.hashRead(src.buff.Next(written))
+		src.offset += int64(written)
+		n += int64(written)
+		if err != nil {
+			return n, errors.Wrap(err, "can't write data")
+		}
+	}
+
+	if !src.useFile {
+		return n, src.finishReading()
+	}
+
+	// Staged data precedes the adopted file
+	err = dst.flushStaging()
+	if err != nil {
+		return n, errors.Wrap(err, "can't write data")
+	}
+	if dst.canAdoptTempFile(src) {
+		moved, err := dst.adoptTempFile(src)
+		if err == nil {
+			return n + moved, nil
+		}
+		// Copy the file
+	}
+
+	data := make([]byte, readFromChunkSize)
+	for {
+		rN, rErr := src.read(data)
+		if rErr != nil && rErr != io.EOF {
+			return n, errors.Wrap(rErr, "can't read data from Buffer")
+		}
+
+		wN, wErr := dst.write(data[:rN])
+		if wErr != nil {
+			return n + int64(wN), errors.Wrap(wErr, "can't write data")
+		}
+		n += int64(rN)
+
+		if rErr == io.EOF {
+			return n, nil
+		}
+	}
+}
+
+// canAdoptTempFile reports whether the temp file of src can be moved into b as is. Both Buffers
+// must store the file in the same format, and the file must not be read yet
+func (b *Buffer) canAdoptTempFile(src *Buffer) bool {
+	switch {
+	case src.keepFile, src.readFile != nil, src.fileConsumed() != 0, src.unlinkedFile != nil:
+		return false
+	case src.encrypt, src.checksums != nil, src.quota != nil, src.readHash != nil:
+		return false
+	case b.useFile, b.writeFile != nil, b.diskFailed, b.writingFinished, b.hash != nil, b.tee != nil, b.quota != nil, b.countRecords:
+		return false
+	case b.lockFiles && src.fileLock == nil:
+		return false
+	case b.encrypt, b.checksumsEnabled, b.mmapWriteRegionSize > 0, b.sparseFiles, b.diskFullPolicy != DiskFullFail, b.mirrorDir != "":
+		return false
+	}
+	return true
+}
+
+// adoptTempFile moves the temp file of src into the temp dir of b. Further writes into b are
+// appended to the file. It returns the number of moved bytes
+func (b *Buffer) adoptTempFile(src *Buffer) (int64, error) {
+	// Open the file before renaming: the descriptor follows the file
+	file, err := os.OpenFile(src.filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't open a temp file '%s'", src.filename)
+	}
+
+	placeholder, err := b.createTempFile(src.fileSize)
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	filename := placeholder.Name()
+	placeholder.Close()
+
+	err = os.Rename(src.filename, filename)
+	if err != nil {
+		file.Close()
+		b.tempFiles().Remove(filename)
+		return 0, errors.Wrapf(err, "can't move a temp file '%s'", src.filename)
+	}
+
+	if b.manager != nil && !b.tracked {
+		b.manager.add(b)
+	}
+
+	size := src.fileSize
+	b.filename = filename
+	b.fileSize = size
+	b.size += size
+	b.useFile = true
+
+	err = b.setWriteFile(file, nil)
+	if err != nil {
+		// The file is moved already, so b owns it. The data can be read, but not appended
+		file.Close()
+		b.finishWriting()
+	}
+
+	// The file belongs to b now. The lock follows the file
+	b.setFileLock(src.fileLock)
+	src.fileLock = nil
+	src.offset += size
+	src.keepFile = true
+	src.removeTempFile()
+	src.finishReading()
+
+	return size, nil
+}
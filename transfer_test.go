@@ -0,0 +1,158 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Transfer(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(100))
+
+		src := NewBufferWithMaxMemorySize(200)
+		defer src.Reset()
+		dst := NewBufferWithMaxMemorySize(200)
+		defer dst.Reset()
+
+		_, err := src.Write(slice)
+		require.Nil(err)
+
+		n, err := dst.ReadFrom(src)
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+		require.Equal(0, src.Len())
+		require.Empty(dst.filename)
+
+		res := readByChunks(require, dst, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Move temp file", func(t *testing.T) {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir)
+
+		slice := []byte(generateRandomString(1000))
+
+		src := NewBufferWithMaxMemorySize(10)
+		defer src.Reset()
+		dst := NewBufferWithMaxMemorySize(10)
+		defer dst.Reset()
+
+		require.Nil(dst.ChangeTempDir(dir))
+
+		writeByChunks(require, src, slice[:500], 7)
+		srcFilename := src.filename
+
+		n, err := src.WriteTo(dst)
+		require.Nil(err)
+		require.Equal(int64(500), n)
+		require.Equal(0, src.Len())
+
+		// The file must be moved into the temp dir of dst
+		require.Equal(dir, filepath.Dir(dst.filename))
+		require.Equal(int64(490), dst.fileSize)
+		_, err = os.Stat(srcFilename)
+		require.True(os.IsNotExist(err), "temp file of src must be moved")
+
+		// Writes must be appended to the moved file
+		_, err = dst.Write(slice[500:])
+		require.Nil(err)
+
+		res := readByChunks(require, dst, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Copy temp file", func(t *testing.T) {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(1000))
+
+		src := NewBufferWithMaxMemorySize(10)
+		defer src.Reset()
+		dst := NewBufferWithMaxMemorySize(10)
+		defer dst.Reset()
+
+		require.Nil(src.EnableEncryption())
+
+		writeByChunks(require, src, slice, 7)
+		srcFilename := src.filename
+
+		n, err := dst.ReadFrom(src)
+		require.Nil(err)
+		require.Equal(int64(len(slice)), n)
+		require.NotEqual(srcFilename, dst.filename)
+
+		_, err = os.Stat(srcFilename)
+		require.True(os.IsNotExist(err), "temp file of src must be removed after reading")
+
+		res := readByChunks(require, dst, 16)
+		require.Equal(slice, res, "wrong content was read")
+	})
+
+	t.Run("Itself", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(10)
+		defer b.Reset()
+
+		_, err := b.ReadFrom(b)
+		require.NotNil(err)
+	})
+}
+
+func TestBuffer_Transfer_Concurrent(t *testing.T) {
+	require := require.New(t)
+
+	a := NewBufferWithMaxMemorySize(100)
+	defer a.Reset()
+	_, err := a.ReadFrom(a)
+	require.NotNil(err)
+
+	// Transfers in opposite directions must lock the Buffers in the same order
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 20; i++ {
+			a := NewBufferWithMaxMemorySize(100)
+			b := NewBufferWithMaxMemorySize(100)
+
+			// Make the transfers wait for the same Buffer
+			a.mu.Lock()
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				b.ReadFrom(a)
+			}()
+			time.Sleep(time.Millisecond)
+			go func() {
+				defer wg.Done()
+				a.ReadFrom(b)
+			}()
+			time.Sleep(time.Millisecond)
+			a.mu.Unlock()
+			wg.Wait()
+
+			a.Reset()
+			b.Reset()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow("transfers are deadlocked")
+	}
+}
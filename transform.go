@@ -0,0 +1,20 @@
+package buffer
+
+import (
+	"io"
+
+	"golang.org/x/text/transform"
+)
+
+// WriteTransformed returns a writer that transforms data with t and writes the result into the Buffer
+// (newline conversion or charset fixes, for example). Data is transformed by chunks without extra full
+// copies. The writer must be closed to flush the data buffered by t. It doesn't reset the Buffer
+func (b *Buffer) WriteTransformed(t transform.Transformer) io.WriteCloser {
+	return transform.NewWriter(b, t)
+}
+
+// ReadTransformed returns a reader that reads data from the Buffer and transforms it with t.
+// The Buffer is drained as the reader is read
+func (b *Buffer) ReadTransformed(t transform.Transformer) io.Reader {
+	return transform.NewReader(b, t)
+}
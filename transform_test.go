@@ -0,0 +1,66 @@
+package buffer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/transform"
+)
+
+// crlfToLF converts "\r\n" into "\n"
+type crlfToLF struct {
+	transform.NopResetter
+}
+
+func (crlfToLF) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		c := src[nSrc]
+		if c == '\r' {
+			if nSrc+1 == len(src) && !atEOF {
+				// Wait for the next byte
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			if nSrc+1 < len(src) && src[nSrc+1] == '\n' {
+				nSrc++
+				continue
+			}
+		}
+		if nDst == len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		dst[nDst] = c
+		nDst++
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
+
+func TestBuffer_Transformed(t *testing.T) {
+	require := require.New(t)
+
+	line := generateRandomString(30)
+	crlf := []byte(line + "\r\n" + line + "\r\n" + line + "\r")
+	lf := []byte(line + "\n" + line + "\n" + line + "\r")
+	crlf = bytes.Repeat(crlf, 20)
+	lf = bytes.Repeat(lf, 20)
+
+	// Write
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+
+	w := b.WriteTransformed(crlfToLF{})
+	require.Nil(writeByChunksBenchmark(w, crlf, 7))
+	require.Nil(w.Close())
+	require.Equal(lf, readByChunks(require, b, 16))
+
+	// Read
+	b.Reset()
+	writeByChunks(require, b, crlf, 7)
+
+	res, err := ioutil.ReadAll(b.ReadTransformed(crlfToLF{}))
+	require.Nil(err)
+	require.Equal(lf, res)
+	require.Equal(0, b.Len())
+}
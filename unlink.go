@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnlinkingUnsupported is returned by EnableUnlinkedTempFiles on platforms that can't remove open files
+var ErrUnlinkingUnsupported = errors.New("removal of open files isn't supported on this platform")
+
+// EnableUnlinkedTempFiles makes the Buffer remove the temp file right after creation and keep only its
+// descriptor. The space is freed by the OS when the descriptor is closed, so crashed processes never leave
+// temp files behind. It is a portable alternative to O_TMPFILE that works on every file system.
+//
+// Files aren't unlinked if writes through memory mappings, mirrors or DiskFullPolicy other than DiskFullFail
+// are used: they need the name. MigrateTempDir, PunchHoles and EnableFileLocking ignore unlinked files.
+// EnableUnlinkedTempFiles must be called before the first Write
+func (b *Buffer) EnableUnlinkedTempFiles() error {
+	if !unlinkingSupported {
+		return ErrUnlinkingUnsupported
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.unlinkTempFiles = true
+
+	return nil
+}
+
+// canUnlinkTempFile reports whether a new temp file can be unlinked
+func (b *Buffer) canUnlinkTempFile() bool {
+	return b.unlinkTempFiles && b.mmapWriteRegionSize == 0 && b.mirrorDir == "" && b.diskFullPolicy == DiskFullFail
+}
+
+// unlinkFile opens a file for reading and removes it. The data is available through the returned
+// file till it is closed
+func unlinkFile(tempFiles TempFileStrategy, filename string) (*os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open a temp file '%s'", filename)
+	}
+	err = tempFiles.Remove(filename)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "can't unlink a temp file '%s'", filename)
+	}
+	return file, nil
+}
+
+// replaceUnlinkedFile closes the descriptor of the unlinked file old if it was replaced
+func (b *Buffer) replaceUnlinkedFile(old *os.File) {
+	if old != nil && old != b.unlinkedFile {
+		old.Close()
+	}
+}
+
+// restoreUnlinkedFile closes the descriptor of the new unlinked file and restores old
+func (b *Buffer) restoreUnlinkedFile(old *os.File) {
+	if b.unlinkedFile != nil && b.unlinkedFile != old {
+		b.unlinkedFile.Close()
+	}
+	b.unlinkedFile = old
+}
+
+// closeUnlinkedFile closes the descriptor of the unlinked file. The space is freed by the OS
+func (b *Buffer) closeUnlinkedFile() {
+	if b.unlinkedFile != nil {
+		b.unlinkedFile.Close()
+		b.unlinkedFile = nil
+	}
+}
+
+// openUnlinkedFile opens the unlinked file for reading. The returned file has its own descriptor and offset
+func (b *Buffer) openUnlinkedFile() (readableFile, error) {
+	file, err := dupFile(b.unlinkedFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't duplicate the descriptor of a temp file '%s'", b.filename)
+	}
+	return &unlinkedFile{File: file}, nil
+}
+
+// unlinkedFile reads data with ReadAt only: duplicated descriptors share the file offset
+type unlinkedFile struct {
+	*os.File
+
+	off int64
+}
+
+func (f *unlinkedFile) Read(p []byte) (int, error) {
+	n, err := f.File.ReadAt(p, f.off)
+	f.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
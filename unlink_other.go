@@ -0,0 +1,13 @@
+//go:build !unix
+
+package buffer
+
+import (
+	"os"
+)
+
+const unlinkingSupported = false
+
+func dupFile(*os.File) (*os.File, error) {
+	return nil, ErrUnlinkingUnsupported
+}
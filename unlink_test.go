@@ -0,0 +1,53 @@
+package buffer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_EnableUnlinkedTempFiles(t *testing.T) {
+	if !unlinkingSupported {
+		t.Skip("unlinking isn't supported")
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		require := require.New(t)
+
+		slice := []byte(generateRandomString(10000))
+
+		b := NewBufferWithMaxMemorySize(100)
+		require.Nil(b.EnableUnlinkedTempFiles())
+		b.EnableChecksums()
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+
+		writeByChunks(require, b, slice, 7)
+		require.NotNil(b.unlinkedFile)
+		_, err := os.Stat(b.filename)
+		require.True(os.IsNotExist(err), "temp file must be removed")
+
+		at := make([]byte, 100)
+		_, err = b.ReadAt(at, 5000)
+		require.Nil(err)
+		require.Equal(slice[5000:5100], at)
+
+		res := make([]byte, 6000)
+		_, err = b.Read(res)
+		require.Nil(err)
+
+		// Compaction creates a new unlinked file
+		old := b.unlinkedFile
+		require.Nil(b.Compact())
+		require.NotEqual(old, b.unlinkedFile)
+		require.NotNil(b.unlinkedFile)
+
+		res = append(res, readByChunks(require, b, 16)...)
+		require.Equal(slice, res)
+
+		b.Reset()
+		require.Nil(b.unlinkedFile)
+	}
+}
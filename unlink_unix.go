@@ -0,0 +1,20 @@
+//go:build unix
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+const unlinkingSupported = true
+
+// dupFile returns a new descriptor of file
+func dupFile(file *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), file.Name()), nil
+}
@@ -0,0 +1,81 @@
+package buffer
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSizeMismatch is returned by Verify when the size of the temp file doesn't match the written data
+var ErrSizeMismatch = errors.New("size of the temp file doesn't match the written data")
+
+// Verify re-reads the whole temp file and checks its integrity: checksums (see EnableChecksums),
+// authentication of encrypted data and the size of the file. It returns *ChecksumError, ErrTampered,
+// ErrSpillLost or ErrSizeMismatch wrapped with details (see errors.Cause). The read position isn't changed.
+//
+// Verify finishes writing. It does nothing if the Buffer doesn't use a temp file
+func (b *Buffer) Verify() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.finishWriting()
+	if err != nil {
+		return err
+	}
+	if !b.useFile {
+		return nil
+	}
+
+	file, err := b.openTempFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stats, err := file.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "can't get stats of a temp file '%s'", b.filename)
+	}
+
+	if b.persistentIndex != nil {
+		return b.verifyPersistent(file, stats.Size())
+	}
+
+	expected := b.fileSize - b.fileDropped
+	if !b.encrypt && stats.Size() != expected {
+		return errors.Wrapf(ErrSizeMismatch, "temp file '%s' contains %d bytes, expected %d", b.filename, stats.Size(), expected)
+	}
+
+	// Checksums and MACs are verified during reading
+	src, err := b.openReadFile()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	n, err := io.Copy(ioutil.Discard, src)
+	if err != nil {
+		return errors.Wrapf(err, "can't read a temp file '%s'", b.filename)
+	}
+	if n != expected {
+		return errors.Wrapf(ErrSizeMismatch, "temp file '%s' contains %d bytes of data, expected %d", b.filename, n, expected)
+	}
+	return nil
+}
+
+// verifyPersistent checks records of the file of a persistent Buffer
+func (b *Buffer) verifyPersistent(file io.ReaderAt, size int64) error {
+	index, err := scanPersistentRecords(file)
+	if err != nil {
+		return errors.Wrapf(err, "can't read a file '%s'", b.filename)
+	}
+	if index.fileSize < b.persistentIndex.fileSize {
+		// The record is corrupted or truncated
+		return errors.Wrap(&ChecksumError{Filename: b.filename, Offset: index.fileSize}, "invalid record")
+	}
+	if size != b.persistentIndex.fileSize {
+		return errors.Wrapf(ErrSizeMismatch, "file '%s' contains %d bytes, expected %d", b.filename, size, b.persistentIndex.fileSize)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Verify(t *testing.T) {
+	slice := []byte(generateRandomString(200 << 10))
+
+	newBuffer := func(require *require.Assertions, encrypt, checksums bool) *Buffer {
+		b := NewBufferWithMaxMemorySize(100)
+		if encrypt {
+			require.Nil(b.EnableEncryption())
+		}
+		if checksums {
+			b.EnableChecksums()
+		}
+		writeByChunks(require, b, slice, 4096)
+		return b
+	}
+
+	// corrupt flips a byte in the middle of the temp file
+	corrupt := func(require *require.Assertions, filename string) {
+		file, err := os.OpenFile(filename, os.O_RDWR, 0)
+		require.Nil(err)
+		defer file.Close()
+
+		data := make([]byte, 1)
+		_, err = file.ReadAt(data, 50<<10)
+		require.Nil(err)
+		data[0] ^= 0xff
+		_, err = file.WriteAt(data, 50<<10)
+		require.Nil(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		for _, encrypt := range []bool{false, true} {
+			require := require.New(t)
+
+			b := newBuffer(require, encrypt, true)
+			defer b.Reset()
+
+			require.Nil(b.Verify())
+			// The read position isn't changed
+			require.Equal(len(slice), b.Len())
+			require.Equal(slice, readByChunks(require, b, 1000))
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		require := require.New(t)
+
+		b := NewBufferWithMaxMemorySize(100)
+		_, err := b.Write([]byte("data"))
+		require.Nil(err)
+		require.Nil(b.Verify())
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		require := require.New(t)
+
+		b := newBuffer(require, false, true)
+		defer b.Reset()
+		require.Nil(b.finishWriting())
+
+		corrupt(require, b.filename)
+		err := b.Verify()
+		require.IsType(&ChecksumError{}, errors.Cause(err))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		require := require.New(t)
+
+		b := newBuffer(require, true, false)
+		defer b.Reset()
+		require.Nil(b.finishWriting())
+
+		corrupt(require, b.filename)
+		err := b.Verify()
+		require.Equal(ErrTampered, errors.Cause(err))
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		require := require.New(t)
+
+		b := newBuffer(require, false, false)
+		defer b.Reset()
+		require.Nil(b.finishWriting())
+
+		require.Nil(os.Truncate(b.filename, 1000))
+		err := b.Verify()
+		require.Equal(ErrSizeMismatch, errors.Cause(err))
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		require := require.New(t)
+
+		b := newBuffer(require, false, false)
+		defer b.Reset()
+		require.Nil(b.finishWriting())
+
+		require.Nil(os.Remove(b.filename))
+		err := b.Verify()
+		require.Equal(ErrSpillLost, errors.Cause(err))
+	})
+	t.Run("persistent", func(t *testing.T) {
+		require := require.New(t)
+
+		dir, err := ioutil.TempDir("", "go-disk-buffer-test-")
+		require.Nil(err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "persistent")
+		b, err := OpenPersistent(path)
+		require.Nil(err)
+		defer b.Reset()
+		writeByChunks(require, b, slice, 4096)
+		require.Nil(b.Sync())
+
+		require.Nil(b.Verify())
+
+		corrupt(require, path)
+		err = b.Verify()
+		require.IsType(&ChecksumError{}, errors.Cause(err))
+	})
+}
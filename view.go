@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrViewClosed is returned by a view returned by AsReadSeekCloser after Close
+var ErrViewClosed = errors.New("view is closed")
+
+// AsReadSeekCloser finishes writing and returns a view of the written data that implements
+// io.ReadSeekCloser. Close releases only the view: the Buffer isn't reset.
+//
+// The view is backed by ReadAt, so multiple views can be used in parallel. The Buffer must not be
+// read with Read till the view is used: the temp file is removed when the Buffer is drained
+func (b *Buffer) AsReadSeekCloser() (io.ReadSeekCloser, error) {
+	b.mu.Lock()
+	err := b.finishWriting()
+	size := b.size
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return &readSeekView{
+		r: io.NewSectionReader(b, 0, size),
+	}, nil
+}
+
+// readSeekView is a view returned by AsReadSeekCloser
+type readSeekView struct {
+	r      *io.SectionReader
+	closed bool
+}
+
+func (v *readSeekView) Read(p []byte) (int, error) {
+	if v.closed {
+		return 0, ErrViewClosed
+	}
+	return v.r.Read(p)
+}
+
+func (v *readSeekView) Seek(offset int64, whence int) (int64, error) {
+	if v.closed {
+		return 0, ErrViewClosed
+	}
+	return v.r.Seek(offset, whence)
+}
+
+func (v *readSeekView) Close() error {
+	if v.closed {
+		return ErrViewClosed
+	}
+	v.closed = true
+	return nil
+}
@@ -0,0 +1,55 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_AsReadSeekCloser(t *testing.T) {
+	require := require.New(t)
+
+	b := NewBufferWithMaxMemorySize(10)
+	defer b.Reset()
+
+	slice := []byte(generateRandomString(100))
+	writeByChunks(require, b, slice, 7)
+
+	view, err := b.AsReadSeekCloser()
+	require.Nil(err)
+
+	res, err := readByChunksBenchmark(view, 16)
+	require.Nil(err)
+	require.Equal(slice, res, "wrong content was read")
+
+	// Seek
+	off, err := view.Seek(-30, io.SeekEnd)
+	require.Nil(err)
+	require.Equal(int64(70), off)
+
+	res = make([]byte, 10)
+	_, err = io.ReadFull(view, res)
+	require.Nil(err)
+	require.Equal(slice[70:80], res)
+
+	_, err = view.Seek(5, io.SeekStart)
+	require.Nil(err)
+	_, err = io.ReadFull(view, res)
+	require.Nil(err)
+	require.Equal(slice[5:15], res)
+
+	// Close releases only the view
+	require.Nil(view.Close())
+	_, err = view.Read(res)
+	require.Equal(ErrViewClosed, err)
+	_, err = view.Seek(0, io.SeekStart)
+	require.Equal(ErrViewClosed, err)
+
+	require.Equal(len(slice), b.Len())
+	require.Equal(slice, readByChunks(require, b, 16))
+
+	// Writing is finished
+	_, err = b.Write(slice)
+	require.Equal(ErrBufferFinished, err)
+}
@@ -0,0 +1,71 @@
+package buffer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultiWriteError is returned by WriteToMulti when some destinations failed
+type MultiWriteError struct {
+	// Errs contains errors of the destinations in the order they were passed. The error
+	// of a successful destination is nil
+	Errs []error
+}
+
+func (e *MultiWriteError) Error() string {
+	var msgs []string
+	for i, err := range e.Errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("writer %d: %s", i, err))
+		}
+	}
+	return "can't write data into io.Writer: " + strings.Join(msgs, "; ")
+}
+
+// WriteToMulti reads the Buffer once and writes every chunk into all ws. A failed destination doesn't
+// stop writing into others: it is skipped till the end. The returned slice contains the number of bytes
+// written into each destination. If some destinations failed, the error is *MultiWriteError.
+//
+// Just like WriteTo, WriteToMulti drains the Buffer. It stops when all destinations failed
+func (b *Buffer) WriteToMulti(ws ...io.Writer) ([]int64, error) {
+	var (
+		written = make([]int64, len(ws))
+		errs    = make([]error, len(ws))
+		failed  int
+	)
+
+	data := make([]byte, readFromChunkSize)
+	for failed < len(ws) {
+		rN, rErr := b.Read(data)
+		if rErr != nil && rErr != io.EOF {
+			return written, errors.Wrap(rErr, "can't read data from Buffer")
+		}
+
+		for i, w := range ws {
+			if errs[i] != nil || rN == 0 {
+				continue
+			}
+
+			wN, wErr := w.Write(data[:rN])
+			written[i] += int64(wN)
+			if wErr == nil && wN < rN {
+				wErr = io.ErrShortWrite
+			}
+			if wErr != nil {
+				errs[i] = wErr
+				failed++
+			}
+		}
+
+		if rErr == io.EOF {
+			break
+		}
+	}
+	if failed > 0 {
+		return written, &MultiWriteError{Errs: errs}
+	}
+	return written, nil
+}
@@ -0,0 +1,61 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// limitedWriter accepts limit bytes and fails after that
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("limit is reached")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestBuffer_WriteToMulti(t *testing.T) {
+	require := require.New(t)
+
+	slice := []byte(generateRandomString(3*readFromChunkSize + 100))
+
+	b := NewBufferWithMaxMemorySize(100)
+	defer b.Reset()
+	writeByChunks(require, b, slice, 1000)
+
+	var (
+		w1     bytes.Buffer
+		w2     bytes.Buffer
+		failed = &limitedWriter{limit: readFromChunkSize + 10}
+	)
+	written, err := b.WriteToMulti(&w1, failed, &w2)
+	require.Equal([]int64{int64(len(slice)), readFromChunkSize + 10, int64(len(slice))}, written)
+	require.Equal(slice, w1.Bytes())
+	require.Equal(slice, w2.Bytes())
+	require.Equal(0, b.Len())
+
+	multiErr, ok := err.(*MultiWriteError)
+	require.True(ok)
+	require.Len(multiErr.Errs, 3)
+	require.Nil(multiErr.Errs[0])
+	require.NotNil(multiErr.Errs[1])
+	require.Nil(multiErr.Errs[2])
+
+	// All destinations succeeded
+	b.Reset()
+	writeByChunks(require, b, slice, 1000)
+	w1.Reset()
+	written, err = b.WriteToMulti(&w1)
+	require.Nil(err)
+	require.Equal([]int64{int64(len(slice))}, written)
+	require.Equal(slice, w1.Bytes())
+}